@@ -1,6 +1,10 @@
 package box
 
 import (
+	"io"
+	"time"
+
+	"github.com/twystd/unboxd/box/events"
 	"github.com/twystd/unboxd/box/files"
 	"github.com/twystd/unboxd/box/folders"
 	"github.com/twystd/unboxd/box/templates"
@@ -35,16 +39,72 @@ func (b Box) Hash() string {
 	return b.hash
 }
 
+func (b *Box) GetFolder(folderID uint64) (*folders.Folder, error) {
+	return folders.Get(folderID, b.token.Token)
+}
+
 func (b *Box) ListFolders(folderID uint64) ([]folders.Folder, error) {
 	return folders.List(folderID, b.token.Token)
 }
 
+func (b *Box) CreateFolder(parentID uint64, name string) (uint64, error) {
+	return folders.Create(parentID, name, b.token.Token)
+}
+
+func (b *Box) DeleteFolder(folderID uint64) error {
+	return folders.Delete(folderID, false, b.token.Token)
+}
+
+func (b *Box) DeleteFolderTree(folderID uint64) error {
+	return folders.Delete(folderID, true, b.token.Token)
+}
+
 func (b *Box) ListFiles(folderID uint64) ([]files.File, error) {
 	return files.List(folderID, b.token.Token)
 }
 
-func (b *Box) UploadFile(file string, folder string) (string, error) {
-	return files.Upload(file, folder, b.token.Token)
+func (b *Box) GetFile(fileID uint64) (*files.File, error) {
+	return files.Get(fileID, b.token.Token)
+}
+
+func (b *Box) UploadFile(file string, target files.Target, progress func(int64)) (string, error) {
+	return files.Upload(file, target, progress, b.token.Token)
+}
+
+func (b *Box) UploadReader(r io.Reader, target files.Target, progress func(int64)) (string, error) {
+	return files.UploadReader(r, target, progress, b.token.Token)
+}
+
+func (b *Box) PreflightUpload(target files.Target, size int64) error {
+	return files.Preflight(target, size, b.token.Token)
+}
+
+func (b *Box) CreateUploadSession(target files.Target, size int64) (*files.UploadSession, error) {
+	return files.CreateSession(target, size, b.token.Token)
+}
+
+func (b *Box) UploadPart(sessionID string, part []byte, offset int64, size int64) (files.Part, error) {
+	return files.UploadPart(sessionID, part, offset, size, b.token.Token)
+}
+
+func (b *Box) ListUploadedParts(sessionID string) ([]files.Part, error) {
+	return files.ListParts(sessionID, b.token.Token)
+}
+
+func (b *Box) CommitUploadSession(sessionID string, parts []files.Part, modified time.Time, digest []byte) (string, error) {
+	return files.CommitSession(sessionID, parts, modified, digest, b.token.Token)
+}
+
+func (b *Box) AbortUploadSession(sessionID string) error {
+	return files.AbortSession(sessionID, b.token.Token)
+}
+
+func (b *Box) DownloadFile(fileID uint64, version string, offset int64) (io.ReadCloser, int64, error) {
+	return files.Download(fileID, version, offset, b.token.Token)
+}
+
+func (b *Box) FileVersions(fileID uint64) ([]files.Version, error) {
+	return files.Versions(fileID, b.token.Token)
 }
 
 func (b *Box) DeleteFile(fileID string) error {
@@ -63,6 +123,18 @@ func (b *Box) RetagFile(fileID uint64, oldTag, newTag string) error {
 	return files.Retag(fileID, oldTag, newTag, b.token.Token)
 }
 
+func (b *Box) HasMetadata(fileID uint64, key templates.TemplateKey) (bool, error) {
+	return files.HasMetadata(fileID, string(key), b.token.Token)
+}
+
+func (b *Box) StreamPosition() (string, error) {
+	return events.Now(b.token.Token)
+}
+
+func (b *Box) GetEvents(position string) (*events.Events, error) {
+	return events.Get(position, b.token.Token)
+}
+
 func (b *Box) ListTemplates() (map[string]templates.TemplateKey, error) {
 	return templates.List(b.token.Token)
 }
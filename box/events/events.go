@@ -0,0 +1,144 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/twystd/unboxd/log"
+)
+
+// Events is a chunk of the Box user events stream and the stream position from which to
+// retrieve the next chunk.
+type Events struct {
+	Position string
+	Entries  []Event
+}
+
+type Event struct {
+	ID      string
+	Type    string
+	Created time.Time
+	Source  Source
+}
+
+// Source identifies the file or folder affected by an event. The ID is 0 for events that do
+// not apply to a file or folder.
+type Source struct {
+	Type string
+	ID   uint64
+}
+
+// ErrExpired is returned if Box no longer has the events for a stream position.
+var ErrExpired = errors.New("stream position expired")
+
+const fetchSize = 500
+
+// Now returns the current stream position i.e. the stream position from which to retrieve
+// subsequent events.
+func Now(token string) (string, error) {
+	if events, err := get("now", token); err != nil {
+		return "", err
+	} else {
+		return events.Position, nil
+	}
+}
+
+// Get retrieves the events since the stream position.
+func Get(position string, token string) (*Events, error) {
+	return get(position, token)
+}
+
+func get(position string, token string) (*Events, error) {
+	client := http.Client{
+		Timeout: 60 * time.Second,
+	}
+
+	auth := fmt.Sprintf("Bearer %s", token)
+	uri := fmt.Sprintf("https://api.box.com/2.0/events?stream_type=all&stream_position=%v&limit=%v", url.QueryEscape(position), fetchSize)
+
+	rq, _ := http.NewRequest("GET", uri, nil)
+	rq.Header.Set("Authorization", auth)
+	rq.Header.Set("Content-Type", "application/json")
+	rq.Header.Set("Accepts", "application/json")
+
+	response, err := client.Do(rq)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// ... Box rejects stream positions that are too old to replay
+	if (response.StatusCode == http.StatusBadRequest || response.StatusCode == http.StatusGone) && position != "now" {
+		return nil, fmt.Errorf("%v: %w (%v)", position, ErrExpired, response.Status)
+	} else if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error retrieving events (%v)", response.Status)
+	}
+
+	reply := struct {
+		ChunkSize          int             `json:"chunk_size"`
+		NextStreamPosition json.RawMessage `json:"next_stream_position"`
+		Entries            []struct {
+			EventID   string    `json:"event_id"`
+			EventType string    `json:"event_type"`
+			CreatedAt time.Time `json:"created_at"`
+			Source    struct {
+				Type string `json:"type"`
+				ID   string `json:"id"`
+			} `json:"source"`
+		} `json:"entries"`
+	}{}
+
+	if err := json.Unmarshal(body, &reply); err != nil {
+		return nil, err
+	}
+
+	// ... next_stream_position may be either a number or a string
+	next := string(reply.NextStreamPosition)
+	if s, err := strconv.Unquote(next); err == nil {
+		next = s
+	}
+
+	events := Events{
+		Position: next,
+		Entries:  []Event{},
+	}
+
+	for _, e := range reply.Entries {
+		event := Event{
+			ID:      e.EventID,
+			Type:    e.EventType,
+			Created: e.CreatedAt,
+			Source: Source{
+				Type: e.Source.Type,
+			},
+		}
+
+		if e.Source.Type == "file" || e.Source.Type == "folder" {
+			event.Source.ID, _ = strconv.ParseUint(e.Source.ID, 10, 64)
+		}
+
+		events.Entries = append(events.Entries, event)
+	}
+
+	debugf("events", "position:%v  chunk:%-4v next:%v\n", position, reply.ChunkSize, events.Position)
+
+	return &events, nil
+}
+
+func debugf(tag string, format string, args ...any) {
+	f := fmt.Sprintf("%-20v %v", tag, format)
+
+	log.Debugf(f, args...)
+}
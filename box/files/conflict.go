@@ -0,0 +1,159 @@
+package files
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Target is the destination of an upload i.e. a new file in a folder or, if FileID is not blank,
+// a new version of an existing file. ETag is the optional If-Match precondition for a new version
+// and ContentModified, if not zero, is recorded as the file content_modified_at.
+type Target struct {
+	Folder          string
+	Name            string
+	FileID          string
+	ETag            string
+	ContentModified time.Time
+}
+
+// ConflictError is returned when an upload conflicts with an existing item with the same name.
+type ConflictError struct {
+	ID   string
+	Type string
+	Name string
+	ETag string
+	SHA1 string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%v already exists (%v %v)", e.Name, e.Type, e.ID)
+}
+
+// Preflight checks that a file can be uploaded to the target before any content is sent i.e.
+// that the name is valid, the size is allowed and the account has sufficient quota. Returns a
+// ConflictError if the name is already in use. The size is not checked if it is negative.
+func Preflight(target Target, size int64, token string) error {
+	client := http.Client{
+		Timeout: 60 * time.Second,
+	}
+
+	request := struct {
+		Name   string `json:"name,omitempty"`
+		Size   *int64 `json:"size,omitempty"`
+		Parent *struct {
+			ID string `json:"id"`
+		} `json:"parent,omitempty"`
+	}{
+		Name: target.Name,
+	}
+
+	if size >= 0 {
+		request.Size = &size
+	}
+
+	uri := "https://api.box.com/2.0/files/content"
+	if target.FileID != "" {
+		uri = fmt.Sprintf("https://api.box.com/2.0/files/%v/content", target.FileID)
+	} else {
+		request.Parent = &struct {
+			ID string `json:"id"`
+		}{
+			ID: target.Folder,
+		}
+	}
+
+	encoded, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	rq, _ := http.NewRequest("OPTIONS", uri, bytes.NewBuffer(encoded))
+	rq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	rq.Header.Set("Content-Type", "application/json")
+	rq.Header.Set("Accepts", "application/json")
+
+	response, err := client.Do(rq)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	reply, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	switch response.StatusCode {
+	case http.StatusOK:
+		return nil
+
+	case http.StatusConflict:
+		return conflict(reply, target.Name)
+
+	default:
+		return fmt.Errorf("%v: upload preflight check failed (%v)", target.Name, reason(reply, response.Status))
+	}
+}
+
+// conflict returns a ConflictError with the conflicting item from a 409 Conflict response body.
+func conflict(reply []byte, name string) error {
+	body := struct {
+		Code    string `json:"code"`
+		Context struct {
+			Conflicts json.RawMessage `json:"conflicts"`
+		} `json:"context_info"`
+	}{}
+
+	type item struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+		Name string `json:"name"`
+		ETag string `json:"etag"`
+		SHA1 string `json:"sha1"`
+	}
+
+	if err := json.Unmarshal(reply, &body); err != nil {
+		return fmt.Errorf("%v: upload conflict (%v)", name, err)
+	}
+
+	// ... conflicts is either a single item or a list of items
+	conflicts := []item{}
+	var single item
+	if err := json.Unmarshal(body.Context.Conflicts, &single); err == nil && single.ID != "" {
+		conflicts = append(conflicts, single)
+	} else if err := json.Unmarshal(body.Context.Conflicts, &conflicts); err != nil || len(conflicts) == 0 {
+		return fmt.Errorf("%v: upload conflict (%v)", name, body.Code)
+	}
+
+	c := conflicts[0]
+	if c.Name == "" {
+		c.Name = name
+	}
+
+	return &ConflictError{
+		ID:   c.ID,
+		Type: c.Type,
+		Name: c.Name,
+		ETag: c.ETag,
+		SHA1: c.SHA1,
+	}
+}
+
+// reason returns the message from a Box error response body, or the response status if the body
+// does not have a message.
+func reason(reply []byte, status string) string {
+	body := struct {
+		Message string `json:"message"`
+	}{}
+
+	if err := json.Unmarshal(reply, &body); err == nil && body.Message != "" {
+		return fmt.Sprintf("%v: %v", status, body.Message)
+	}
+
+	return status
+}
@@ -0,0 +1,133 @@
+package files
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Version is a previous version of a file.
+type Version struct {
+	ID         string
+	Name       string
+	Size       uint64
+	SHA1       string
+	ModifiedAt time.Time
+
+	// ContentModifiedAt is the modification time of the version content, as set by the uploader
+	ContentModifiedAt time.Time
+}
+
+// Download opens the content of a file (or of a previous version of the file if version is not
+// blank) for reading from offset, using an HTTP Range request. Returns the content and the offset
+// from which the content starts, which is 0 if the content is not a partial response.
+//
+// The caller is responsible for closing the content.
+func Download(fileID uint64, version string, offset int64, token string) (io.ReadCloser, int64, error) {
+	// ... no overall timeout because the content is streamed
+	client := http.Client{
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			ResponseHeaderTimeout: 60 * time.Second,
+		},
+	}
+
+	auth := fmt.Sprintf("Bearer %s", token)
+	uri := fmt.Sprintf("https://api.box.com/2.0/files/%v/content", fileID)
+	if version != "" {
+		uri = fmt.Sprintf("https://api.box.com/2.0/files/%v/content?version=%v", fileID, version)
+	}
+
+	rq, _ := http.NewRequest("GET", uri, nil)
+	rq.Header.Set("Authorization", auth)
+	if offset > 0 {
+		rq.Header.Set("Range", fmt.Sprintf("bytes=%v-", offset))
+	}
+
+	response, err := client.Do(rq)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch response.StatusCode {
+	case http.StatusOK:
+		return response.Body, 0, nil
+
+	case http.StatusPartialContent:
+		return response.Body, offset, nil
+
+	case http.StatusNotFound:
+		response.Body.Close()
+		return nil, 0, fmt.Errorf("%v: file %w", fileID, ErrNotFound)
+
+	default:
+		response.Body.Close()
+		return nil, 0, fmt.Errorf("%v: error downloading file (%v)", fileID, response.Status)
+	}
+}
+
+// Versions retrieves the previous versions of a file (the current version is not included).
+func Versions(fileID uint64, token string) ([]Version, error) {
+	client := http.Client{
+		Timeout: 60 * time.Second,
+	}
+
+	auth := fmt.Sprintf("Bearer %s", token)
+	uri := fmt.Sprintf("https://api.box.com/2.0/files/%v/versions?fields=id,name,size,sha1,modified_at,content_modified_at&limit=1000", fileID)
+
+	rq, _ := http.NewRequest("GET", uri, nil)
+	rq.Header.Set("Authorization", auth)
+	rq.Header.Set("Content-Type", "application/json")
+	rq.Header.Set("Accepts", "application/json")
+
+	response, err := client.Do(rq)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%v: file %w", fileID, ErrNotFound)
+	} else if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v: error retrieving file versions (%v)", fileID, response.Status)
+	}
+
+	reply := struct {
+		Entries []struct {
+			ID         string    `json:"id"`
+			Name       string    `json:"name"`
+			Size       uint64    `json:"size"`
+			SHA1       string    `json:"sha1"`
+			ModifiedAt time.Time `json:"modified_at"`
+			// ... not returned for all versions
+			ContentModifiedAt time.Time `json:"content_modified_at"`
+		} `json:"entries"`
+	}{}
+
+	if err := json.Unmarshal(body, &reply); err != nil {
+		return nil, err
+	}
+
+	versions := []Version{}
+	for _, e := range reply.Entries {
+		versions = append(versions, Version{
+			ID:         e.ID,
+			Name:       e.Name,
+			Size:       e.Size,
+			SHA1:       e.SHA1,
+			ModifiedAt: e.ModifiedAt,
+
+			ContentModifiedAt: e.ContentModifiedAt,
+		})
+	}
+
+	return versions, nil
+}
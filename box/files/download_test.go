@@ -0,0 +1,47 @@
+package files
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVersions(t *testing.T) {
+	modified := time.Date(2023, time.June, 1, 12, 30, 0, 0, time.UTC)
+	content := time.Date(2023, time.May, 28, 9, 15, 0, 0, time.UTC)
+
+	stub(t, func(rq *http.Request) (*http.Response, error) {
+		if rq.URL.Path != "/2.0/files/1189165332/versions" {
+			return reply(http.StatusNotFound, nil), nil
+		}
+
+		if fields := rq.URL.Query().Get("fields"); !strings.Contains(fields, "content_modified_at") {
+			t.Errorf("Missing content_modified_at field - got:%v", fields)
+		}
+
+		return reply(http.StatusOK, map[string]any{
+			"entries": []map[string]any{
+				{"id": "101", "name": "report.pdf", "size": 1024, "sha1": "qwerty", "modified_at": modified, "content_modified_at": content},
+				{"id": "100", "name": "report.pdf", "size": 512, "sha1": "uiop", "modified_at": modified},
+			},
+		}), nil
+	})
+
+	versions, err := Versions(1189165332, "token")
+	if err != nil {
+		t.Fatalf("Error retrieving versions (%v)", err)
+	}
+
+	if len(versions) != 2 {
+		t.Fatalf("Incorrect number of versions - expected:%v, got:%v", 2, len(versions))
+	}
+
+	if v := versions[0]; v.ID != "101" || !v.ModifiedAt.Equal(modified) || !v.ContentModifiedAt.Equal(content) {
+		t.Errorf("Incorrect version - expected:%v, got:%+v", "101", v)
+	}
+
+	if v := versions[1]; v.ID != "100" || !v.ContentModifiedAt.IsZero() {
+		t.Errorf("Incorrect version - expected:%v, got:%+v", "100", v)
+	}
+}
@@ -3,22 +3,44 @@ package files
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/twystd/unboxd/log"
 )
 
 type File struct {
-	ID   uint64
-	Name string
-	Tags []string
+	ID         uint64
+	Parent     uint64
+	Name       string
+	Path       string
+	Tags       []string
+	Size       uint64
+	SHA1       string
+	Version    string
+	CreatedAt  time.Time
+	ModifiedAt time.Time
+
+	// ContentModifiedAt is the modification time of the file content, as set by the uploader
+	ContentModifiedAt time.Time
 }
 
+var ErrNotFound = errors.New("not found")
+
 const fetchSize = 500
+const fields = "id,type,name,size,sha1,tags,created_at,modified_at,content_modified_at"
+
+// Get retrieves the file information, including the parent folder ID, the absolute path
+// constructed from the file path_collection and the current file version ID. Returns an error
+// wrapping ErrNotFound if the file does not exist (or is in the trash).
+func Get(fileID uint64, token string) (*File, error) {
+	return get(fileID, token)
+}
 
 func get(fileID uint64, token string) (*File, error) {
 	client := http.Client{
@@ -26,7 +48,7 @@ func get(fileID uint64, token string) (*File, error) {
 	}
 
 	auth := fmt.Sprintf("Bearer %s", token)
-	uri := fmt.Sprintf("https://api.box.com/2.0/files/%[1]v?fields=id,type,name,sha1,tags", fileID)
+	uri := fmt.Sprintf("https://api.box.com/2.0/files/%[1]v?fields=%[2]v,path_collection,file_version", fileID, fields)
 
 	rq, _ := http.NewRequest("GET", uri, nil)
 	rq.Header.Set("Authorization", auth)
@@ -45,28 +67,62 @@ func get(fileID uint64, token string) (*File, error) {
 		return nil, err
 	}
 
-	if response.StatusCode != http.StatusOK {
+	if response.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%v: file %w", fileID, ErrNotFound)
+	} else if response.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("%v: error retrieving file information (%v)", fileID, response.Status)
 	}
 
 	reply := struct {
-		Type string   `json:"type"`
-		ID   string   `json:"id"`
-		Name string   `json:"name"`
-		Tags []string `json:"tags"`
+		Type              string    `json:"type"`
+		ID                string    `json:"id"`
+		Name              string    `json:"name"`
+		Tags              []string  `json:"tags"`
+		Size              uint64    `json:"size"`
+		SHA1              string    `json:"sha1"`
+		CreatedAt         time.Time `json:"created_at"`
+		ModifiedAt        time.Time `json:"modified_at"`
+		ContentModifiedAt time.Time `json:"content_modified_at"`
+		PathCollection    struct {
+			Entries []struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"entries"`
+		} `json:"path_collection"`
+		FileVersion struct {
+			ID string `json:"id"`
+		} `json:"file_version"`
 	}{}
 
 	if err := json.Unmarshal(body, &reply); err != nil {
 		return nil, err
 	}
 
+	segments := []string{}
+	parent := uint64(0)
+	for _, e := range reply.PathCollection.Entries {
+		if e.ID != "0" {
+			segments = append(segments, e.Name)
+		}
+
+		parent, _ = strconv.ParseUint(e.ID, 10, 64)
+	}
+
 	if id, err := strconv.ParseUint(reply.ID, 10, 64); err != nil {
 		return nil, err
 	} else {
 		return &File{
-			ID:   id,
-			Name: reply.Name,
-			Tags: reply.Tags,
+			ID:                id,
+			Parent:            parent,
+			Name:              reply.Name,
+			Path:              "/" + strings.Join(append(segments, reply.Name), "/"),
+			Tags:              reply.Tags,
+			Size:              reply.Size,
+			SHA1:              reply.SHA1,
+			Version:           reply.FileVersion.ID,
+			CreatedAt:         reply.CreatedAt,
+			ModifiedAt:        reply.ModifiedAt,
+			ContentModifiedAt: reply.ContentModifiedAt,
 		}, nil
 	}
 }
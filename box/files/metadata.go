@@ -0,0 +1,45 @@
+package files
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HasMetadata returns true if the file has an instance of the enterprise metadata template.
+func HasMetadata(fileID uint64, template string, token string) (bool, error) {
+	client := http.Client{
+		Timeout: 60 * time.Second,
+	}
+
+	auth := fmt.Sprintf("Bearer %s", token)
+	uri := fmt.Sprintf("https://api.box.com/2.0/files/%v/metadata/enterprise/%v", fileID, template)
+
+	rq, _ := http.NewRequest("GET", uri, nil)
+	rq.Header.Set("Authorization", auth)
+	rq.Header.Set("Content-Type", "application/json")
+	rq.Header.Set("Accepts", "application/json")
+
+	response, err := client.Do(rq)
+	if err != nil {
+		return false, err
+	}
+
+	defer response.Body.Close()
+
+	if _, err := io.ReadAll(response.Body); err != nil {
+		return false, err
+	}
+
+	switch response.StatusCode {
+	case http.StatusOK:
+		return true, nil
+
+	case http.StatusNotFound:
+		return false, nil
+
+	default:
+		return false, fmt.Errorf("%v: error retrieving file metadata (%v)", fileID, response.Status)
+	}
+}
@@ -0,0 +1,280 @@
+package files
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// MaxUploadSize is the largest file that can be uploaded in a single request. Larger files must
+// be uploaded in parts using an upload session.
+const MaxUploadSize = 50 * 1024 * 1024
+
+// MinSessionSize is the smallest file that can be uploaded using an upload session.
+const MinSessionSize = 20 * 1024 * 1024
+
+// UploadSession is a Box chunked upload session. The file is uploaded in parts of PartSize bytes
+// (except for the last part) and then committed.
+type UploadSession struct {
+	ID         string    `json:"id"`
+	PartSize   int64     `json:"part_size"`
+	TotalParts int       `json:"total_parts"`
+	Expires    time.Time `json:"session_expires_at"`
+}
+
+// Part is an uploaded part of an upload session.
+type Part struct {
+	PartID string `json:"part_id"`
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+	SHA1   string `json:"sha1"`
+}
+
+const sessions = "https://upload.box.com/api/2.0/files/upload_sessions"
+
+// partsFetchSize is the number of parts retrieved per request by ListParts.
+const partsFetchSize = 1000
+
+// CreateSession creates an upload session for a new file in the target folder or, if the target
+// has a file ID, for a new version of the file.
+func CreateSession(target Target, size int64, token string) (*UploadSession, error) {
+	request := struct {
+		FolderID string `json:"folder_id,omitempty"`
+		FileSize int64  `json:"file_size"`
+		FileName string `json:"file_name,omitempty"`
+	}{
+		FolderID: target.Folder,
+		FileSize: size,
+		FileName: target.Name,
+	}
+
+	uri := sessions
+	if target.FileID != "" {
+		uri = fmt.Sprintf("https://upload.box.com/api/2.0/files/%v/upload_sessions", target.FileID)
+		request.FolderID = ""
+	}
+
+	encoded, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	rq, _ := http.NewRequest("POST", uri, bytes.NewBuffer(encoded))
+	rq.Header.Set("Content-Type", "application/json")
+
+	reply, err := session(rq, http.StatusCreated, token)
+	if err != nil {
+		return nil, fmt.Errorf("error creating upload session (%w)", err)
+	}
+
+	s := UploadSession{}
+	if err := json.Unmarshal(reply, &s); err != nil {
+		return nil, err
+	} else if s.ID == "" || s.PartSize <= 0 {
+		return nil, fmt.Errorf("invalid upload session (%s)", reply)
+	}
+
+	return &s, nil
+}
+
+// UploadPart uploads a part of the file at offset. The part SHA1 digest is verified by Box.
+func UploadPart(sessionID string, part []byte, offset int64, size int64, token string) (Part, error) {
+	digest := sha1.Sum(part)
+
+	rq, _ := http.NewRequest("PUT", sessions+"/"+sessionID, bytes.NewReader(part))
+	rq.ContentLength = int64(len(part))
+	rq.Header.Set("Content-Type", "application/octet-stream")
+	rq.Header.Set("Content-Range", fmt.Sprintf("bytes %v-%v/%v", offset, offset+int64(len(part))-1, size))
+	rq.Header.Set("Digest", "sha="+base64.StdEncoding.EncodeToString(digest[:]))
+
+	reply, err := session(rq, http.StatusOK, token)
+	if err != nil {
+		return Part{}, fmt.Errorf("error uploading part at offset %v (%w)", offset, err)
+	}
+
+	response := struct {
+		Part Part `json:"part"`
+	}{}
+
+	if err := json.Unmarshal(reply, &response); err != nil {
+		return Part{}, err
+	}
+
+	return response.Part, nil
+}
+
+// ListParts retrieves the parts that have been uploaded to an upload session, following the
+// offset (or marker) paging until all the parts have been retrieved.
+func ListParts(sessionID string, token string) ([]Part, error) {
+	parts := []Part{}
+	uri := fmt.Sprintf("%v/%v/parts?limit=%v", sessions, sessionID, partsFetchSize)
+
+	for {
+		rq, _ := http.NewRequest("GET", uri, nil)
+
+		reply, err := session(rq, http.StatusOK, token)
+		if err != nil {
+			return nil, fmt.Errorf("error listing upload session parts (%w)", err)
+		}
+
+		response := struct {
+			Entries    []Part `json:"entries"`
+			Offset     int    `json:"offset"`
+			TotalCount int    `json:"total_count"`
+			NextMarker string `json:"next_marker,omitempty"`
+		}{}
+
+		if err := json.Unmarshal(reply, &response); err != nil {
+			return nil, err
+		}
+
+		parts = append(parts, response.Entries...)
+
+		switch {
+		case response.NextMarker != "":
+			uri = fmt.Sprintf("%v/%v/parts?limit=%v&marker=%v", sessions, sessionID, partsFetchSize, url.QueryEscape(response.NextMarker))
+
+		case len(response.Entries) > 0 && response.Offset+len(response.Entries) < response.TotalCount:
+			uri = fmt.Sprintf("%v/%v/parts?limit=%v&offset=%v", sessions, sessionID, partsFetchSize, response.Offset+len(response.Entries))
+
+		default:
+			return parts, nil
+		}
+	}
+}
+
+// CommitSession commits an upload session, creating the file. The SHA1 is the SHA1 digest of the
+// whole file and the modification time, if not zero, is recorded as the file content_modified_at.
+// Box may respond with 202 Accepted while it is still processing the parts, in which case the
+// commit is retried after the Retry-After interval.
+func CommitSession(sessionID string, parts []Part, modified time.Time, digest []byte, token string) (string, error) {
+	type attributes struct {
+		ContentModifiedAt string `json:"content_modified_at"`
+	}
+
+	request := struct {
+		Parts      []Part      `json:"parts"`
+		Attributes *attributes `json:"attributes,omitempty"`
+	}{
+		Parts: parts,
+	}
+
+	if !modified.IsZero() {
+		request.Attributes = &attributes{
+			ContentModifiedAt: modified.Format(time.RFC3339),
+		}
+	}
+
+	encoded, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	client := http.Client{
+		Timeout: 60 * time.Second,
+	}
+
+	for attempt := 0; attempt < 10; attempt++ {
+		rq, _ := http.NewRequest("POST", sessions+"/"+sessionID+"/commit", bytes.NewBuffer(encoded))
+		rq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		rq.Header.Set("Content-Type", "application/json")
+		rq.Header.Set("Accepts", "application/json")
+		rq.Header.Set("Digest", "sha="+base64.StdEncoding.EncodeToString(digest))
+
+		response, err := client.Do(rq)
+		if err != nil {
+			return "", err
+		}
+
+		reply, err := io.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			return "", err
+		}
+
+		switch response.StatusCode {
+		case http.StatusCreated:
+			info := struct {
+				Entries []struct {
+					ID string `json:"id"`
+				} `json:"entries"`
+			}{}
+
+			if err := json.Unmarshal(reply, &info); err != nil {
+				return "", err
+			} else if len(info.Entries) != 1 {
+				return "", fmt.Errorf("invalid response - entries:%v", len(info.Entries))
+			}
+
+			return info.Entries[0].ID, nil
+
+		case http.StatusAccepted:
+			delay := 5 * time.Second
+			if v, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil && v > 0 {
+				delay = time.Duration(v) * time.Second
+			}
+
+			debugf("upload", "commit of upload session %v accepted - retrying in %v", sessionID, delay)
+			time.Sleep(delay)
+
+		case http.StatusConflict:
+			return "", conflict(reply, "")
+
+		default:
+			return "", fmt.Errorf("error committing upload session (%v)", response.Status)
+		}
+	}
+
+	return "", fmt.Errorf("upload session %v was not committed", sessionID)
+}
+
+// AbortSession aborts an upload session, discarding the uploaded parts.
+func AbortSession(sessionID string, token string) error {
+	rq, _ := http.NewRequest("DELETE", sessions+"/"+sessionID, nil)
+
+	if _, err := session(rq, http.StatusNoContent, token); err != nil {
+		return fmt.Errorf("error aborting upload session (%w)", err)
+	}
+
+	return nil
+}
+
+// session executes an upload session request, returning the response body if the response
+// status is the expected status.
+func session(rq *http.Request, expected int, token string) ([]byte, error) {
+	client := http.Client{
+		Timeout: 300 * time.Second,
+	}
+
+	rq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	rq.Header.Set("Accepts", "application/json")
+
+	response, err := client.Do(rq)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	reply, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	} else if response.StatusCode == http.StatusConflict {
+		return nil, conflict(reply, "")
+	} else if response.StatusCode != expected {
+		return nil, fmt.Errorf("%v", response.Status)
+	}
+
+	return reply, nil
+}
@@ -0,0 +1,162 @@
+package files
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type roundTripper func(rq *http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(rq *http.Request) (*http.Response, error) {
+	return f(rq)
+}
+
+// stub replaces the default HTTP transport for the duration of a test.
+func stub(t *testing.T, f roundTripper) {
+	transport := http.DefaultTransport
+	http.DefaultTransport = f
+
+	t.Cleanup(func() { http.DefaultTransport = transport })
+}
+
+func reply(status int, body any) *http.Response {
+	bytes, _ := json.Marshal(body)
+
+	return &http.Response{
+		StatusCode: status,
+		Status:     fmt.Sprintf("%v %v", status, http.StatusText(status)),
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(string(bytes))),
+	}
+}
+
+func TestListPartsOffsetPaging(t *testing.T) {
+	const total = 2500
+
+	requests := 0
+	stub(t, func(rq *http.Request) (*http.Response, error) {
+		requests++
+
+		offset, _ := strconv.Atoi(rq.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(rq.URL.Query().Get("limit"))
+
+		if rq.URL.Path != "/api/2.0/files/upload_sessions/F971964745A5CD0C001BBE4E58196BFD/parts" {
+			return reply(http.StatusNotFound, nil), nil
+		}
+
+		entries := []Part{}
+		for i := offset; i < offset+limit && i < total; i++ {
+			entries = append(entries, Part{PartID: fmt.Sprintf("%08X", i), Offset: int64(i) * 8388608, Size: 8388608})
+		}
+
+		return reply(http.StatusOK, map[string]any{
+			"entries":     entries,
+			"limit":       limit,
+			"offset":      offset,
+			"total_count": total,
+		}), nil
+	})
+
+	parts, err := ListParts("F971964745A5CD0C001BBE4E58196BFD", "token")
+	if err != nil {
+		t.Fatalf("Error listing parts (%v)", err)
+	}
+
+	if len(parts) != total {
+		t.Errorf("Incorrect number of parts - expected:%v, got:%v", total, len(parts))
+	}
+
+	if requests != 3 {
+		t.Errorf("Incorrect number of requests - expected:%v, got:%v", 3, requests)
+	}
+
+	for i, p := range parts {
+		if p.PartID != fmt.Sprintf("%08X", i) {
+			t.Fatalf("Incorrect part %v - expected:%08X, got:%v", i, i, p.PartID)
+		}
+	}
+}
+
+func TestListPartsMarkerPaging(t *testing.T) {
+	pages := map[string]map[string]any{
+		"": {
+			"entries":     []Part{{PartID: "0001", Offset: 0, Size: 8388608}},
+			"next_marker": "page2",
+		},
+		"page2": {
+			"entries":     []Part{{PartID: "0002", Offset: 8388608, Size: 8388608}},
+			"next_marker": "page3",
+		},
+		"page3": {
+			"entries": []Part{{PartID: "0003", Offset: 16777216, Size: 1024}},
+		},
+	}
+
+	stub(t, func(rq *http.Request) (*http.Response, error) {
+		if page, ok := pages[rq.URL.Query().Get("marker")]; ok {
+			return reply(http.StatusOK, page), nil
+		}
+
+		return reply(http.StatusBadRequest, nil), nil
+	})
+
+	parts, err := ListParts("F971964745A5CD0C001BBE4E58196BFD", "token")
+	if err != nil {
+		t.Fatalf("Error listing parts (%v)", err)
+	}
+
+	if len(parts) != 3 || parts[0].PartID != "0001" || parts[2].PartID != "0003" {
+		t.Errorf("Incorrect parts %+v", parts)
+	}
+}
+
+func TestListPartsEmpty(t *testing.T) {
+	stub(t, func(rq *http.Request) (*http.Response, error) {
+		return reply(http.StatusOK, map[string]any{"entries": []Part{}, "offset": 0, "total_count": 0}), nil
+	})
+
+	if parts, err := ListParts("F971964745A5CD0C001BBE4E58196BFD", "token"); err != nil {
+		t.Fatalf("Error listing parts (%v)", err)
+	} else if len(parts) != 0 {
+		t.Errorf("Expected no parts, got %+v", parts)
+	}
+}
+
+func TestCommitSessionAttributes(t *testing.T) {
+	modified := time.Date(2023, time.June, 1, 12, 30, 15, 0, time.UTC)
+
+	tests := []struct {
+		modified time.Time
+		expected string
+	}{
+		{time.Time{}, `{"parts":[{"part_id":"0001","offset":0,"size":1024,"sha1":"qwerty"}]}`},
+		{modified, `{"parts":[{"part_id":"0001","offset":0,"size":1024,"sha1":"qwerty"}],"attributes":{"content_modified_at":"2023-06-01T12:30:15Z"}}`},
+	}
+
+	for _, v := range tests {
+		body := ""
+		stub(t, func(rq *http.Request) (*http.Response, error) {
+			bytes, _ := io.ReadAll(rq.Body)
+			body = string(bytes)
+
+			return reply(http.StatusCreated, map[string]any{"entries": []map[string]any{{"id": "12345"}}}), nil
+		})
+
+		parts := []Part{{PartID: "0001", Offset: 0, Size: 1024, SHA1: "qwerty"}}
+		if fileID, err := CommitSession("F971964745A5CD0C001BBE4E58196BFD", parts, v.modified, []byte("sha1"), "token"); err != nil {
+			t.Fatalf("Error committing session (%v)", err)
+		} else if fileID != "12345" {
+			t.Errorf("Incorrect file ID - expected:%v, got:%v", "12345", fileID)
+		}
+
+		if body != v.expected {
+			t.Errorf("Incorrect commit request\n   expected:%v\n   got:     %v", v.expected, body)
+		}
+	}
+}
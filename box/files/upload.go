@@ -2,68 +2,128 @@ package files
 
 import (
 	"bytes"
+	"crypto/sha1"
 	"encoding/json"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 )
 
-func Upload(file string, folder string, token string) (string, error) {
-	client := http.Client{
-		Timeout: 60 * time.Second,
-	}
-
-	filename := filepath.Base(file)
-	attributes := struct {
-		Name   string `json:"name"`
-		Parent struct {
-			ID string `json:"id"`
-		} `json:"parent"`
-	}{
-		Name: filename,
-		Parent: struct {
-			ID string `json:"id"`
-		}{
-			ID: folder,
-		},
+// Upload uploads a file to the target folder (as the target name if it is not blank) or as a
+// new version of the target file. The multipart request body is streamed from the file with a
+// known Content-Length and the SHA1 digest of the file is sent in the Content-MD5 header so that
+// Box verifies the content. The optional progress function is invoked with the number of bytes
+// sent as the request body is uploaded.
+//
+// Returns a ConflictError if the target folder already has an item with the same name.
+func Upload(file string, target Target, progress func(int64), token string) (string, error) {
+	if target.Name == "" {
+		target.Name = filepath.Base(file)
 	}
 
-	a, err := json.Marshal(attributes)
+	r, err := os.Open(file)
 	if err != nil {
 		return "", err
 	}
 
-	r, err := os.Open(file)
+	defer r.Close()
+
+	info, err := r.Stat()
 	if err != nil {
 		return "", err
-	} else {
-		defer r.Close()
 	}
 
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-	if err = writer.WriteField("attributes", string(a)); err != nil {
+	digest := sha1.New()
+	if _, err := io.Copy(digest, r); err != nil {
+		return "", err
+	} else if _, err := r.Seek(0, io.SeekStart); err != nil {
 		return "", err
 	}
 
-	if part, err := writer.CreateFormFile("file", filename); err != nil {
-		return "", err
-	} else if _, err = io.Copy(part, r); err != nil {
-		return "", err
+	return upload(r, target, info.Size(), fmt.Sprintf("%x", digest.Sum(nil)), progress, token)
+}
+
+// UploadReader uploads the content of a reader (e.g. stdin) to the target. The size of the
+// content is not known in advance so the multipart request body is streamed through a pipe and
+// the SHA1 digest is verified against the SHA1 of the uploaded file. If the SHA1 digests do not
+// match the uploaded file is deleted (or, for a new version, the previous version is restored).
+func UploadReader(r io.Reader, target Target, progress func(int64), token string) (string, error) {
+	return upload(r, target, -1, "", progress, token)
+}
+
+func upload(r io.Reader, target Target, size int64, digest string, progress func(int64), token string) (string, error) {
+	// ... no overall timeout because the content is streamed
+	client := http.Client{
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			ResponseHeaderTimeout: 60 * time.Second,
+		},
 	}
 
-	if err = writer.Close(); err != nil {
+	name := target.Name
+	uri := "https://upload.box.com/api/2.0/files/content"
+	if target.FileID != "" {
+		uri = fmt.Sprintf("https://upload.box.com/api/2.0/files/%v/content", target.FileID)
+	}
+
+	a, err := attributes(target)
+	if err != nil {
 		return "", err
 	}
 
-	rq, _ := http.NewRequest("POST", "https://upload.box.com/api/2.0/files/content", body)
+	hash := sha1.New()
+	content := io.TeeReader(r, hash)
+
+	var body io.Reader
+	var length int64 = -1
+	var contentType string
+
+	if size >= 0 {
+		prefix, suffix, boundary, err := envelope(string(a), name)
+		if err != nil {
+			return "", err
+		}
+
+		body = io.MultiReader(bytes.NewReader(prefix), io.LimitReader(content, size), bytes.NewReader(suffix))
+		length = int64(len(prefix)) + size + int64(len(suffix))
+		contentType = boundary
+	} else {
+		pr, pw := io.Pipe()
+		writer := multipart.NewWriter(pw)
+
+		go func() {
+			pw.CloseWithError(multipartBody(writer, string(a), name, content))
+		}()
+
+		defer pr.Close()
+
+		body = pr
+		contentType = writer.FormDataContentType()
+	}
+
+	if progress != nil {
+		body = &counter{reader: body, progress: progress}
+	}
+
+	rq, _ := http.NewRequest("POST", uri, body)
+	rq.ContentLength = length
 	rq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 	rq.Header.Set("Accepts", "application/json")
-	rq.Header.Set("Content-Type", writer.FormDataContentType())
+	rq.Header.Set("Content-Type", contentType)
+	if digest != "" {
+		rq.Header.Set("Content-MD5", digest)
+	}
+
+	if target.FileID != "" && target.ETag != "" {
+		rq.Header.Set("If-Match", target.ETag)
+	}
 
 	response, err := client.Do(rq)
 	if err != nil {
@@ -77,28 +137,250 @@ func Upload(file string, folder string, token string) (string, error) {
 		return "", err
 	}
 
-	// ... ok - acknowledge with Box file ID
-	if response.StatusCode == http.StatusCreated {
-		info := struct {
-			TotalCount int `json:"total_count"`
-			Entries    []struct {
-				ID   string `json:"id"`
-				Name string `json:"name"`
-				Size uint64 `json:"size"`
-				SHA1 string `json:"sha1"`
-			} `json:"entries"`
-		}{}
+	switch response.StatusCode {
+	case http.StatusCreated:
+		entry, err := uploaded(reply)
+		if err != nil {
+			return "", err
+		}
+
+		if sent := fmt.Sprintf("%x", hash.Sum(nil)); entry.SHA1 != "" && !strings.EqualFold(entry.SHA1, sent) {
+			err := fmt.Errorf("%v: SHA1 mismatch (sent %v, uploaded %v)", entry.ID, sent, entry.SHA1)
+			if errx := rollback(target, entry.ID, entry.Version.ID, token); errx != nil {
+				return "", fmt.Errorf("%w - error removing uploaded content (%v)", err, errx)
+			}
 
-		if err := json.Unmarshal(reply, &info); err != nil {
 			return "", err
-		} else if info.TotalCount != 1 {
-			return "", fmt.Errorf("invalid response - total count:%v", info.TotalCount)
-		} else if len(info.Entries) != 1 {
-			return "", fmt.Errorf("invalid response - entries:%v", len(info.Entries))
 		}
 
-		return info.Entries[0].ID, nil
+		return entry.ID, nil
+
+	case http.StatusConflict:
+		return "", conflict(reply, name)
+
+	case http.StatusPreconditionFailed:
+		return "", fmt.Errorf("upload request failed (%v)", reason(reply, response.Status))
+
+	default:
+		return "", fmt.Errorf("upload request failed (%s)", response.Status)
+	}
+}
+
+type entry struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Size    uint64 `json:"size"`
+	SHA1    string `json:"sha1"`
+	Version struct {
+		ID string `json:"id"`
+	} `json:"file_version"`
+}
+
+// uploaded returns the uploaded file from the upload response.
+func uploaded(reply []byte) (entry, error) {
+	info := struct {
+		TotalCount int     `json:"total_count"`
+		Entries    []entry `json:"entries"`
+	}{}
+
+	if err := json.Unmarshal(reply, &info); err != nil {
+		return entry{}, err
+	} else if info.TotalCount != 1 {
+		return entry{}, fmt.Errorf("invalid response - total count:%v", info.TotalCount)
+	} else if len(info.Entries) != 1 {
+		return entry{}, fmt.Errorf("invalid response - entries:%v", len(info.Entries))
+	}
+
+	return info.Entries[0], nil
+}
+
+// rollback removes an uploaded file that failed verification. A new file is deleted and a new
+// version is replaced by promoting the previous version, after which the uploaded version is
+// deleted.
+func rollback(target Target, fileID string, version string, token string) error {
+	if target.FileID == "" {
+		return Delete(fileID, token)
+	}
+
+	id, err := strconv.ParseUint(fileID, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	versions, err := Versions(id, token)
+	if err != nil {
+		return err
+	}
+
+	var previous *Version
+	for i, v := range versions {
+		if v.ID != version && (previous == nil || v.ModifiedAt.After(previous.ModifiedAt)) {
+			previous = &versions[i]
+		}
+	}
+
+	if previous == nil {
+		return fmt.Errorf("%v: no previous version", fileID)
+	} else if err := promote(fileID, previous.ID, token); err != nil {
+		return err
+	} else if version != "" {
+		return deleteVersion(fileID, version, token)
+	}
+
+	return nil
+}
+
+// promote makes a previous version of a file the current version.
+func promote(fileID string, version string, token string) error {
+	request := struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+	}{
+		Type: "file_version",
+		ID:   version,
+	}
+
+	encoded, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	uri := fmt.Sprintf("https://api.box.com/2.0/files/%v/versions/current", fileID)
+	rq, _ := http.NewRequest("POST", uri, bytes.NewBuffer(encoded))
+
+	if err := versions(rq, http.StatusCreated, token); err != nil {
+		return fmt.Errorf("%v: error promoting version %v (%w)", fileID, version, err)
+	}
+
+	return nil
+}
+
+// deleteVersion moves a version of a file to the trash.
+func deleteVersion(fileID string, version string, token string) error {
+	uri := fmt.Sprintf("https://api.box.com/2.0/files/%v/versions/%v", fileID, version)
+	rq, _ := http.NewRequest("DELETE", uri, nil)
+
+	if err := versions(rq, http.StatusNoContent, token); err != nil {
+		return fmt.Errorf("%v: error deleting version %v (%w)", fileID, version, err)
+	}
+
+	return nil
+}
+
+// versions executes a file versions request, returning an error if the response status is not
+// the expected status.
+func versions(rq *http.Request, expected int, token string) error {
+	client := http.Client{
+		Timeout: 60 * time.Second,
+	}
+
+	rq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	rq.Header.Set("Content-Type", "application/json")
+	rq.Header.Set("Accepts", "application/json")
+
+	response, err := client.Do(rq)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if _, err := io.ReadAll(response.Body); err != nil {
+		return err
+	} else if response.StatusCode != expected {
+		return fmt.Errorf("%v", response.Status)
+	}
+
+	return nil
+}
+
+// attributes returns the JSON encoded upload attributes for the target i.e. the name, the parent
+// folder for a new file and the content modification time, if known.
+func attributes(target Target) ([]byte, error) {
+	attributes := struct {
+		Name   string `json:"name,omitempty"`
+		Parent *struct {
+			ID string `json:"id"`
+		} `json:"parent,omitempty"`
+		ContentModifiedAt string `json:"content_modified_at,omitempty"`
+	}{
+		Name: target.Name,
+	}
+
+	if target.FileID == "" {
+		attributes.Parent = &struct {
+			ID string `json:"id"`
+		}{
+			ID: target.Folder,
+		}
+	}
+
+	if !target.ContentModified.IsZero() {
+		attributes.ContentModifiedAt = target.ContentModified.Format(time.RFC3339)
+	}
+
+	return json.Marshal(attributes)
+}
+
+// envelope returns the multipart body before and after the file content, along with the
+// Content-Type (with boundary), so that the body can be streamed with a known Content-Length.
+func envelope(attributes string, name string) ([]byte, []byte, string, error) {
+	var b bytes.Buffer
+
+	writer := multipart.NewWriter(&b)
+	if err := writer.WriteField("attributes", attributes); err != nil {
+		return nil, nil, "", err
+	} else if _, err := writer.CreatePart(fileHeader(name)); err != nil {
+		return nil, nil, "", err
+	}
+
+	prefix := append([]byte{}, b.Bytes()...)
+
+	b.Reset()
+	if err := writer.Close(); err != nil {
+		return nil, nil, "", err
+	}
+
+	suffix := append([]byte{}, b.Bytes()...)
+
+	return prefix, suffix, writer.FormDataContentType(), nil
+}
+
+// multipartBody writes the attributes and the file content as a multipart body.
+func multipartBody(writer *multipart.Writer, attributes string, name string, content io.Reader) error {
+	if err := writer.WriteField("attributes", attributes); err != nil {
+		return err
+	}
+
+	if part, err := writer.CreatePart(fileHeader(name)); err != nil {
+		return err
+	} else if _, err := io.Copy(part, content); err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+func fileHeader(name string) textproto.MIMEHeader {
+	quote := strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, quote.Replace(name)))
+	h.Set("Content-Type", "application/octet-stream")
+
+	return h
+}
+
+type counter struct {
+	reader   io.Reader
+	progress func(int64)
+}
+
+func (c *counter) Read(p []byte) (int, error) {
+	N, err := c.reader.Read(p)
+	if N > 0 {
+		c.progress(int64(N))
 	}
 
-	return "", fmt.Errorf("upload request failed (%s)", response.Status)
+	return N, err
 }
@@ -0,0 +1,178 @@
+package files
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUploadEnvelope(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"report.pdf", ""},
+		{"report.pdf", "qwerty"},
+		{`quoted "name".txt`, strings.Repeat("0123456789", 10000)},
+		{`back\slash.txt`, "uiop"},
+		{"ünïcødé.txt", "asdf"},
+	}
+
+	attributes := `{"name":"report.pdf","parent":{"id":"0"}}`
+
+	for _, v := range tests {
+		prefix, suffix, contentType, err := envelope(attributes, v.name)
+		if err != nil {
+			t.Fatalf("Error creating envelope for %v (%v)", v.name, err)
+		}
+
+		body := append(append(append([]byte{}, prefix...), v.content...), suffix...)
+		length := int64(len(prefix)) + int64(len(v.content)) + int64(len(suffix))
+
+		// ... the Content-Length must match the equivalent streamed multipart body
+		_, params, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			t.Fatalf("Invalid Content-Type '%v' (%v)", contentType, err)
+		}
+
+		var b bytes.Buffer
+		writer := multipart.NewWriter(&b)
+		if err := writer.SetBoundary(params["boundary"]); err != nil {
+			t.Fatalf("Error setting boundary (%v)", err)
+		}
+
+		if err := multipartBody(writer, attributes, v.name, strings.NewReader(v.content)); err != nil {
+			t.Fatalf("Error writing multipart body (%v)", err)
+		}
+
+		if length != int64(b.Len()) {
+			t.Errorf("Incorrect Content-Length for %v - expected:%v, got:%v", v.name, b.Len(), length)
+		}
+
+		if !bytes.Equal(body, b.Bytes()) {
+			t.Errorf("Envelope and multipart body differ for %v\n   envelope:  %q\n   multipart: %q", v.name, body, b.Bytes())
+		}
+
+		// ... and parse as the attributes and file parts
+		reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		if part, err := reader.NextPart(); err != nil {
+			t.Errorf("Error reading attributes part (%v)", err)
+		} else if part.FormName() != "attributes" {
+			t.Errorf("Incorrect first part - expected:attributes, got:%v", part.FormName())
+		} else if bytes, _ := io.ReadAll(part); string(bytes) != attributes {
+			t.Errorf("Incorrect attributes - expected:%v, got:%s", attributes, bytes)
+		}
+
+		if part, err := reader.NextPart(); err != nil {
+			t.Errorf("Error reading file part (%v)", err)
+		} else if part.FormName() != "file" || part.FileName() != v.name {
+			t.Errorf("Incorrect file part - expected:file/%v, got:%v/%v", v.name, part.FormName(), part.FileName())
+		} else if bytes, _ := io.ReadAll(part); string(bytes) != v.content {
+			t.Errorf("Incorrect file content for %v - expected:%v bytes, got:%v bytes", v.name, len(v.content), len(bytes))
+		}
+
+		if _, err := reader.NextPart(); err != io.EOF {
+			t.Errorf("Expected end of multipart body for %v, got %v", v.name, err)
+		}
+	}
+}
+
+func TestUploadRollbackFile(t *testing.T) {
+	requests := []string{}
+	stub(t, func(rq *http.Request) (*http.Response, error) {
+		requests = append(requests, rq.Method+" "+rq.URL.Path)
+
+		return reply(http.StatusNoContent, nil), nil
+	})
+
+	if err := rollback(Target{Folder: "0", Name: "report.pdf"}, "12345", "67890", "token"); err != nil {
+		t.Fatalf("Error rolling back upload (%v)", err)
+	}
+
+	if expected := []string{"DELETE /2.0/files/12345"}; strings.Join(requests, ";") != strings.Join(expected, ";") {
+		t.Errorf("Incorrect rollback requests - expected:%v, got:%v", expected, requests)
+	}
+}
+
+func TestUploadRollbackVersion(t *testing.T) {
+	modified := time.Date(2023, time.June, 1, 12, 30, 0, 0, time.UTC)
+
+	requests := []string{}
+	stub(t, func(rq *http.Request) (*http.Response, error) {
+		requests = append(requests, rq.Method+" "+rq.URL.Path)
+
+		switch rq.Method + " " + rq.URL.Path {
+		case "GET /2.0/files/12345/versions":
+			return reply(http.StatusOK, map[string]any{
+				"entries": []map[string]any{
+					{"id": "101", "sha1": "aaaa", "modified_at": modified.Add(-48 * time.Hour)},
+					{"id": "102", "sha1": "bbbb", "modified_at": modified},
+					{"id": "100", "sha1": "cccc", "modified_at": modified.Add(-96 * time.Hour)},
+				},
+			}), nil
+
+		case "POST /2.0/files/12345/versions/current":
+			if body, _ := io.ReadAll(rq.Body); !strings.Contains(string(body), `"id":"102"`) {
+				return reply(http.StatusBadRequest, nil), nil
+			}
+
+			return reply(http.StatusCreated, nil), nil
+
+		case "DELETE /2.0/files/12345/versions/67890":
+			return reply(http.StatusNoContent, nil), nil
+
+		default:
+			return reply(http.StatusNotFound, nil), nil
+		}
+	})
+
+	if err := rollback(Target{FileID: "12345", Name: "report.pdf"}, "12345", "67890", "token"); err != nil {
+		t.Fatalf("Error rolling back upload (%v)", err)
+	}
+
+	expected := []string{
+		"GET /2.0/files/12345/versions",
+		"POST /2.0/files/12345/versions/current",
+		"DELETE /2.0/files/12345/versions/67890",
+	}
+
+	if strings.Join(requests, ";") != strings.Join(expected, ";") {
+		t.Errorf("Incorrect rollback requests\n   expected:%v\n   got:     %v", expected, requests)
+	}
+}
+
+func TestUploadRollbackNoPreviousVersion(t *testing.T) {
+	stub(t, func(rq *http.Request) (*http.Response, error) {
+		return reply(http.StatusOK, map[string]any{"entries": []any{}}), nil
+	})
+
+	if err := rollback(Target{FileID: "12345", Name: "report.pdf"}, "12345", "67890", "token"); err == nil {
+		t.Errorf("Expected error rolling back a version without a previous version")
+	}
+}
+
+func TestUploadAttributes(t *testing.T) {
+	modified := time.Date(2023, time.June, 1, 12, 30, 15, 0, time.FixedZone("SAST", 2*3600))
+
+	tests := []struct {
+		target   Target
+		expected string
+	}{
+		{Target{Folder: "0", Name: "report.pdf"}, `{"name":"report.pdf","parent":{"id":"0"}}`},
+		{Target{Folder: "0", Name: "report.pdf", ContentModified: modified}, `{"name":"report.pdf","parent":{"id":"0"},"content_modified_at":"2023-06-01T12:30:15+02:00"}`},
+		{Target{FileID: "12345", Name: "report.pdf", ContentModified: modified}, `{"name":"report.pdf","content_modified_at":"2023-06-01T12:30:15+02:00"}`},
+	}
+
+	for _, v := range tests {
+		if a, err := attributes(v.target); err != nil {
+			t.Errorf("Error encoding attributes for %+v (%v)", v.target, err)
+		} else if string(a) != v.expected {
+			t.Errorf("Incorrect attributes for %+v\n   expected:%v\n   got:     %s", v.target, v.expected, a)
+		}
+	}
+}
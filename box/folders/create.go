@@ -0,0 +1,77 @@
+package folders
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Create creates a folder in the parent folder, returning the folder ID. Returns an error
+// wrapping ErrExists if the parent folder already has an item with the same name.
+func Create(parentID uint64, name string, token string) (uint64, error) {
+	client := http.Client{
+		Timeout: 60 * time.Second,
+	}
+
+	request := struct {
+		Name   string `json:"name"`
+		Parent struct {
+			ID string `json:"id"`
+		} `json:"parent"`
+	}{
+		Name: name,
+	}
+
+	request.Parent.ID = fmt.Sprintf("%v", parentID)
+
+	encoded, err := json.Marshal(request)
+	if err != nil {
+		return 0, err
+	}
+
+	auth := fmt.Sprintf("Bearer %s", token)
+	uri := "https://api.box.com/2.0/folders?fields=id,name"
+
+	rq, _ := http.NewRequest("POST", uri, bytes.NewBuffer(encoded))
+	rq.Header.Set("Authorization", auth)
+	rq.Header.Set("Content-Type", "application/json")
+	rq.Header.Set("Accepts", "application/json")
+
+	response, err := client.Do(rq)
+	if err != nil {
+		return 0, err
+	}
+
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	switch response.StatusCode {
+	case http.StatusCreated:
+		reply := struct {
+			ID string `json:"id"`
+		}{}
+
+		if err := json.Unmarshal(body, &reply); err != nil {
+			return 0, err
+		}
+
+		return strconv.ParseUint(reply.ID, 10, 64)
+
+	case http.StatusConflict:
+		return 0, fmt.Errorf("%v: folder %w", name, ErrExists)
+
+	case http.StatusNotFound:
+		return 0, fmt.Errorf("%v: folder %w", parentID, ErrNotFound)
+
+	default:
+		return 0, fmt.Errorf("%v: error creating folder (%v)", name, response.Status)
+	}
+}
@@ -0,0 +1,39 @@
+package folders
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+func Delete(folderID uint64, recursive bool, token string) error {
+	client := http.Client{
+		Timeout: 60 * time.Second,
+	}
+
+	auth := fmt.Sprintf("Bearer %s", token)
+	uri := fmt.Sprintf("https://api.box.com/2.0/folders/%v?recursive=%v", folderID, recursive)
+
+	rq, _ := http.NewRequest("DELETE", uri, nil)
+	rq.Header.Set("Authorization", auth)
+	rq.Header.Set("Content-Type", "application/json")
+	rq.Header.Set("Accepts", "application/json")
+
+	response, err := client.Do(rq)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if _, err := io.ReadAll(response.Body); err != nil {
+		return err
+	}
+
+	if response.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("error deleting folder (%v)", response.Status)
+	}
+
+	return nil
+}
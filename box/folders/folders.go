@@ -1,17 +1,28 @@
 package folders
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/twystd/unboxd/log"
 )
 
+var ErrNotFound = errors.New("not found")
+var ErrExists = errors.New("already exists")
+
 const fetchSize = 500
+const fields = "id,type,name,size,tags,created_at,modified_at"
 
 type Folder struct {
-	ID   uint64
-	Name string
-	Tags []string
+	ID         uint64
+	Parent     uint64
+	Name       string
+	Path       string
+	Tags       []string
+	Size       uint64
+	CreatedAt  time.Time
+	ModifiedAt time.Time
 }
 
 func debugf(tag string, format string, args ...any) {
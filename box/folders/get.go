@@ -0,0 +1,97 @@
+package folders
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Get retrieves the folder information, including the parent folder ID and the absolute path
+// constructed from the folder path_collection. The root folder (ID 0) has the path "/". Returns
+// an error wrapping ErrNotFound if the folder does not exist (or is in the trash).
+func Get(folderID uint64, token string) (*Folder, error) {
+	client := http.Client{
+		Timeout: 60 * time.Second,
+	}
+
+	auth := fmt.Sprintf("Bearer %s", token)
+	uri := fmt.Sprintf("https://api.box.com/2.0/folders/%[1]v?fields=%[2]v,path_collection", folderID, fields)
+
+	rq, _ := http.NewRequest("GET", uri, nil)
+	rq.Header.Set("Authorization", auth)
+	rq.Header.Set("Content-Type", "application/json")
+	rq.Header.Set("Accepts", "application/json")
+
+	response, err := client.Do(rq)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%v: folder %w", folderID, ErrNotFound)
+	} else if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v: error retrieving folder information (%v)", folderID, response.Status)
+	}
+
+	reply := struct {
+		Type           string    `json:"type"`
+		ID             string    `json:"id"`
+		Name           string    `json:"name"`
+		Tags           []string  `json:"tags"`
+		Size           uint64    `json:"size"`
+		CreatedAt      time.Time `json:"created_at"`
+		ModifiedAt     time.Time `json:"modified_at"`
+		PathCollection struct {
+			Entries []struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"entries"`
+		} `json:"path_collection"`
+	}{}
+
+	if err := json.Unmarshal(body, &reply); err != nil {
+		return nil, err
+	}
+
+	id, err := strconv.ParseUint(reply.ID, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	path := "/"
+	parent := uint64(0)
+	if id != 0 {
+		segments := []string{}
+		for _, e := range reply.PathCollection.Entries {
+			if e.ID != "0" {
+				segments = append(segments, e.Name)
+			}
+
+			parent, _ = strconv.ParseUint(e.ID, 10, 64)
+		}
+
+		path = "/" + strings.Join(append(segments, reply.Name), "/")
+	}
+
+	return &Folder{
+		ID:         id,
+		Parent:     parent,
+		Name:       reply.Name,
+		Path:       path,
+		Tags:       reply.Tags,
+		Size:       reply.Size,
+		CreatedAt:  reply.CreatedAt,
+		ModifiedAt: reply.ModifiedAt,
+	}, nil
+}
@@ -16,7 +16,7 @@ func List(folderID uint64, token string) ([]Folder, error) {
 		Timeout: 60 * time.Second,
 	}
 
-	uri := fmt.Sprintf("https://api.box.com/2.0/folders/%[1]v/items?fields=id,type,name,tags,sha1&limit=%[2]v&usemarker=true", folderID, fetchSize)
+	uri := fmt.Sprintf("https://api.box.com/2.0/folders/%[1]v/items?fields=%[2]v&limit=%[3]v&usemarker=true", folderID, fields, fetchSize)
 
 	for {
 		rq, _ := http.NewRequest("GET", uri, nil)
@@ -43,10 +43,13 @@ func List(folderID uint64, token string) ([]Folder, error) {
 		reply := struct {
 			TotalCount int `json:"total_count"`
 			Entries    []struct {
-				Type string   `json:"type"`
-				ID   string   `json:"id"`
-				Name string   `json:"name"`
-				Tags []string `json:"tags"`
+				Type       string    `json:"type"`
+				ID         string    `json:"id"`
+				Name       string    `json:"name"`
+				Tags       []string  `json:"tags"`
+				Size       uint64    `json:"size"`
+				CreatedAt  time.Time `json:"created_at"`
+				ModifiedAt time.Time `json:"modified_at"`
 			} `json:"entries"`
 			NextMarker string `json:"next_marker,omitempty"`
 		}{}
@@ -59,9 +62,12 @@ func List(folderID uint64, token string) ([]Folder, error) {
 			if e.Type == "folder" {
 				if id, err := strconv.ParseUint(e.ID, 10, 64); err == nil {
 					folders = append(folders, Folder{
-						ID:   id,
-						Name: e.Name,
-						Tags: e.Tags,
+						ID:         id,
+						Name:       e.Name,
+						Tags:       e.Tags,
+						Size:       e.Size,
+						CreatedAt:  e.CreatedAt,
+						ModifiedAt: e.ModifiedAt,
 					})
 				}
 			}
@@ -73,7 +79,7 @@ func List(folderID uint64, token string) ([]Folder, error) {
 			break
 		}
 
-		uri = fmt.Sprintf("https://api.box.com/2.0/folders/%[1]v/items?fields=id,type,name,sha1&limit=%[2]v&marker=%[3]v&usemarker=true", folderID, fetchSize, reply.NextMarker)
+		uri = fmt.Sprintf("https://api.box.com/2.0/folders/%[1]v/items?fields=%[2]v&limit=%[3]v&marker=%[4]v&usemarker=true", folderID, fields, fetchSize, reply.NextMarker)
 	}
 
 	return folders, nil
@@ -1,6 +1,7 @@
 package lib
 
 import (
+	"path"
 	"strings"
 )
 
@@ -16,6 +17,12 @@ func NewGlob(g string) Glob {
 	var match func(string) bool
 
 	switch {
+	case wildcard(strings.TrimSuffix(strings.TrimSuffix(g, "/**"), "/*")):
+		segments := strings.Split(g, "/")
+		match = func(p string) bool {
+			return matchSegments(segments, strings.Split(p, "/"))
+		}
+
 	case strings.HasSuffix(g, "/**"):
 		l := len(g)
 		match = func(p string) bool {
@@ -59,3 +66,40 @@ func NewGlob(g string) Glob {
 func (g glob) Match(s string) bool {
 	return g.match(s)
 }
+
+func wildcard(g string) bool {
+	return strings.ContainsAny(g, "*?[")
+}
+
+// matchSegments matches a tokenised path against a tokenised glob. A '**' segment matches
+// zero or more path segments, except when it is the last segment in which case it matches
+// one or more (i.e. /alpha/** matches everything under /alpha but not /alpha itself).
+func matchSegments(glob []string, p []string) bool {
+	if len(glob) == 0 {
+		return len(p) == 0
+	}
+
+	if glob[0] == "**" {
+		if len(glob) == 1 {
+			return len(p) > 0
+		}
+
+		for i := 0; i <= len(p); i++ {
+			if matchSegments(glob[1:], p[i:]) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if len(p) == 0 {
+		return false
+	}
+
+	if ok, err := path.Match(glob[0], p[0]); err != nil || !ok {
+		return false
+	}
+
+	return matchSegments(glob[1:], p[1:])
+}
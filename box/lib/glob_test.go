@@ -153,3 +153,33 @@ func TestGlobMatchPathSlashStarStar(t *testing.T) {
 		}
 	}
 }
+
+func TestGlobMatchWildcards(t *testing.T) {
+	tests := []struct {
+		glob     string
+		path     string
+		expected bool
+	}{
+		{"/alpha/**/today", "/alpha/photos/new/today", true},
+		{"/alpha/**/today", "/alpha/today", true},
+		{"/alpha/**/today", "/alpha/photos/new", false},
+		{"/*/pending", "/alpha/pending", true},
+		{"/*/pending", "/beta/pending", true},
+		{"/*/pending", "/alpha/photos/pending", false},
+		{"/*/photos/**", "/alpha/photos", false},
+		{"/*/photos/**", "/alpha/photos/new", true},
+		{"/*/photos/**", "/alpha/photos/new/today", true},
+		{"/**/*.tmp", "/alpha/pending/report.tmp", true},
+		{"/**/*.tmp", "/report.tmp", true},
+		{"/**/*.tmp", "/alpha/pending/report.pdf", false},
+		{"/alpha/p?nding", "/alpha/pending", true},
+		{"/alpha/p?nding", "/alpha/photos", false},
+	}
+
+	for _, v := range tests {
+		g := NewGlob(v.glob)
+		if match := g.Match(v.path); match != v.expected {
+			t.Errorf("Incorrect match for '%s' against '%s' - expected:%v, got:%v", v.path, v.glob, v.expected, match)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Selection is an ordered set of include and exclude patterns using gitignore-like
+// semantics:
+//   - a pattern with a leading or embedded '/' is anchored at the root
+//   - a pattern without a '/' matches a file or folder name at any depth
+//   - a pattern with a trailing '/' matches only folders (and hence their contents)
+//   - a pattern that matches a folder also matches everything under that folder
+//
+// A folder-only pattern matches a folder itself only if the item being matched is a folder.
+//
+// A path is selected if there are no include patterns or it matches at least one include
+// pattern. Exclude rules are then applied in order and the last matching rule wins, so a
+// negated ('!') rule from a patterns file can re-include a path excluded by an earlier rule.
+type Selection struct {
+	includes []rule
+	rules    []rule
+}
+
+// Kind is the kind of item (file or folder) matched against a selection.
+type Kind int
+
+const (
+	File Kind = iota
+	Folder
+)
+
+type rule struct {
+	pattern string
+	glob    Glob
+	include bool
+	folder  bool
+}
+
+func NewSelection(include []string, exclude []string) *Selection {
+	s := Selection{
+		includes: []rule{},
+		rules:    []rule{},
+	}
+
+	for _, p := range include {
+		s.Include(p)
+	}
+
+	for _, p := range exclude {
+		s.Exclude(p)
+	}
+
+	return &s
+}
+
+func (s *Selection) Include(pattern string) {
+	if r, ok := newRule(pattern, true); ok {
+		s.includes = append(s.includes, r)
+	}
+}
+
+func (s *Selection) Exclude(pattern string) {
+	if r, ok := newRule(pattern, false); ok {
+		s.rules = append(s.rules, r)
+	}
+}
+
+// Load appends the rules from a gitignore-like patterns file. Blank lines and lines starting
+// with '#' are ignored, a leading '!' re-includes anything matched by the pattern and a leading
+// '\' escapes a literal '#' or '!'.
+func (s *Selection) Load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+
+		case strings.HasPrefix(line, "!"):
+			if r, ok := newRule(line[1:], true); ok {
+				s.rules = append(s.rules, r)
+			}
+
+		case strings.HasPrefix(line, `\`):
+			s.Exclude(line[1:])
+
+		default:
+			s.Exclude(line)
+		}
+	}
+
+	return scanner.Err()
+}
+
+func (s Selection) IsEmpty() bool {
+	return len(s.includes) == 0 && len(s.rules) == 0
+}
+
+// Match returns true if the path of a file or folder is selected.
+func (s Selection) Match(p string, kind Kind) bool {
+	selected := len(s.includes) == 0
+
+	for _, r := range s.includes {
+		if r.matches(p, kind) {
+			selected = true
+			break
+		}
+	}
+
+	for _, r := range s.rules {
+		if r.matches(p, kind) {
+			selected = r.include
+		}
+	}
+
+	return selected
+}
+
+// String returns a canonical representation of the selection, suitable for including in
+// e.g. a checkpoint hash.
+func (s Selection) String() string {
+	list := []string{}
+
+	for _, r := range s.includes {
+		list = append(list, fmt.Sprintf("+%v", r.pattern))
+	}
+
+	for _, r := range s.rules {
+		if r.include {
+			list = append(list, fmt.Sprintf("!%v", r.pattern))
+		} else {
+			list = append(list, fmt.Sprintf("-%v", r.pattern))
+		}
+	}
+
+	return strings.Join(list, ";")
+}
+
+func newRule(pattern string, include bool) (rule, bool) {
+	p := strings.TrimSpace(pattern)
+	folder := false
+
+	if p == "" || p == "/" {
+		return rule{}, false
+	}
+
+	if strings.HasSuffix(p, "/") {
+		p = strings.TrimRight(p, "/")
+		folder = true
+	}
+
+	switch {
+	case strings.HasPrefix(p, "/"):
+	case strings.Contains(p, "/"):
+		p = "/" + p
+	default:
+		p = "/**/" + p
+	}
+
+	return rule{
+		pattern: pattern,
+		glob:    NewGlob(p),
+		include: include,
+		folder:  folder,
+	}, true
+}
+
+// matches returns true if the rule matches the path or any of the folders containing the path.
+// A folder-only rule only matches the path itself if the path is a folder.
+func (r rule) matches(p string, kind Kind) bool {
+	if (!r.folder || kind == Folder) && r.glob.Match(p) {
+		return true
+	}
+
+	for i := 1; i < len(p); i++ {
+		if p[i] == '/' && r.glob.Match(p[:i]) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,106 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelectionIncludeExclude(t *testing.T) {
+	tests := []struct {
+		path     string
+		kind     Kind
+		expected bool
+	}{
+		{"/projects", Folder, false},
+		{"/projects/alpha", Folder, true},
+		{"/projects/alpha/report.pdf", File, true},
+		{"/projects/alpha/report.tmp", File, false},
+		{"/projects/alpha/archive", Folder, true},
+		{"/projects/alpha/archive/2020", Folder, false},
+		{"/projects/alpha/archive/2020/report.pdf", File, false},
+		{"/projects/beta/photos/archive/today", Folder, true},
+		{"/photos/alpha", Folder, false},
+	}
+
+	s := NewSelection([]string{"/projects/**"}, []string{"/projects/*/archive/**", "*.tmp"})
+
+	for _, v := range tests {
+		if match := s.Match(v.path, v.kind); match != v.expected {
+			t.Errorf("Incorrect match for '%s' - expected:%v, got:%v", v.path, v.expected, match)
+		}
+	}
+}
+
+func TestSelectionLoad(t *testing.T) {
+	patterns := `
+# comment
+archive/
+*.tmp
+!keep.tmp
+\#literal
+`
+
+	tests := []struct {
+		path     string
+		kind     Kind
+		expected bool
+	}{
+		{"/alpha/report.pdf", File, true},
+		{"/alpha/archive", Folder, false},
+		{"/alpha/archive/report.pdf", File, false},
+		{"/alpha/photos/archive/new/today.jpg", File, false},
+		{"/alpha/report.tmp", File, false},
+		{"/alpha/keep.tmp", File, true},
+		{"/alpha/#literal", File, false},
+	}
+
+	s := NewSelection(nil, nil)
+	if err := s.Load(strings.NewReader(patterns)); err != nil {
+		t.Fatalf("Error loading patterns (%v)", err)
+	}
+
+	for _, v := range tests {
+		if match := s.Match(v.path, v.kind); match != v.expected {
+			t.Errorf("Incorrect match for '%s' - expected:%v, got:%v", v.path, v.expected, match)
+		}
+	}
+
+	if expected := `-archive/;-*.tmp;!keep.tmp;-#literal`; s.String() != expected {
+		t.Errorf("Incorrect selection string - expected:%v, got:%v", expected, s.String())
+	}
+}
+
+func TestSelectionFolderOnly(t *testing.T) {
+	tests := []struct {
+		path     string
+		kind     Kind
+		expected bool
+	}{
+		{"/alpha/archive", Folder, false},
+		{"/alpha/archive", File, true},
+		{"/alpha/archive/report.pdf", File, false},
+		{"/alpha/archive/2020", Folder, false},
+		{"/archive", Folder, false},
+		{"/alpha/archived", Folder, true},
+	}
+
+	s := NewSelection(nil, []string{"archive/"})
+
+	for _, v := range tests {
+		if match := s.Match(v.path, v.kind); match != v.expected {
+			t.Errorf("Incorrect match for '%s' (%v) - expected:%v, got:%v", v.path, v.kind, v.expected, match)
+		}
+	}
+}
+
+func TestSelectionEmpty(t *testing.T) {
+	s := NewSelection(nil, nil)
+
+	if !s.IsEmpty() {
+		t.Errorf("Expected empty selection")
+	}
+
+	if !s.Match("/alpha/photos/new", Folder) {
+		t.Errorf("Expected empty selection to match everything")
+	}
+}
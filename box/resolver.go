@@ -0,0 +1,454 @@
+package box
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/twystd/unboxd/box/folders"
+)
+
+type ItemType string
+
+const (
+	AnyItem    ItemType = ""
+	FileItem   ItemType = "file"
+	FolderItem ItemType = "folder"
+)
+
+type Item struct {
+	Type ItemType `json:"type"`
+	ID   uint64   `json:"id"`
+	Path string   `json:"path"`
+}
+
+var ErrNotFound = errors.New("not found")
+var ErrAmbiguous = errors.New("ambiguous")
+var ErrExists = errors.New("already exists")
+
+// Resolver translates between Box paths and IDs. Paths are resolved by walking the folder
+// items from the root folder and the results are cached for the lifetime of the resolver
+// and, optionally, in a cache file so that they can be reused by subsequent invocations.
+// A Resolver is safe for concurrent use.
+type Resolver struct {
+	mu       sync.Mutex
+	list     func(folderID uint64) ([]Item, error)
+	file     func(fileID uint64) (string, error)
+	folder   func(folderID uint64) (string, error)
+	mkdir    func(parentID uint64, name string) (uint64, error)
+	paths    map[string][]Item
+	ids      map[string]Item
+	children map[uint64][]Item
+	cached   map[string]time.Time
+	cache    string
+	ttl      time.Duration
+}
+
+type resolverCache struct {
+	Version int `json:"version"`
+	Items   []struct {
+		Item
+		Cached time.Time `json:"cached"`
+	} `json:"items"`
+}
+
+const resolverCacheVersion = 1
+
+// NewResolver returns a resolver that uses the Box API to walk the folder tree. If the cache
+// file is not blank, entries younger than an hour are loaded from the cache file and Save
+// writes the resolved paths back to it. A missing or invalid cache file is ignored.
+func NewResolver(b *Box, cache string) *Resolver {
+	r := newResolver(cache, 1*time.Hour)
+
+	r.list = func(folderID uint64) ([]Item, error) {
+		items := []Item{}
+
+		if l, err := b.ListFolders(folderID); err != nil {
+			return nil, err
+		} else {
+			for _, f := range l {
+				items = append(items, Item{Type: FolderItem, ID: f.ID, Path: f.Name})
+			}
+		}
+
+		if l, err := b.ListFiles(folderID); err != nil {
+			return nil, err
+		} else {
+			for _, f := range l {
+				items = append(items, Item{Type: FileItem, ID: f.ID, Path: f.Name})
+			}
+		}
+
+		return items, nil
+	}
+
+	r.file = func(fileID uint64) (string, error) {
+		if f, err := b.GetFile(fileID); err != nil {
+			return "", err
+		} else {
+			return f.Path, nil
+		}
+	}
+
+	r.folder = func(folderID uint64) (string, error) {
+		if f, err := b.GetFolder(folderID); err != nil {
+			return "", err
+		} else {
+			return f.Path, nil
+		}
+	}
+
+	r.mkdir = func(parentID uint64, name string) (uint64, error) {
+		if id, err := b.CreateFolder(parentID, name); errors.Is(err, folders.ErrExists) {
+			return 0, fmt.Errorf("%v: %w", name, ErrExists)
+		} else {
+			return id, err
+		}
+	}
+
+	r.load()
+
+	return r
+}
+
+func newResolver(cache string, ttl time.Duration) *Resolver {
+	return &Resolver{
+		paths:    map[string][]Item{},
+		ids:      map[string]Item{},
+		children: map[uint64][]Item{},
+		cached:   map[string]time.Time{},
+		cache:    cache,
+		ttl:      ttl,
+	}
+}
+
+// File returns the file ID for a file ID or absolute file path.
+func (r *Resolver) File(spec string) (uint64, error) {
+	if item, err := r.Lookup(spec, FileItem); err != nil {
+		return 0, err
+	} else {
+		return item.ID, nil
+	}
+}
+
+// Folder returns the folder ID for a folder ID or absolute folder path.
+func (r *Resolver) Folder(spec string) (uint64, error) {
+	if item, err := r.Lookup(spec, FolderItem); err != nil {
+		return 0, err
+	} else {
+		return item.ID, nil
+	}
+}
+
+// Lookup resolves either a numeric Box ID or an absolute path. IDs are not validated against
+// Box and the returned item path is blank.
+func (r *Resolver) Lookup(spec string, t ItemType) (Item, error) {
+	spec = strings.TrimSpace(spec)
+
+	switch {
+	case regexp.MustCompile("^[0-9]+$").MatchString(spec):
+		if id, err := strconv.ParseUint(spec, 10, 64); err != nil {
+			return Item{}, fmt.Errorf("invalid ID %v (%v)", spec, err)
+		} else {
+			return Item{Type: t, ID: id}, nil
+		}
+
+	case strings.HasPrefix(spec, "/"):
+		return r.Resolve(spec, t)
+
+	default:
+		return Item{}, fmt.Errorf("invalid %v '%v' (expected Box ID or absolute path)", describe(t), spec)
+	}
+}
+
+// Resolve returns the item for an absolute path. The path is matched exactly if possible,
+// falling back to a case-insensitive match. Returns an error wrapping ErrNotFound if there is
+// no matching item and ErrAmbiguous if there is more than one.
+func (r *Resolver) Resolve(p string, t ItemType) (Item, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.resolve(p, t)
+}
+
+func (r *Resolver) resolve(p string, t ItemType) (Item, error) {
+	p = path.Clean("/" + strings.TrimSpace(p))
+
+	if p == "/" {
+		if t == FileItem {
+			return Item{}, fmt.Errorf("%v: %w", p, ErrNotFound)
+		}
+
+		return Item{Type: FolderItem, ID: 0, Path: "/"}, nil
+	}
+
+	if items := filter(r.paths[p], t); len(items) == 1 {
+		return items[0], nil
+	}
+
+	parent, err := r.resolve(path.Dir(p), FolderItem)
+	if err != nil {
+		return Item{}, err
+	}
+
+	children, err := r.items(parent)
+	if err != nil {
+		return Item{}, err
+	}
+
+	name := path.Base(p)
+	exact := []Item{}
+	approximate := []Item{}
+
+	for _, item := range filter(children, t) {
+		if path.Base(item.Path) == name {
+			exact = append(exact, item)
+		} else if strings.EqualFold(path.Base(item.Path), name) {
+			approximate = append(approximate, item)
+		}
+	}
+
+	switch {
+	case len(exact) == 1:
+		return exact[0], nil
+
+	case len(exact) > 1:
+		return Item{}, ambiguous(p, exact)
+
+	case len(approximate) == 1:
+		return approximate[0], nil
+
+	case len(approximate) > 1:
+		return Item{}, ambiguous(p, approximate)
+
+	default:
+		return Item{}, fmt.Errorf("%v %v: %w", describe(t), p, ErrNotFound)
+	}
+}
+
+// MkdirAll returns the folder for an absolute path, creating any missing folders along the
+// path (in the style of mkdir -p).
+func (r *Resolver) MkdirAll(p string) (Item, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.mkdirAll(path.Clean("/" + strings.TrimSpace(p)))
+}
+
+func (r *Resolver) mkdirAll(p string) (Item, error) {
+	item, err := r.resolve(p, FolderItem)
+	if err == nil || !errors.Is(err, ErrNotFound) {
+		return item, err
+	}
+
+	parent, err := r.mkdirAll(path.Dir(p))
+	if err != nil {
+		return Item{}, err
+	}
+
+	id, err := r.mkdir(parent.ID, path.Base(p))
+	if errors.Is(err, ErrExists) {
+		// ... created since the parent folder was listed
+		delete(r.children, parent.ID)
+		return r.resolve(p, FolderItem)
+	} else if err != nil {
+		return Item{}, err
+	}
+
+	item = Item{Type: FolderItem, ID: id, Path: path.Join(parent.Path, path.Base(p))}
+
+	r.add(item, time.Now())
+	r.children[id] = []Item{}
+	if children, ok := r.children[parent.ID]; ok {
+		r.children[parent.ID] = append(children, item)
+	}
+
+	return item, nil
+}
+
+// Path returns the absolute path for a file or folder ID, retrieved from the Box item
+// path_collection if it has not already been resolved.
+func (r *Resolver) Path(t ItemType, id uint64) (string, error) {
+	if t != FileItem && t != FolderItem {
+		return "", fmt.Errorf("invalid item type '%v'", t)
+	} else if t == FolderItem && id == 0 {
+		return "/", nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if item, ok := r.ids[key(t, id)]; ok {
+		return item.Path, nil
+	}
+
+	var p string
+	var err error
+
+	if t == FileItem {
+		p, err = r.file(id)
+	} else {
+		p, err = r.folder(id)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	r.add(Item{Type: t, ID: id, Path: p}, time.Now())
+
+	return p, nil
+}
+
+// Save writes the resolved items to the cache file (if any).
+func (r *Resolver) Save() error {
+	if r.cache == "" {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cache := resolverCache{
+		Version: resolverCacheVersion,
+	}
+
+	keys := []string{}
+	for k := range r.ids {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		cache.Items = append(cache.Items, struct {
+			Item
+			Cached time.Time `json:"cached"`
+		}{
+			Item:   r.ids[k],
+			Cached: r.cached[k],
+		})
+	}
+
+	bytes, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.cache), 0750); err != nil {
+		return err
+	}
+
+	tmp := r.cache + ".tmp"
+	if err := os.WriteFile(tmp, bytes, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, r.cache)
+}
+
+func (r *Resolver) load() {
+	if r.cache == "" {
+		return
+	}
+
+	cache := resolverCache{}
+	if bytes, err := os.ReadFile(r.cache); err != nil {
+		return
+	} else if err := json.Unmarshal(bytes, &cache); err != nil || cache.Version != resolverCacheVersion {
+		return
+	}
+
+	for _, v := range cache.Items {
+		if time.Since(v.Cached) < r.ttl {
+			r.add(v.Item, v.Cached)
+		}
+	}
+}
+
+func (r *Resolver) items(folder Item) ([]Item, error) {
+	if children, ok := r.children[folder.ID]; ok {
+		return children, nil
+	}
+
+	list, err := r.list(folder.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	children := []Item{}
+	for _, item := range list {
+		item.Path = path.Join(folder.Path, item.Path)
+		children = append(children, item)
+		r.add(item, now)
+	}
+
+	r.children[folder.ID] = children
+
+	return children, nil
+}
+
+func (r *Resolver) add(item Item, cached time.Time) {
+	k := key(item.Type, item.ID)
+
+	if previous, ok := r.ids[k]; ok {
+		r.paths[previous.Path] = remove(r.paths[previous.Path], item)
+	}
+
+	r.ids[k] = item
+	r.paths[item.Path] = append(r.paths[item.Path], item)
+	r.cached[k] = cached
+}
+
+func key(t ItemType, id uint64) string {
+	return fmt.Sprintf("%v:%v", t, id)
+}
+
+func filter(items []Item, t ItemType) []Item {
+	list := []Item{}
+	for _, item := range items {
+		if t == AnyItem || item.Type == t {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
+
+func remove(items []Item, item Item) []Item {
+	list := []Item{}
+	for _, v := range items {
+		if v.Type != item.Type || v.ID != item.ID {
+			list = append(list, v)
+		}
+	}
+
+	return list
+}
+
+func ambiguous(p string, items []Item) error {
+	candidates := []string{}
+	for _, item := range items {
+		candidates = append(candidates, fmt.Sprintf("%v %v (%v)", item.Type, item.ID, item.Path))
+	}
+
+	return fmt.Errorf("%v: %w - matches %v", p, ErrAmbiguous, strings.Join(candidates, ", "))
+}
+
+func describe(t ItemType) string {
+	if t == AnyItem {
+		return "item"
+	}
+
+	return string(t)
+}
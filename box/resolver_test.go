@@ -0,0 +1,167 @@
+package box
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var tree = map[uint64][]Item{
+	0: []Item{
+		{Type: FolderItem, ID: 1, Path: "alpha"},
+		{Type: FolderItem, ID: 2, Path: "beta"},
+	},
+	1: []Item{
+		{Type: FolderItem, ID: 11, Path: "pending"},
+		{Type: FolderItem, ID: 12, Path: "Photos"},
+		{Type: FolderItem, ID: 13, Path: "report"},
+		{Type: FileItem, ID: 101, Path: "report"},
+	},
+	11: []Item{
+		{Type: FileItem, ID: 111, Path: "report.pdf"},
+		{Type: FileItem, ID: 112, Path: "REPORT.PDF"},
+		{Type: FileItem, ID: 113, Path: "notes.txt"},
+	},
+}
+
+func newTestResolver(cache string) (*Resolver, *int) {
+	calls := 0
+	r := newResolver(cache, time.Hour)
+
+	r.list = func(folderID uint64) ([]Item, error) {
+		calls++
+		if items, ok := tree[folderID]; ok {
+			return items, nil
+		}
+
+		return []Item{}, nil
+	}
+
+	r.file = func(fileID uint64) (string, error) {
+		return "", fmt.Errorf("%v: file not found", fileID)
+	}
+
+	r.folder = func(folderID uint64) (string, error) {
+		return "", fmt.Errorf("%v: folder not found", folderID)
+	}
+
+	return r, &calls
+}
+
+func TestResolverResolve(t *testing.T) {
+	tests := []struct {
+		path     string
+		itemType ItemType
+		expected uint64
+	}{
+		{"/", FolderItem, 0},
+		{"/alpha", FolderItem, 1},
+		{"/alpha/", AnyItem, 1},
+		{"/alpha/pending/report.pdf", FileItem, 111},
+		{"/alpha/pending/Notes.TXT", FileItem, 113},
+		{"/alpha/photos", FolderItem, 12},
+		{"/alpha/report", FileItem, 101},
+		{"/alpha/report", FolderItem, 13},
+	}
+
+	r, _ := newTestResolver("")
+
+	for _, v := range tests {
+		if item, err := r.Resolve(v.path, v.itemType); err != nil {
+			t.Errorf("Error resolving '%v' (%v)", v.path, err)
+		} else if item.ID != v.expected {
+			t.Errorf("Incorrectly resolved '%v' - expected:%v, got:%v", v.path, v.expected, item.ID)
+		}
+	}
+}
+
+func TestResolverErrors(t *testing.T) {
+	tests := []struct {
+		path     string
+		itemType ItemType
+		expected error
+	}{
+		{"/gamma", AnyItem, ErrNotFound},
+		{"/alpha/pending/report.doc", FileItem, ErrNotFound},
+		{"/alpha/pending/report.pdf", FolderItem, ErrNotFound},
+		{"/alpha/pending/report.pdf/x", AnyItem, ErrNotFound},
+		{"/alpha/report", AnyItem, ErrAmbiguous},
+		{"/alpha/pending/Report.pdf", FileItem, ErrAmbiguous},
+	}
+
+	r, _ := newTestResolver("")
+
+	for _, v := range tests {
+		if _, err := r.Resolve(v.path, v.itemType); err == nil {
+			t.Errorf("Expected error resolving '%v'", v.path)
+		} else if !errors.Is(err, v.expected) {
+			t.Errorf("Incorrect error resolving '%v' - expected:%v, got:%v", v.path, v.expected, err)
+		}
+	}
+}
+
+func TestResolverCache(t *testing.T) {
+	cache := filepath.Join(t.TempDir(), ".paths")
+
+	r, calls := newTestResolver(cache)
+	if _, err := r.Resolve("/alpha/pending/notes.txt", FileItem); err != nil {
+		t.Fatalf("Error resolving path (%v)", err)
+	} else if _, err := r.Resolve("/alpha/pending/report.pdf", FileItem); err != nil {
+		t.Fatalf("Error resolving path (%v)", err)
+	} else if *calls != 3 {
+		t.Errorf("Incorrect number of folder listings - expected:%v, got:%v", 3, *calls)
+	}
+
+	if err := r.Save(); err != nil {
+		t.Fatalf("Error saving resolver cache (%v)", err)
+	}
+
+	r, calls = newTestResolver(cache)
+	r.load()
+
+	if item, err := r.Resolve("/alpha/pending/notes.txt", FileItem); err != nil {
+		t.Fatalf("Error resolving path (%v)", err)
+	} else if item.ID != 113 {
+		t.Errorf("Incorrectly resolved path - expected:%v, got:%v", 113, item.ID)
+	} else if *calls != 0 {
+		t.Errorf("Expected path to be resolved from cache - folder listings:%v", *calls)
+	}
+
+	if p, err := r.Path(FileItem, 111); err != nil {
+		t.Errorf("Error resolving file ID (%v)", err)
+	} else if p != "/alpha/pending/report.pdf" {
+		t.Errorf("Incorrectly resolved file ID - expected:%v, got:%v", "/alpha/pending/report.pdf", p)
+	}
+}
+
+func TestResolverMkdirAll(t *testing.T) {
+	r, _ := newTestResolver("")
+
+	created := []string{}
+	r.mkdir = func(parentID uint64, name string) (uint64, error) {
+		created = append(created, fmt.Sprintf("%v:%v", parentID, name))
+		return uint64(1000 + len(created)), nil
+	}
+
+	if item, err := r.MkdirAll("/alpha/pending"); err != nil {
+		t.Fatalf("Error creating existing folder (%v)", err)
+	} else if item.ID != 11 || len(created) != 0 {
+		t.Errorf("Incorrectly resolved existing folder - expected:%v, got:%v (created %v)", 11, item.ID, created)
+	}
+
+	if item, err := r.MkdirAll("/alpha/pending/2026/06"); err != nil {
+		t.Fatalf("Error creating folders (%v)", err)
+	} else if item.ID != 1002 || item.Path != "/alpha/pending/2026/06" {
+		t.Errorf("Incorrect folder - expected:%v %v, got:%v %v", 1002, "/alpha/pending/2026/06", item.ID, item.Path)
+	} else if fmt.Sprintf("%v", created) != "[11:2026 1001:06]" {
+		t.Errorf("Incorrect folders created - expected:%v, got:%v", "[11:2026 1001:06]", created)
+	}
+
+	if item, err := r.Resolve("/alpha/pending/2026", FolderItem); err != nil {
+		t.Errorf("Error resolving created folder (%v)", err)
+	} else if item.ID != 1001 {
+		t.Errorf("Incorrectly resolved created folder - expected:%v, got:%v", 1001, item.ID)
+	}
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/twystd/unboxd/commands"
 	"github.com/twystd/unboxd/credentials"
@@ -12,10 +14,26 @@ import (
 
 var options = struct {
 	credentials string
+	cache       string
+	quiet       bool
 	debug       bool
+	format      string
+	columns     string
+	sort        string
+	reverse     bool
+	template    string
+	tmplFile    string
 }{
 	credentials: ".credentials.json",
+	cache:       "",
+	quiet:       false,
 	debug:       false,
+	format:      "",
+	columns:     "",
+	sort:        "",
+	reverse:     false,
+	template:    "",
+	tmplFile:    "",
 }
 
 func exec(cli []commands.Command) {
@@ -45,22 +63,41 @@ func exec(cli []commands.Command) {
 		log.SetLevel("debug")
 	}
 
+	commands.SetPathCache(options.cache)
+	commands.SetQuiet(options.quiet)
+	commands.SetOutput(options.format, options.columns, options.sort, options.reverse)
+	commands.SetTemplate(options.template, options.tmplFile)
+
 	credentials := credentials.ICredentials{}
 
-	if c, err := NewCredentials(options.credentials); err != nil {
-		log.Fatalf("Error reading credentials from %s (%v)", options.credentials, err)
-	} else {
-		credentials["box"] = c
+	// ... the checkpoint and diff commands and --cached queries only use local files
+	if cmd.Name() != "checkpoint" && cmd.Name() != "diff" && !cached(flagset) {
+		if c, err := NewCredentials(options.credentials); err != nil {
+			log.Fatalf("Error reading credentials from %s (%v)", options.credentials, err)
+		} else {
+			credentials["box"] = c
+		}
 	}
 
-	if err := cmd.Execute(flagset, credentials); err != nil {
+	if err := cmd.Execute(flagset, credentials); errors.Is(err, commands.ErrInterrupted) {
+		log.Warnf("%v  %v", cmd.Name(), err)
+		os.Exit(commands.ExitInterrupted)
+	} else if err != nil {
 		log.Fatalf("%v  %v", cmd.Name(), err)
 	}
 }
 
+func cached(flagset *flag.FlagSet) bool {
+	if f := flagset.Lookup("cached"); f != nil {
+		return f.Value.String() == "true"
+	}
+
+	return false
+}
+
 func usage(cli []commands.Command) {
 	fmt.Println()
-	fmt.Printf("   Usage: %v [--debug] --credentials <file> <command>\n", APP)
+	fmt.Printf("   Usage: %v [--debug] [--quiet] [--path-cache <file>] [--format <format>] [--columns <list>] [--sort <list>] [--reverse] [--template <template>] --credentials <file> <command>\n", APP)
 	fmt.Println()
 	fmt.Println("   Commands:")
 	fmt.Println()
@@ -76,7 +113,15 @@ func parse(cli []commands.Command) (commands.Command, *flag.FlagSet, error) {
 	flagset := flag.NewFlagSet(APP, flag.ExitOnError)
 
 	flagset.StringVar(&options.credentials, "credentials", options.credentials, "(required) JSON file with Box credentials")
+	flagset.StringVar(&options.cache, "path-cache", options.cache, "(optional) File in which to cache resolved Box paths")
+	flagset.BoolVar(&options.quiet, "quiet", options.quiet, "(optional) Disables the progress display for long running commands")
 	flagset.BoolVar(&options.debug, "debug", options.debug, "(optional) Enable debugging information")
+	flagset.StringVar(&options.format, "format", options.format, "(optional) Output format for list and get commands (table, tsv, csv, json, jsonl, yaml, ids or, for list-folders, tree, dot or mermaid)")
+	flagset.StringVar(&options.columns, "columns", options.columns, "(optional) Comma separated list of the columns to display")
+	flagset.StringVar(&options.sort, "sort", options.sort, "(optional) Comma separated list of the columns by which to sort the output")
+	flagset.BoolVar(&options.reverse, "reverse", options.reverse, "(optional) Reverses the sort order")
+	flagset.StringVar(&options.template, "template", options.template, "(optional) Go text/template with which to render the output of list and get commands")
+	flagset.StringVar(&options.tmplFile, "template-file", options.tmplFile, "(optional) File containing the Go text/template with which to render the output")
 	flagset.Parse(os.Args[1:])
 
 	args := flagset.Args()
@@ -86,7 +131,7 @@ func parse(cli []commands.Command) (commands.Command, *flag.FlagSet, error) {
 			if c.Name() == args[0] {
 				cmd := c
 				flagset = cmd.Flagset(flagset)
-				if err := flagset.Parse(args[1:]); err != nil {
+				if err := interspersed(flagset, args[1:]); err != nil {
 					return cmd, flagset, err
 				} else {
 					return cmd, flagset, nil
@@ -97,3 +142,49 @@ func parse(cli []commands.Command) (commands.Command, *flag.FlagSet, error) {
 
 	return nil, flagset, nil
 }
+
+// interspersed parses the command options, allowing options to follow the positional arguments
+// (e.g. list-files '/inbox/*' --format ids). Everything following '--' or an argument that looks
+// like an option but is not defined (e.g. a find predicate) is a positional argument.
+func interspersed(flagset *flag.FlagSet, args []string) error {
+	positional := []string{}
+
+	for len(args) > 0 {
+		if err := flagset.Parse(args); err != nil {
+			return err
+		}
+
+		rest := flagset.Args()
+		if N := len(args) - len(rest); N > 0 && args[N-1] == "--" {
+			positional = append(positional, rest...)
+			break
+		}
+
+		for len(rest) > 0 && !isOption(flagset, rest[0]) {
+			if rest[0] == "--" {
+				positional = append(positional, rest[1:]...)
+				rest = nil
+			} else if strings.HasPrefix(rest[0], "-") && rest[0] != "-" {
+				positional = append(positional, rest...)
+				rest = nil
+			} else {
+				positional = append(positional, rest[0])
+				rest = rest[1:]
+			}
+		}
+
+		args = rest
+	}
+
+	return flagset.Parse(append([]string{"--"}, positional...))
+}
+
+func isOption(flagset *flag.FlagSet, arg string) bool {
+	if !strings.HasPrefix(arg, "-") || arg == "-" || arg == "--" {
+		return false
+	}
+
+	name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+
+	return flagset.Lookup(name) != nil
+}
@@ -18,14 +18,25 @@ var help = commands.Help{
 
 var cli = []commands.Command{
 	&commands.ListFoldersCmd,
+	&commands.UploadFolderCmd,
+	&commands.SyncCmd,
+	&commands.WatchCmd,
 
 	&commands.ListFilesCmd,
+	&commands.FindCmd,
 	&commands.UploadFileCmd,
+	&commands.DownloadFileCmd,
 	&commands.DeleteFileCmd,
 	&commands.TagFileCmd,
 	&commands.UntagFileCmd,
 	&commands.RetagFileCmd,
 
+	&commands.ResolveCmd,
+	&commands.CheckpointCmd,
+	&commands.QueryCmd,
+	&commands.InventoryCmd,
+	&commands.DiffCmd,
+
 	&commands.ListTemplatesCmd,
 	&commands.GetTemplateCmd,
 	&commands.CreateTemplateCmd,
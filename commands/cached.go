@@ -0,0 +1,165 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/twystd/unboxd/inventory"
+)
+
+// defaultInventory is the inventory database used by --cached and query if --db is not
+// specified.
+const defaultInventory = ".inventory"
+
+func openInventory(db string) (*inventory.Inventory, error) {
+	if db == "" {
+		db = defaultInventory
+	}
+
+	return inventory.Open(db)
+}
+
+// readInventory opens an existing inventory database read-only for --cached and query, so that
+// a missing inventory is not created and more than one query can use the inventory at a time.
+func readInventory(db string) (*inventory.Inventory, error) {
+	if db == "" {
+		db = defaultInventory
+	}
+
+	v, err := inventory.OpenReadOnly(db)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no inventory at %v (run list-files --db first)", db)
+	}
+
+	return v, err
+}
+
+// cached retrieves the folders and files below a root folder from the inventory instead of
+// the Box API, applying the same depth bounds as a traversal. The root folder may be a folder
+// ID (if it is in the inventory) or an absolute path.
+func cached(tag string, v *inventory.Inventory, spec string, relativePaths bool, minDepth, maxDepth uint) ([]folder, []file, error) {
+	prefix, err := cachedRoot(v, spec)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	folders := []folder{}
+	files := []file{}
+
+	err = v.Walk(prefix, func(item inventory.Item) error {
+		path := item.Path
+		if relativePaths {
+			path = relative(prefix, item.Path)
+		}
+
+		depth := uint(strings.Count(relative(prefix, item.Path), "/"))
+		if depth == 0 || depth < minDepth || (maxDepth != 0 && depth > maxDepth) {
+			return nil
+		}
+
+		if item.Type == inventory.FolderItem {
+			folders = append(folders, folder{
+				ID:       item.ID,
+				Name:     item.Name,
+				Path:     path,
+				Tags:     item.Tags,
+				Size:     item.Size,
+				Created:  item.Created,
+				Modified: item.Modified,
+			})
+		} else {
+			files = append(files, file{
+				ID:       item.ID,
+				FileName: item.Name,
+				FilePath: path,
+				Tags:     item.Tags,
+				Size:     item.Size,
+				SHA1:     item.SHA1,
+				Created:  item.Created,
+				Modified: item.Modified,
+			})
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	staleness(tag, v, prefix)
+
+	return folders, files, nil
+}
+
+// cachedRoot returns the path prefix for a root folder ID or path. The prefix for the root
+// folder is "".
+func cachedRoot(v *inventory.Inventory, spec string) (string, error) {
+	spec = strings.TrimSpace(spec)
+
+	switch {
+	case spec == "" || spec == "/":
+		return "", nil
+
+	case regexp.MustCompile("^[0-9]+$").MatchString(spec):
+		if id, err := strconv.ParseUint(spec, 10, 64); err != nil {
+			return "", fmt.Errorf("invalid folder ID %v (%v)", spec, err)
+		} else if id == 0 {
+			return "", nil
+		} else if item, err := v.Get(inventory.FolderItem, id); err != nil {
+			return "", fmt.Errorf("folder %v is not in the inventory", id)
+		} else {
+			return item.Path, nil
+		}
+
+	case strings.HasPrefix(spec, "/"):
+		return strings.TrimSuffix(spec, "/"), nil
+
+	default:
+		return "", fmt.Errorf("invalid folder '%v' (expected Box ID or absolute path)", spec)
+	}
+}
+
+// staleness logs the time at which the part of the inventory used for a query was crawled i.e.
+// the last completed crawl that included the folder or, failing that, the time at which the
+// least recently listed item was retrieved.
+func staleness(tag string, v *inventory.Inventory, prefix string) {
+	crawled := time.Time{}
+	oldest := time.Time{}
+	root := "/" + strings.TrimPrefix(prefix, "/")
+
+	if crawls, err := v.Crawls(); err == nil {
+		for _, c := range crawls {
+			p := strings.TrimSuffix(c.Path, "/")
+			if (p == "" || root == p || strings.HasPrefix(root, p+"/")) && c.Completed.After(crawled) {
+				crawled = c.Completed
+			}
+		}
+	}
+
+	if crawled.IsZero() {
+		v.Walk(prefix, func(item inventory.Item) error {
+			if oldest.IsZero() || item.Crawled.Before(oldest) {
+				oldest = item.Crawled
+			}
+
+			return nil
+		})
+	}
+
+	switch {
+	case !crawled.IsZero():
+		infof(tag, "using cached inventory of %v crawled %v (%v ago)", root, timestamp(crawled), age(crawled))
+
+	case !oldest.IsZero():
+		infof(tag, "using cached inventory of %v (partial crawl, oldest item retrieved %v, %v ago)", root, timestamp(oldest), age(oldest))
+
+	default:
+		warnf(tag, "no cached inventory for %v", root)
+	}
+}
@@ -1,13 +1,32 @@
 package commands
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"time"
 )
 
+// A checkpoint is an append-only journal of JSON records, one per line. The first line is the
+// header and each subsequent line is a queue push/pop, a discovered folder/file or the status of
+// a bulk job task. Records are written in transactions terminated by a 'commit' record and any
+// records following the last commit (e.g. from a crash part way through writing a transaction)
+// are discarded on replay.
+//
+// The journal is compacted periodically (and whenever it is opened) by atomically replacing it
+// with a snapshot of the current state. Version 1 checkpoints (a single JSON object) are migrated
+// to the journal format when they are opened.
+const checkpointVersion = 2
+
+// compactAfter is the number of records appended to the journal before it is compacted.
+const compactAfter = 10000
+
+// Checkpoint is the version 1 checkpoint format, retained for migrating existing checkpoints.
 type Checkpoint struct {
 	Hash    string      `json:"id"`
 	Queue   []QueueItem `json:"queue"`
@@ -16,26 +35,248 @@ type Checkpoint struct {
 }
 
 type QueueItem struct {
-	ID   uint64 `json:"ID"`
-	Path string `json:"path"`
+	ID    uint64 `json:"ID"`
+	Path  string `json:"path"`
+	Depth uint   `json:"depth,omitempty"`
+}
+
+type checkpointHeader struct {
+	Version  int       `json:"version"`
+	Hash     string    `json:"id"`
+	Command  string    `json:"command,omitempty"`
+	Root     string    `json:"root,omitempty"`
+	Position string    `json:"position,omitempty"`
+	Started  time.Time `json:"started"`
+}
+
+type record struct {
+	Op     string     `json:"op"`
+	Item   *QueueItem `json:"item,omitempty"`
+	Folder *folder    `json:"folder,omitempty"`
+	File   *file      `json:"file,omitempty"`
+	Task   *task      `json:"task,omitempty"`
+}
+
+type state struct {
+	queue   []QueueItem
+	folders []folder
+	files   []file
+	tasks   []task
+}
+
+type journal struct {
+	path    string
+	header  checkpointHeader
+	lock    *lock
+	f       *os.File
+	pending []record
+	records int
+}
+
+// openJournal locks the checkpoint file and replays it. The returned state is empty if the
+// checkpoint does not exist or restart is true. A pending checkpoint created for a different
+// operation is not overwritten unless restart is true. The checkpoint is compacted before
+// returning and a blank file disables checkpointing.
+func openJournal(chkpt string, hdr checkpointHeader, restart bool) (*journal, state, error) {
+	j := journal{
+		path:   chkpt,
+		header: hdr,
+	}
+
+	s := state{
+		queue:   []QueueItem{},
+		folders: []folder{},
+		files:   []file{},
+	}
+
+	if chkpt == "" {
+		return &j, s, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(chkpt), 0750); err != nil && !os.IsExist(err) {
+		return nil, s, err
+	}
+
+	if l, err := acquire(chkpt + ".lock"); err != nil {
+		return nil, s, fmt.Errorf("checkpoint %v is in use (%v)", chkpt, err)
+	} else {
+		j.lock = l
+	}
+
+	if !restart {
+		if h, v, err := readCheckpoint(chkpt); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			j.lock.release()
+			return nil, s, err
+		} else if err == nil && h.Version != 0 && h.Hash != hdr.Hash {
+			j.lock.release()
+			if h.Command != "" {
+				return nil, s, fmt.Errorf("checkpoint %v belongs to an unfinished %v operation with different arguments (use --no-resume to discard it or --checkpoint to use another file)", chkpt, h.Command)
+			}
+
+			return nil, s, fmt.Errorf("checkpoint %v belongs to an unfinished operation with different arguments (use --no-resume to discard it or --checkpoint to use another file)", chkpt)
+		} else if err == nil {
+			j.header.Started = h.Started
+			if h.Position != "" {
+				j.header.Position = h.Position
+			}
+			s = v
+		}
+	}
+
+	if j.header.Started.IsZero() {
+		j.header.Started = time.Now()
+	}
+
+	if err := j.compact(s); err != nil {
+		j.lock.release()
+		return nil, s, err
+	}
+
+	return &j, s, nil
+}
+
+func (j *journal) push(item QueueItem) {
+	j.pending = append(j.pending, record{Op: "push", Item: &item})
 }
 
-func checkpoint(file string, queue []QueueItem, folders []folder, files []file, hash string) error {
-	checkpoint := Checkpoint{
-		Hash:    hash,
-		Queue:   queue,
-		Folders: folders,
-		Files:   files,
+func (j *journal) pop(item QueueItem) {
+	j.pending = append(j.pending, record{Op: "pop", Item: &item})
+}
+
+func (j *journal) folder(f folder) {
+	j.pending = append(j.pending, record{Op: "folder", Folder: &f})
+}
+
+func (j *journal) file(f file) {
+	j.pending = append(j.pending, record{Op: "file", File: &f})
+}
+
+func (j *journal) task(t task) {
+	j.pending = append(j.pending, record{Op: "task", Task: &t})
+}
+
+// rollback discards the records written since the last commit.
+func (j *journal) rollback() {
+	j.pending = j.pending[:0]
+}
+
+// commit appends the pending records to the journal as a single transaction.
+func (j *journal) commit() error {
+	if j.f == nil || len(j.pending) == 0 {
+		j.pending = j.pending[:0]
+		return nil
 	}
 
-	if file != "" {
-		if err := os.MkdirAll(filepath.Dir(file), 0750); err != nil && !os.IsExist(err) {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+
+	for _, r := range append(j.pending, record{Op: "commit"}) {
+		if err := encoder.Encode(r); err != nil {
 			return err
 		}
+	}
+
+	j.records += len(j.pending)
+	j.pending = j.pending[:0]
+
+	if _, err := j.f.Write(b.Bytes()); err != nil {
+		return err
+	}
+
+	return j.f.Sync()
+}
+
+// checkpoint commits any pending records and compacts the journal if it has grown too large.
+func (j *journal) checkpoint(s state) error {
+	if err := j.commit(); err != nil {
+		return err
+	}
+
+	if j.records >= compactAfter {
+		return j.compact(s)
+	}
+
+	return nil
+}
 
-		if bytes, err := json.MarshalIndent(checkpoint, "", "  "); err != nil {
+// compact atomically replaces the journal with a snapshot of the current state.
+func (j *journal) compact(s state) error {
+	if j.path == "" {
+		return nil
+	}
+
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+
+	j.header.Version = checkpointVersion
+	if err := encoder.Encode(j.header); err != nil {
+		return err
+	}
+
+	records := []record{}
+	for i := range s.folders {
+		records = append(records, record{Op: "folder", Folder: &s.folders[i]})
+	}
+
+	for i := range s.files {
+		records = append(records, record{Op: "file", File: &s.files[i]})
+	}
+
+	for i := range s.queue {
+		records = append(records, record{Op: "push", Item: &s.queue[i]})
+	}
+
+	for i := range s.tasks {
+		records = append(records, record{Op: "task", Task: &s.tasks[i]})
+	}
+
+	for _, r := range append(records, record{Op: "commit"}) {
+		if err := encoder.Encode(r); err != nil {
 			return err
-		} else if err := os.WriteFile(file, bytes, 0666); err != nil {
+		}
+	}
+
+	if j.f != nil {
+		j.f.Close()
+		j.f = nil
+	}
+
+	tmp := j.path + ".tmp"
+	if f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666); err != nil {
+		return err
+	} else if _, err := f.Write(b.Bytes()); err != nil {
+		f.Close()
+		return err
+	} else if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	} else if err := f.Close(); err != nil {
+		return err
+	} else if err := os.Rename(tmp, j.path); err != nil {
+		return err
+	}
+
+	if f, err := os.OpenFile(j.path, os.O_APPEND|os.O_WRONLY, 0666); err != nil {
+		return err
+	} else {
+		j.f = f
+		j.records = 0
+	}
+
+	return nil
+}
+
+// complete removes the checkpoint once the operation has finished.
+func (j *journal) complete() error {
+	j.pending = j.pending[:0]
+
+	if j.f != nil {
+		j.f.Close()
+		j.f = nil
+	}
+
+	if j.path != "" {
+		if err := os.Remove(j.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
 			return err
 		}
 	}
@@ -43,26 +284,137 @@ func checkpoint(file string, queue []QueueItem, folders []folder, files []file,
 	return nil
 }
 
-func resume(chkpt string, hash string, restart bool) ([]QueueItem, []folder, []file, error) {
-	checkpoint := Checkpoint{}
+func (j *journal) close() error {
+	var err error
+
+	if j.f != nil {
+		err = j.f.Close()
+		j.f = nil
+	}
+
+	if j.lock != nil {
+		j.lock.release()
+		j.lock = nil
+	}
+
+	return err
+}
+
+// readCheckpoint reads a checkpoint file, migrating version 1 checkpoints to the current format.
+func readCheckpoint(chkpt string) (checkpointHeader, state, error) {
+	hdr := checkpointHeader{}
+	s := state{
+		queue:   []QueueItem{},
+		folders: []folder{},
+		files:   []file{},
+	}
+
+	f, err := os.Open(chkpt)
+	if err != nil {
+		return hdr, s, err
+	}
+
+	defer f.Close()
 
-	if chkpt != "" && !restart {
-		if _, err := os.Stat(chkpt); err != nil && !errors.Is(err, fs.ErrNotExist) {
-			return nil, nil, nil, err
-		} else if err != nil {
-			return []QueueItem{}, []folder{}, []file{}, nil
+	reader := bufio.NewReader(f)
+	line, err := reader.ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return hdr, s, nil
+	}
+
+	if err := json.Unmarshal(line, &hdr); err != nil || hdr.Version == 0 {
+		return readCheckpointV1(chkpt)
+	} else if hdr.Version > checkpointVersion {
+		return hdr, s, fmt.Errorf("unsupported checkpoint version %v", hdr.Version)
+	}
+
+	pending := []record{}
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			r := record{}
+			if errx := json.Unmarshal(line, &r); errx != nil {
+				break
+			}
+
+			if r.Op == "commit" {
+				s.apply(pending)
+				pending = pending[:0]
+			} else {
+				pending = append(pending, r)
+			}
 		}
 
-		if bytes, err := os.ReadFile(chkpt); err != nil {
-			return nil, nil, nil, err
-		} else if err := json.Unmarshal(bytes, &checkpoint); err != nil {
-			return nil, nil, nil, err
-		} else if checkpoint.Hash != hash {
-			return []QueueItem{}, []folder{}, []file{}, nil
-		} else {
-			return checkpoint.Queue, checkpoint.Folders, checkpoint.Files, nil
+		if err != nil {
+			break
 		}
 	}
 
-	return []QueueItem{}, []folder{}, []file{}, nil
+	return hdr, s, nil
+}
+
+func readCheckpointV1(chkpt string) (checkpointHeader, state, error) {
+	checkpoint := Checkpoint{}
+
+	if bytes, err := os.ReadFile(chkpt); err != nil {
+		return checkpointHeader{}, state{}, err
+	} else if err := json.Unmarshal(bytes, &checkpoint); err != nil {
+		return checkpointHeader{}, state{}, fmt.Errorf("invalid checkpoint %v (%v)", chkpt, err)
+	}
+
+	hdr := checkpointHeader{
+		Version: 1,
+		Hash:    checkpoint.Hash,
+	}
+
+	if info, err := os.Stat(chkpt); err == nil {
+		hdr.Started = info.ModTime()
+	}
+
+	s := state{
+		queue:   checkpoint.Queue,
+		folders: checkpoint.Folders,
+		files:   checkpoint.Files,
+	}
+
+	if s.queue == nil {
+		s.queue = []QueueItem{}
+	}
+
+	if s.folders == nil {
+		s.folders = []folder{}
+	}
+
+	if s.files == nil {
+		s.files = []file{}
+	}
+
+	return hdr, s, nil
+}
+
+func (s *state) apply(records []record) {
+	for _, r := range records {
+		switch {
+		case r.Op == "push" && r.Item != nil:
+			s.queue = append(s.queue, *r.Item)
+
+		case r.Op == "pop" && r.Item != nil:
+			if len(s.queue) > 0 && s.queue[0].ID == r.Item.ID {
+				s.queue = s.queue[1:]
+			}
+
+		case r.Op == "folder" && r.Folder != nil:
+			s.folders = append(s.folders, *r.Folder)
+
+		case r.Op == "file" && r.File != nil:
+			s.files = append(s.files, *r.File)
+
+		case r.Op == "task" && r.Task != nil:
+			if r.Task.Index < len(s.tasks) {
+				s.tasks[r.Task.Index] = *r.Task
+			} else if r.Task.Index == len(s.tasks) {
+				s.tasks = append(s.tasks, *r.Task)
+			}
+		}
+	}
 }
@@ -0,0 +1,324 @@
+package commands
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/twystd/unboxd/credentials"
+)
+
+var CheckpointCmd = Checkpoints{
+	command: command{
+		name:  "checkpoint",
+		delay: 0,
+	},
+
+	file: "",
+}
+
+// Checkpoints implements the 'checkpoint' command for inspecting and managing the checkpoint
+// files created by list-folders, list-files and find. It does not use the Box API.
+type Checkpoints struct {
+	command
+	file string
+}
+
+type checkpointInfo struct {
+	file     string
+	header   checkpointHeader
+	state    state
+	modified time.Time
+	locked   bool
+}
+
+func (cmd *Checkpoints) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
+	flagset.StringVar(&cmd.file, "file", cmd.file, "TSV file to which to export the checkpoint results (defaults to stdout)")
+
+	return flagset
+}
+
+func (cmd Checkpoints) Execute(flagset *flag.FlagSet, c credentials.ICredentials) error {
+	args := flagset.Args()
+	if len(args) < 1 {
+		return fmt.Errorf("missing checkpoint subcommand (show, list, clear or export)")
+	}
+
+	subcommand := args[0]
+	arg := ""
+	if len(args) > 1 {
+		arg = args[1]
+	}
+
+	switch subcommand {
+	case "show":
+		return cmd.show(checkpointFile(arg))
+
+	case "list":
+		if arg == "" {
+			arg = "."
+		}
+		return cmd.list(arg)
+
+	case "clear":
+		return cmd.clear(checkpointFile(arg))
+
+	case "export":
+		return cmd.export(checkpointFile(arg))
+
+	default:
+		return fmt.Errorf("invalid checkpoint subcommand '%v' (expected show, list, clear or export)", subcommand)
+	}
+}
+
+func (cmd Checkpoints) show(chkpt string) error {
+	info, err := inspect(chkpt)
+	if err != nil {
+		return err
+	}
+
+	root, path := info.root()
+
+	fmt.Printf("checkpoint  %v\n", info.file)
+	fmt.Printf("version     %v\n", info.header.Version)
+	fmt.Printf("command     %v\n", info.command())
+	fmt.Printf("root        %v  %v\n", root, path)
+	fmt.Printf("queued      %v\n", len(info.state.queue))
+	fmt.Printf("folders     %v\n", len(info.state.folders))
+	fmt.Printf("files       %v\n", len(info.state.files))
+	if len(info.state.tasks) > 0 {
+		fmt.Printf("items       %v  (%v completed)\n", len(info.state.tasks), info.completed())
+	}
+	fmt.Printf("started     %v  (%v ago)\n", timestamp(info.header.Started), age(info.header.Started))
+	fmt.Printf("updated     %v  (%v ago)\n", timestamp(info.modified), age(info.modified))
+	fmt.Printf("status      %v\n", info.status())
+
+	return nil
+}
+
+func (cmd Checkpoints) list(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	r := results{
+		columns:  []string{"Checkpoint", "Command", "Root", "Queued", "Folders", "Files", "Items", "Age", "Started", "Updated", "Status"},
+		defaults: []string{"Checkpoint", "Command", "Root", "Queued", "Folders", "Files", "Age", "Status"},
+		rows:     [][]any{},
+	}
+
+	for _, e := range entries {
+		if e.IsDir() || strings.HasSuffix(e.Name(), ".lock") || strings.HasSuffix(e.Name(), ".tmp") {
+			continue
+		}
+
+		// ... skips anything that isn't a checkpoint
+		info, err := inspect(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+
+		_, path := info.root()
+
+		r.rows = append(r.rows, []any{
+			info.file,
+			info.command(),
+			path,
+			len(info.state.queue),
+			len(info.state.folders),
+			len(info.state.files),
+			len(info.state.tasks),
+			age(info.header.Started),
+			info.header.Started,
+			info.modified,
+			info.status(),
+		})
+	}
+
+	if len(r.rows) == 0 {
+		infof("checkpoint", "no checkpoints in %v", dir)
+		return nil
+	}
+
+	return r.render(os.Stdout, "table", false)
+}
+
+// clear removes a checkpoint that is not in use.
+func (cmd Checkpoints) clear(chkpt string) error {
+	if _, err := os.Stat(chkpt); err != nil {
+		return err
+	}
+
+	l, err := acquire(chkpt + ".lock")
+	if err != nil {
+		return fmt.Errorf("checkpoint %v is in use (%v)", chkpt, err)
+	}
+
+	defer l.release()
+
+	if err := os.Remove(chkpt); err != nil {
+		return err
+	} else if err := os.Remove(chkpt + ".tmp"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	infof("checkpoint", "removed %v", chkpt)
+
+	return nil
+}
+
+// export writes the folders and files collected so far to a TSV file.
+func (cmd Checkpoints) export(chkpt string) error {
+	info, err := inspect(chkpt)
+	if err != nil {
+		return err
+	}
+
+	folders := info.state.folders
+	files := info.state.files
+
+	sort.Slice(folders, func(i, j int) bool { return folders[i].Path < folders[j].Path })
+	sort.Slice(files, func(i, j int) bool { return files[i].FilePath < files[j].FilePath })
+
+	table := [][]string{
+		{"Type", "ID", "Path", "Size", "Tags"},
+	}
+
+	for _, f := range folders {
+		table = append(table, []string{"folder", fmt.Sprintf("%v", f.ID), f.Path, fmt.Sprintf("%v", f.Size), strings.Join(f.Tags, "; ")})
+	}
+
+	for _, f := range files {
+		table = append(table, []string{"file", fmt.Sprintf("%v", f.ID), f.FilePath, fmt.Sprintf("%v", f.Size), strings.Join(f.Tags, "; ")})
+	}
+
+	var w io.Writer = os.Stdout
+	if cmd.file != "" {
+		infof("checkpoint", "exporting %v folders and %v files to TSV file %v", len(folders), len(files), cmd.file)
+
+		if err := os.MkdirAll(filepath.Dir(cmd.file), 0750); err != nil {
+			return err
+		} else if f, err := os.Create(cmd.file); err != nil {
+			return err
+		} else {
+			defer f.Close()
+			w = f
+		}
+	}
+
+	tsv := csv.NewWriter(w)
+	tsv.Comma = '\t'
+	tsv.WriteAll(table)
+
+	return tsv.Error()
+}
+
+func checkpointFile(arg string) string {
+	if arg == "" {
+		return ListFilesCmd.checkpoint
+	}
+
+	return arg
+}
+
+func inspect(chkpt string) (checkpointInfo, error) {
+	info := checkpointInfo{
+		file: chkpt,
+	}
+
+	if stat, err := os.Stat(chkpt); err != nil {
+		return info, err
+	} else {
+		info.modified = stat.ModTime()
+	}
+
+	if hdr, s, err := readCheckpoint(chkpt); err != nil {
+		return info, err
+	} else if hdr.Hash == "" {
+		return info, fmt.Errorf("%v is not a checkpoint", chkpt)
+	} else {
+		info.header = hdr
+		info.state = s
+	}
+
+	if l, err := acquire(chkpt + ".lock"); err != nil {
+		info.locked = true
+	} else {
+		l.release()
+	}
+
+	return info, nil
+}
+
+func (info checkpointInfo) command() string {
+	if info.header.Command == "" {
+		return "-"
+	}
+
+	return info.header.Command
+}
+
+// root returns the starting folder ID and path recorded in the checkpoint header. Version 1
+// checkpoints did not record the root folder.
+func (info checkpointInfo) root() (string, string) {
+	if info.header.Root == "" {
+		return "-", "-"
+	}
+
+	id, path, _ := strings.Cut(info.header.Root, ":")
+	if path == "" {
+		path = "/"
+	}
+
+	return id, path
+}
+
+func (info checkpointInfo) status() string {
+	switch {
+	case info.locked:
+		return "in use"
+
+	case len(info.state.queue) == 0 && info.completed() == len(info.state.tasks):
+		return "complete"
+
+	default:
+		return "resumable"
+	}
+}
+
+// completed returns the number of bulk job tasks that have succeeded.
+func (info checkpointInfo) completed() int {
+	count := 0
+	for _, t := range info.state.tasks {
+		if t.Status == taskSucceeded {
+			count++
+		}
+	}
+
+	return count
+}
+
+func timestamp(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.Format("2006-01-02 15:04:05")
+}
+
+func age(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return fmt.Sprintf("%v", time.Since(t).Round(time.Second))
+}
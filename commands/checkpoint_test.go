@@ -0,0 +1,279 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckpointReplay(t *testing.T) {
+	chkpt := filepath.Join(t.TempDir(), ".checkpoint")
+	hdr := checkpointHeader{Hash: "qwerty", Command: "list-files", Root: "0:/"}
+
+	j, s, err := openJournal(chkpt, hdr, false)
+	if err != nil {
+		t.Fatalf("Error opening checkpoint (%v)", err)
+	} else if len(s.queue) != 0 || len(s.folders) != 0 || len(s.files) != 0 {
+		t.Fatalf("Expected empty state for new checkpoint, got %+v", s)
+	}
+
+	j.push(QueueItem{ID: 0, Path: "/"})
+	if err := j.commit(); err != nil {
+		t.Fatalf("Error committing checkpoint (%v)", err)
+	}
+
+	j.pop(QueueItem{ID: 0, Path: "/"})
+	j.folder(folder{ID: 1, Name: "alpha", Path: "/alpha"})
+	j.file(file{ID: 2, FileName: "report.pdf", FilePath: "/report.pdf", Size: 1024})
+	j.push(QueueItem{ID: 1, Path: "/alpha", Depth: 1})
+	if err := j.commit(); err != nil {
+		t.Fatalf("Error committing checkpoint (%v)", err)
+	}
+
+	// ... uncommitted records are not replayed
+	j.pop(QueueItem{ID: 1, Path: "/alpha", Depth: 1})
+	j.rollback()
+	j.folder(folder{ID: 3, Name: "beta", Path: "/beta"})
+
+	j.close()
+
+	h, s, err := readCheckpoint(chkpt)
+	if err != nil {
+		t.Fatalf("Error reading checkpoint (%v)", err)
+	}
+
+	if h.Version != checkpointVersion || h.Hash != "qwerty" || h.Command != "list-files" || h.Root != "0:/" {
+		t.Errorf("Incorrect checkpoint header %+v", h)
+	}
+
+	if expected := []QueueItem{{ID: 1, Path: "/alpha", Depth: 1}}; !reflect.DeepEqual(s.queue, expected) {
+		t.Errorf("Incorrect replayed queue - expected:%+v, got:%+v", expected, s.queue)
+	}
+
+	if len(s.folders) != 1 || s.folders[0].Path != "/alpha" {
+		t.Errorf("Incorrect replayed folders %+v", s.folders)
+	}
+
+	if len(s.files) != 1 || s.files[0].FilePath != "/report.pdf" || s.files[0].Size != 1024 {
+		t.Errorf("Incorrect replayed files %+v", s.files)
+	}
+}
+
+func TestCheckpointTasks(t *testing.T) {
+	chkpt := filepath.Join(t.TempDir(), ".checkpoint")
+	hdr := checkpointHeader{Hash: "qwerty", Command: "tag-file"}
+
+	j, _, err := openJournal(chkpt, hdr, false)
+	if err != nil {
+		t.Fatalf("Error opening checkpoint (%v)", err)
+	}
+
+	j.task(task{Index: 0, Args: []string{"1"}, Status: taskPending})
+	j.task(task{Index: 1, Args: []string{"2"}, Status: taskPending})
+	j.task(task{Index: 0, Args: []string{"1"}, Status: taskSucceeded, Result: "1"})
+	j.task(task{Index: 5, Args: []string{"6"}, Status: taskSucceeded})
+	if err := j.commit(); err != nil {
+		t.Fatalf("Error committing checkpoint (%v)", err)
+	}
+
+	j.close()
+
+	_, s, err := readCheckpoint(chkpt)
+	if err != nil {
+		t.Fatalf("Error reading checkpoint (%v)", err)
+	}
+
+	expected := []task{
+		{Index: 0, Args: []string{"1"}, Status: taskSucceeded, Result: "1"},
+		{Index: 1, Args: []string{"2"}, Status: taskPending},
+	}
+
+	if !reflect.DeepEqual(s.tasks, expected) {
+		t.Errorf("Incorrect replayed tasks - expected:%+v, got:%+v", expected, s.tasks)
+	}
+}
+
+func TestCheckpointTornTail(t *testing.T) {
+	chkpt := filepath.Join(t.TempDir(), ".checkpoint")
+	hdr := checkpointHeader{Hash: "qwerty", Command: "list-folders"}
+
+	j, _, err := openJournal(chkpt, hdr, false)
+	if err != nil {
+		t.Fatalf("Error opening checkpoint (%v)", err)
+	}
+
+	j.push(QueueItem{ID: 0, Path: "/"})
+	j.folder(folder{ID: 1, Name: "alpha", Path: "/alpha"})
+	if err := j.commit(); err != nil {
+		t.Fatalf("Error committing checkpoint (%v)", err)
+	}
+
+	j.close()
+
+	// ... simulate a crash part way through writing a transaction
+	torn := []string{
+		`{"op":"folder","folder":{"ID":2,"name":"beta","path":"/beta"}}` + "\n",
+		`{"op":"folder","folder":{"ID":3,"name":"gamma","path":"/gamma"}}` + "\n" + `{"op":"comm`,
+	}
+
+	for _, tail := range torn {
+		f, err := os.OpenFile(chkpt, os.O_APPEND|os.O_WRONLY, 0666)
+		if err != nil {
+			t.Fatalf("Error opening checkpoint (%v)", err)
+		}
+
+		f.WriteString(tail)
+		f.Close()
+
+		j, s, err := openJournal(chkpt, hdr, false)
+		if err != nil {
+			t.Fatalf("Error reopening checkpoint (%v)", err)
+		}
+
+		if len(s.folders) != 1 || s.folders[0].Path != "/alpha" {
+			t.Errorf("Expected uncommitted records to be discarded, got %+v", s.folders)
+		}
+
+		if len(s.queue) != 1 || s.queue[0].Path != "/" {
+			t.Errorf("Incorrect replayed queue %+v", s.queue)
+		}
+
+		j.close()
+
+		// ... reopening compacts the journal, truncating the torn tail
+		if bytes, err := os.ReadFile(chkpt); err != nil {
+			t.Fatalf("Error reading checkpoint (%v)", err)
+		} else if strings.Contains(string(bytes), "beta") || strings.Contains(string(bytes), "gamma") {
+			t.Errorf("Expected torn tail to be truncated:\n%s", bytes)
+		} else if !strings.HasSuffix(string(bytes), `{"op":"commit"}`+"\n") {
+			t.Errorf("Expected compacted checkpoint to end with a commit record:\n%s", bytes)
+		}
+	}
+}
+
+func TestCheckpointLock(t *testing.T) {
+	chkpt := filepath.Join(t.TempDir(), ".checkpoint")
+	hdr := checkpointHeader{Hash: "qwerty", Command: "list-files"}
+
+	j, _, err := openJournal(chkpt, hdr, false)
+	if err != nil {
+		t.Fatalf("Error opening checkpoint (%v)", err)
+	}
+
+	if _, _, err := openJournal(chkpt, hdr, false); err == nil {
+		t.Errorf("Expected error opening a checkpoint that is in use")
+	}
+
+	if _, _, err := openJournal(chkpt, hdr, true); err == nil {
+		t.Errorf("Expected error restarting a checkpoint that is in use")
+	}
+
+	j.close()
+
+	if j, _, err := openJournal(chkpt, hdr, false); err != nil {
+		t.Errorf("Error opening released checkpoint (%v)", err)
+	} else {
+		j.close()
+	}
+}
+
+func TestCheckpointHashMismatch(t *testing.T) {
+	chkpt := filepath.Join(t.TempDir(), ".checkpoint")
+	hdr := checkpointHeader{Hash: "qwerty", Command: "list-files"}
+
+	j, _, err := openJournal(chkpt, hdr, false)
+	if err != nil {
+		t.Fatalf("Error opening checkpoint (%v)", err)
+	}
+
+	j.push(QueueItem{ID: 0, Path: "/"})
+	if err := j.commit(); err != nil {
+		t.Fatalf("Error committing checkpoint (%v)", err)
+	}
+
+	j.close()
+
+	other := checkpointHeader{Hash: "uiop", Command: "list-folders"}
+	if _, _, err := openJournal(chkpt, other, false); err == nil {
+		t.Errorf("Expected error opening a checkpoint for a different operation")
+	} else if !strings.Contains(err.Error(), "--no-resume") {
+		t.Errorf("Expected error to suggest --no-resume, got '%v'", err)
+	}
+
+	// ... the pending checkpoint must be left as is
+	if h, s, err := readCheckpoint(chkpt); err != nil {
+		t.Fatalf("Error reading checkpoint (%v)", err)
+	} else if h.Hash != "qwerty" || len(s.queue) != 1 {
+		t.Errorf("Expected pending checkpoint to be unchanged, got %+v %+v", h, s)
+	}
+
+	j, s, err := openJournal(chkpt, other, true)
+	if err != nil {
+		t.Fatalf("Error restarting checkpoint (%v)", err)
+	} else if len(s.queue) != 0 {
+		t.Errorf("Expected empty state for restarted checkpoint, got %+v", s.queue)
+	}
+
+	j.close()
+
+	if h, _, err := readCheckpoint(chkpt); err != nil {
+		t.Fatalf("Error reading checkpoint (%v)", err)
+	} else if h.Hash != "uiop" {
+		t.Errorf("Expected restarted checkpoint hash 'uiop', got '%v'", h.Hash)
+	}
+}
+
+func TestCheckpointMigrateV1(t *testing.T) {
+	chkpt := filepath.Join(t.TempDir(), ".checkpoint")
+	v1 := `{
+  "id": "qwerty",
+  "queue": [ { "ID": 1, "path": "/alpha", "depth": 1 } ],
+  "folders": [ { "ID": 1, "name": "alpha", "path": "/alpha" } ],
+  "files": [ { "ID": 2, "FileName": "report.pdf", "FilePath": "/report.pdf", "Size": 1024 } ]
+}`
+
+	if err := os.WriteFile(chkpt, []byte(v1), 0666); err != nil {
+		t.Fatalf("Error writing v1 checkpoint (%v)", err)
+	}
+
+	if h, _, err := readCheckpoint(chkpt); err != nil {
+		t.Fatalf("Error reading v1 checkpoint (%v)", err)
+	} else if h.Version != 1 || h.Hash != "qwerty" {
+		t.Errorf("Incorrect v1 checkpoint header %+v", h)
+	}
+
+	j, s, err := openJournal(chkpt, checkpointHeader{Hash: "qwerty", Command: "list-files"}, false)
+	if err != nil {
+		t.Fatalf("Error opening v1 checkpoint (%v)", err)
+	}
+
+	j.close()
+
+	if expected := []QueueItem{{ID: 1, Path: "/alpha", Depth: 1}}; !reflect.DeepEqual(s.queue, expected) {
+		t.Errorf("Incorrect migrated queue - expected:%+v, got:%+v", expected, s.queue)
+	}
+
+	if len(s.folders) != 1 || s.folders[0].Path != "/alpha" {
+		t.Errorf("Incorrect migrated folders %+v", s.folders)
+	}
+
+	if len(s.files) != 1 || s.files[0].FilePath != "/report.pdf" {
+		t.Errorf("Incorrect migrated files %+v", s.files)
+	}
+
+	// ... the checkpoint is rewritten in the journal format
+	h, migrated, err := readCheckpoint(chkpt)
+	if err != nil {
+		t.Fatalf("Error reading migrated checkpoint (%v)", err)
+	}
+
+	if h.Version != checkpointVersion || h.Hash != "qwerty" || h.Command != "list-files" {
+		t.Errorf("Incorrect migrated checkpoint header %+v", h)
+	}
+
+	if !reflect.DeepEqual(migrated.queue, s.queue) || len(migrated.folders) != 1 || len(migrated.files) != 1 {
+		t.Errorf("Incorrect migrated checkpoint state %+v", migrated)
+	}
+}
@@ -4,12 +4,13 @@ import (
 	"crypto/sha256"
 	"flag"
 	"fmt"
+	"os"
 	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/twystd/unboxd/box"
+	"github.com/twystd/unboxd/box/lib"
 	"github.com/twystd/unboxd/credentials"
 	"github.com/twystd/unboxd/log"
 )
@@ -29,13 +30,32 @@ func (cmd command) Name() string {
 	return cmd.name
 }
 
-func (cmd command) hash(command string, credentials string, root string) string {
+func (cmd command) hash(command string, credentials string, root string, qualifiers ...string) string {
 	s := fmt.Sprintf("%v:%v:%v", command, credentials, root)
+	for _, q := range qualifiers {
+		if q != "" {
+			s += ":" + q
+		}
+	}
+
 	hash := sha256.Sum256([]byte(s))
 
 	return fmt.Sprintf("%x", hash)
 }
 
+// patterns implements flag.Value for options that can be specified more than once.
+type patterns []string
+
+func (p *patterns) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *patterns) Set(v string) error {
+	*p = append(*p, v)
+
+	return nil
+}
+
 func Authenticate(credentials box.Credentials) (box.Box, error) {
 	box := box.NewBox()
 	if err := box.Authenticate(credentials); err != nil {
@@ -45,17 +65,59 @@ func Authenticate(credentials box.Credentials) (box.Box, error) {
 	}
 }
 
+func selection(include []string, exclude []string, file string) (*lib.Selection, error) {
+	selection := lib.NewSelection(include, exclude)
+
+	if file != "" {
+		if f, err := os.Open(file); err != nil {
+			return nil, err
+		} else {
+			defer f.Close()
+
+			if err := selection.Load(f); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return selection, nil
+}
+
 func clean(s string) string {
 	return regexp.MustCompile(`[\s\t]+`).ReplaceAllString(strings.ToLower(s), "")
 }
 
-func getFileID(arg string) (uint64, error) {
-	if !regexp.MustCompile("^[0-9]+$").MatchString(arg) {
-		return 0, fmt.Errorf("invalid file ID")
-	} else if v, err := strconv.ParseUint(arg, 10, 64); err != nil {
-		return 0, fmt.Errorf("invalid file ID %v)", err)
+func resolver(b *box.Box) *box.Resolver {
+	return box.NewResolver(b, options.cache)
+}
+
+// getFileID returns the file ID for a file ID or absolute Box file path.
+func getFileID(r *box.Resolver, arg string) (uint64, error) {
+	return r.File(arg)
+}
+
+// getFolderID returns the folder ID for a folder ID or absolute Box folder path.
+func getFolderID(r *box.Resolver, arg string) (uint64, error) {
+	return r.Folder(arg)
+}
+
+// makeFolderID returns the folder ID for a folder ID or absolute Box folder path, creating any
+// missing folders along the path.
+func makeFolderID(r *box.Resolver, arg string) (uint64, error) {
+	if !strings.HasPrefix(strings.TrimSpace(arg), "/") {
+		return getFolderID(r, arg)
+	}
+
+	if item, err := r.MkdirAll(arg); err != nil {
+		return 0, err
 	} else {
-		return uint64(v), nil
+		return item.ID, nil
+	}
+}
+
+func save(tag string, r *box.Resolver) {
+	if err := r.Save(); err != nil {
+		warnf(tag, "error saving path cache (%v)", err)
 	}
 }
 
@@ -3,8 +3,7 @@ package commands
 import (
 	"flag"
 	"fmt"
-	"regexp"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/twystd/unboxd/box"
@@ -16,13 +15,22 @@ var DeleteFileCmd = DeleteFile{
 		name:  "delete-file",
 		delay: 500 * time.Millisecond,
 	},
+
+	bulk: bulk{
+		checkpoint: ".checkpoint.delete-file",
+		workers:    1,
+	},
 }
 
 type DeleteFile struct {
 	command
+	bulk
 }
 
 func (cmd *DeleteFile) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
+	cmd.bulk.flags(flagset)
+	flagset.DurationVar(&cmd.delay, "delay", cmd.delay, "Delay between multiple requests to reduce traffic to Box API")
+
 	return flagset
 }
 
@@ -34,29 +42,46 @@ func (cmd DeleteFile) Execute(flagset *flag.FlagSet, c credentials.ICredentials)
 		return err
 	}
 
-	args := flagset.Args()
-	if len(args) == 0 {
-		return fmt.Errorf("missing file ID argument")
-	}
+	r := resolver(&b)
+	defer save("delete-file", r)
 
-	for _, file := range args {
-		var fileID uint64
-		if !regexp.MustCompile("^[0-9]+$").MatchString(file) {
-			return fmt.Errorf("invalid file ID")
-		} else if v, err := strconv.ParseUint(file, 10, 64); err != nil {
-			return fmt.Errorf("invalid file ID %v)", err)
-		} else {
-			fileID = uint64(v)
-		}
+	files, err := cmd.items(flagset.Args())
+	if err != nil {
+		return err
+	} else if len(files) == 0 {
+		return fmt.Errorf("missing file ID or path argument")
+	}
 
-		if err := cmd.exec(b, fileID); err != nil {
-			return err
-		}
+	selection, err := cmd.selection()
+	if err != nil {
+		return err
+	}
 
-		infof("delete-file", "%v deleted\n", file)
+	items := [][]string{}
+	for _, file := range files {
+		items = append(items, []string{file})
 	}
 
-	return nil
+	hash := cmd.hash("delete-file", b.Hash(), "", selection.String(), strings.Join(files, "\n"))
+	j := cmd.job("delete-file", cmd.delay)
+
+	_, err = j.run(hash, items, func(args []string, sent func(int64), state *taskState) (string, error) {
+		if fileID, err := getFileID(r, args[0]); err != nil {
+			return "", err
+		} else if ok, err := selectedFile(r, selection, fileID); err != nil {
+			return "", err
+		} else if !ok {
+			infof("delete-file", "%v skipped (not selected)", args[0])
+			return fmt.Sprintf("%v", fileID), nil
+		} else if err := cmd.exec(b, fileID); err != nil {
+			return "", err
+		} else {
+			infof("delete-file", "%v deleted\n", args[0])
+			return fmt.Sprintf("%v", fileID), nil
+		}
+	})
+
+	return err
 }
 
 func (cmd DeleteFile) exec(b box.Box, fileID uint64) error {
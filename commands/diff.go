@@ -0,0 +1,269 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/twystd/unboxd/credentials"
+	"github.com/twystd/unboxd/inventory"
+)
+
+var DiffCmd = Diff{
+	command: command{
+		name:  "diff",
+		delay: 0,
+	},
+}
+
+// Diff implements the 'diff' command, which compares two snapshots of a folder tree. A snapshot
+// may be a TSV file created by list-folders, list-files, query or checkpoint export, an inventory
+// database or a checkpoint. It does not use the Box API.
+type Diff struct {
+	command
+	file string
+}
+
+func (cmd *Diff) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
+	flagset.StringVar(&cmd.file, "file", cmd.file, "File to which to write the differences (defaults to stdout)")
+
+	return flagset
+}
+
+func (cmd Diff) Execute(flagset *flag.FlagSet, c credentials.ICredentials) error {
+	args := flagset.Args()
+	if len(args) < 2 {
+		return fmt.Errorf("diff requires two snapshots (TSV file, inventory or checkpoint)")
+	}
+
+	if err := validateFormat(); err != nil {
+		return err
+	}
+
+	old, err := snapshot(args[0])
+	if err != nil {
+		return err
+	}
+
+	new, err := snapshot(args[1])
+	if err != nil {
+		return err
+	}
+
+	changes := inventory.Diff(old, new)
+
+	return cmd.results(changes).write("diff", cmd.file, false)
+}
+
+// results returns the changes as a result set for the output formatter.
+func (cmd Diff) results(changes []inventory.Change) results {
+	r := results{
+		columns:  []string{"Change", "Type", "ID", "Path", "From", "To"},
+		defaults: []string{"Change", "Type", "ID", "Path", "From", "To"},
+		rows:     [][]any{},
+	}
+
+	for _, c := range changes {
+		r.rows = append(r.rows, []any{c.Change, string(c.Type), c.ID, c.Path, c.From, c.To})
+	}
+
+	return r
+}
+
+// boltMagic is the magic number in the first page of a bbolt database (little endian, following
+// the 16 byte page header).
+var boltMagic = []byte{0xed, 0xda, 0x0c, 0xed}
+
+// snapshot loads a snapshot from an inventory database, a checkpoint (JSON) or a TSV file,
+// identified by the contents of the file.
+func snapshot(file string) (inventory.Snapshot, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return inventory.Snapshot{}, err
+	}
+
+	r := bufio.NewReader(f)
+	header, _ := r.Peek(20)
+	magic := len(header) == 20 && bytes.Equal(header[16:], boltMagic)
+	line, _ := r.ReadBytes('\n')
+	f.Close()
+
+	switch {
+	case magic:
+		if v, err := inventory.OpenReadOnly(file); err != nil {
+			return inventory.Snapshot{}, err
+		} else {
+			defer v.Close()
+
+			return v.Snapshot()
+		}
+
+	case bytes.HasPrefix(bytes.TrimSpace(line), []byte("{")):
+		return snapshotFromCheckpoint(file)
+
+	case bytes.Contains(line, []byte("\t")):
+		return snapshotFromTSV(file)
+
+	default:
+		return inventory.Snapshot{}, fmt.Errorf("%v is not a TSV file, inventory or checkpoint", file)
+	}
+}
+
+func snapshotFromCheckpoint(file string) (inventory.Snapshot, error) {
+	snapshot := inventory.Snapshot{
+		Items: []inventory.Item{},
+		Tags:  true,
+	}
+
+	hdr, s, err := readCheckpoint(file)
+	if err != nil {
+		return snapshot, err
+	} else if hdr.Hash == "" {
+		return snapshot, fmt.Errorf("%v is not a checkpoint", file)
+	} else if len(s.queue) > 0 {
+		warnf("diff", "checkpoint %v is incomplete (%v folders pending)", file, len(s.queue))
+	}
+
+	for _, f := range s.folders {
+		snapshot.Items = append(snapshot.Items, inventory.Item{
+			Type: inventory.FolderItem,
+			ID:   f.ID,
+			Name: f.Name,
+			Path: f.Path,
+			Size: f.Size,
+			Tags: f.Tags,
+		})
+	}
+
+	for _, f := range s.files {
+		snapshot.Items = append(snapshot.Items, inventory.Item{
+			Type: inventory.FileItem,
+			ID:   f.ID,
+			Name: f.FileName,
+			Path: f.FilePath,
+			Size: f.Size,
+			SHA1: f.SHA1,
+			Tags: f.Tags,
+		})
+
+		// ... only compare content if the checkpoint has the SHA1 hashes (e.g. not version 1)
+		snapshot.SHA1 = snapshot.SHA1 || f.SHA1 != ""
+	}
+
+	return snapshot, nil
+}
+
+// snapshotFromTSV loads a snapshot from a TSV file with a header row. The columns are matched
+// by name (case-insensitive) so that the list-folders (ID, Path), list-files (ID, Folder,
+// Filename) and query/checkpoint export (Type, ID, Path) layouts are all supported. Items are
+// files if there is a Filename column and folders otherwise, unless there is a Type column.
+func snapshotFromTSV(file string) (inventory.Snapshot, error) {
+	snapshot := inventory.Snapshot{
+		Items: []inventory.Item{},
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return snapshot, err
+	}
+
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comma = '\t'
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	records, err := r.ReadAll()
+	if err != nil {
+		return snapshot, fmt.Errorf("%v: %v", file, err)
+	} else if len(records) == 0 {
+		return snapshot, fmt.Errorf("%v: missing header", file)
+	}
+
+	columns := map[string]int{}
+	for i, c := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(c))] = i
+	}
+
+	field := func(record []string, column string) (string, bool) {
+		if i, ok := columns[column]; ok && i < len(record) {
+			return record[i], true
+		}
+
+		return "", false
+	}
+
+	if _, ok := columns["id"]; !ok {
+		return snapshot, fmt.Errorf("%v: missing ID column", file)
+	}
+
+	_, snapshot.Tags = columns["tags"]
+	_, snapshot.SHA1 = columns["sha1"]
+
+	for i, record := range records[1:] {
+		item := inventory.Item{
+			Type: inventory.FolderItem,
+		}
+
+		if v, _ := field(record, "id"); v == "" {
+			continue
+		} else if id, err := strconv.ParseUint(v, 10, 64); err != nil {
+			return snapshot, fmt.Errorf("%v: line %v: invalid ID '%v'", file, i+2, v)
+		} else {
+			item.ID = id
+		}
+
+		if v, ok := field(record, "type"); ok {
+			item.Type = inventory.ItemType(v)
+		} else if _, ok := columns["filename"]; ok {
+			item.Type = inventory.FileItem
+		}
+
+		if item.Type != inventory.FileItem && item.Type != inventory.FolderItem {
+			return snapshot, fmt.Errorf("%v: line %v: invalid type '%v'", file, i+2, item.Type)
+		}
+
+		if v, ok := field(record, "path"); ok {
+			item.Path = v
+		} else {
+			folder, _ := field(record, "folder")
+			filename, _ := field(record, "filename")
+			item.Path = path.Join("/", folder, filename)
+		}
+
+		if v, ok := field(record, "name"); ok {
+			item.Name = v
+		} else if v, ok := field(record, "filename"); ok {
+			item.Name = v
+		} else {
+			item.Name = path.Base(item.Path)
+		}
+
+		if v, ok := field(record, "size"); ok {
+			item.Size, _ = strconv.ParseUint(v, 10, 64)
+		}
+
+		if v, ok := field(record, "sha1"); ok {
+			item.SHA1 = v
+		}
+
+		if v, ok := field(record, "tags"); ok {
+			for _, tag := range strings.Split(v, ";") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					item.Tags = append(item.Tags, tag)
+				}
+			}
+		}
+
+		snapshot.Items = append(snapshot.Items, item)
+	}
+
+	return snapshot, nil
+}
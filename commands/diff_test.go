@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiffSnapshotFromCheckpoint(t *testing.T) {
+	tests := []struct {
+		checkpoint string
+		sha1       bool
+	}{
+		{
+			`{ "id": "qwerty", "queue": [], "folders": [ { "ID": 1, "name": "alpha", "path": "/alpha" } ], "files": [] }`,
+			false,
+		},
+		{
+			`{ "id": "qwerty", "queue": [], "folders": [], "files": [ { "ID": 2, "FileName": "report.pdf", "FilePath": "/alpha/report.pdf" } ] }`,
+			false,
+		},
+		{
+			`{ "id": "qwerty", "queue": [], "folders": [], "files": [ { "ID": 2, "FileName": "report.pdf", "FilePath": "/alpha/report.pdf", "SHA1": "da39a3ee5e6b4b0d3255bfef95601890afd80709" } ] }`,
+			true,
+		},
+	}
+
+	for _, v := range tests {
+		file := filepath.Join(t.TempDir(), ".checkpoint")
+		if err := os.WriteFile(file, []byte(v.checkpoint), 0666); err != nil {
+			t.Fatalf("Error writing checkpoint (%v)", err)
+		}
+
+		if snapshot, err := snapshot(file); err != nil {
+			t.Errorf("Error loading checkpoint snapshot (%v)", err)
+		} else if snapshot.SHA1 != v.sha1 {
+			t.Errorf("Incorrect snapshot SHA1 flag for %v - expected:%v, got:%v", v.checkpoint, v.sha1, snapshot.SHA1)
+		}
+	}
+}
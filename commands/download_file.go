@@ -0,0 +1,364 @@
+package commands
+
+import (
+	"crypto/sha1"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/twystd/unboxd/box"
+	"github.com/twystd/unboxd/credentials"
+)
+
+var DownloadFileCmd = DownloadFile{
+	command: command{
+		name:  "download-file",
+		delay: 0,
+	},
+}
+
+// DownloadFile implements the 'download-file' command, which streams the content of a Box file
+// to a local file (or stdout). The content is written to a '.part' file alongside the destination,
+// which is renamed once the download is complete and has been verified against the Box SHA1. An
+// interrupted download is resumed from the end of the '.part' file.
+type DownloadFile struct {
+	command
+	version   string
+	overwrite bool
+}
+
+// downloadSource is the subset of the Box API used to download the file content.
+type downloadSource interface {
+	DownloadFile(fileID uint64, version string, offset int64) (io.ReadCloser, int64, error)
+}
+
+// download is the Box file (or file version) to be downloaded.
+type download struct {
+	ID       uint64
+	Name     string
+	Version  string
+	Size     int64
+	SHA1     string
+	Modified time.Time
+}
+
+func (cmd *DownloadFile) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
+	flagset.StringVar(&cmd.version, "version", cmd.version, "Box ID of the file version to download (defaults to the current version)")
+	flagset.BoolVar(&cmd.overwrite, "overwrite", cmd.overwrite, "Replaces the destination file if it exists")
+
+	return flagset
+}
+
+func (cmd DownloadFile) Execute(flagset *flag.FlagSet, c credentials.ICredentials) error {
+	credentials := c["box"].(box.Credentials)
+
+	b := box.NewBox()
+	if err := b.Authenticate(credentials); err != nil {
+		return err
+	}
+
+	args := flagset.Args()
+	if len(args) < 1 {
+		return fmt.Errorf("missing file argument")
+	}
+
+	dest := ""
+	if len(args) > 1 {
+		dest = args[1]
+	}
+
+	r := resolver(&b)
+	defer save("download-file", r)
+
+	fileID, err := getFileID(r, args[0])
+	if err != nil {
+		return err
+	}
+
+	d, err := cmd.lookup(b, fileID)
+	if err != nil {
+		return err
+	}
+
+	if dest == "-" {
+		return cmd.stream(b, d, os.Stdout)
+	}
+
+	if dest == "" {
+		dest = d.Name
+	} else if info, err := os.Stat(dest); err == nil && info.IsDir() {
+		dest = filepath.Join(dest, d.Name)
+	}
+
+	if _, err := os.Stat(dest); err == nil && !cmd.overwrite {
+		return fmt.Errorf("%v already exists (use --overwrite to replace it)", dest)
+	}
+
+	if err := cmd.exec(&b, d, dest, nil); err != nil {
+		return err
+	}
+
+	infof("download-file", "%v  %v  downloaded %v bytes to %v", d.ID, args[0], d.Size, dest)
+
+	return nil
+}
+
+// lookup retrieves the name, size, SHA1 and content modification time of the file or file version.
+func (cmd DownloadFile) lookup(b box.Box, fileID uint64) (download, error) {
+	file, err := b.GetFile(fileID)
+	if err != nil {
+		return download{}, err
+	}
+
+	d := download{
+		ID:       file.ID,
+		Name:     file.Name,
+		Size:     int64(file.Size),
+		SHA1:     file.SHA1,
+		Modified: contentModified(file.ContentModifiedAt, file.ModifiedAt),
+	}
+
+	if cmd.version == "" || cmd.version == file.Version {
+		return d, nil
+	}
+
+	versions, err := b.FileVersions(fileID)
+	if err != nil {
+		return d, err
+	}
+
+	for _, v := range versions {
+		if v.ID == cmd.version {
+			d.Version = v.ID
+			d.Size = int64(v.Size)
+			d.SHA1 = v.SHA1
+			d.Modified = contentModified(v.ContentModifiedAt, v.ModifiedAt)
+
+			return d, nil
+		}
+	}
+
+	return d, fmt.Errorf("%v: no version %v", fileID, cmd.version)
+}
+
+// contentModified returns the content modification time set by the uploader, falling back to
+// the Box modification time if the content modification time is not known.
+func contentModified(contentModifiedAt, modifiedAt time.Time) time.Time {
+	if contentModifiedAt.IsZero() {
+		return modifiedAt
+	}
+
+	return contentModifiedAt
+}
+
+// exec downloads the file to a '.part' file (resuming a previous partial download if there is
+// one), verifies the SHA1, renames it to the destination file and sets the modification time.
+// The progress is displayed unless the caller reports the bytes transferred with a sent function.
+//
+// The SHA1 of the file being downloaded is recorded in a '.part.sha1' file and a '.part' file is
+// only resumed if it is a partial download of the same file content.
+func (cmd DownloadFile) exec(b downloadSource, d download, dest string, sent func(int64)) error {
+	partial := dest + ".part"
+	marker := partial + ".sha1"
+
+	f, err := os.OpenFile(partial, os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	if offset > 0 && !resumable(marker, d) {
+		warnf("download-file", "discarding %v (not a partial download of %v)", partial, d.ID)
+		offset = 0
+	} else if offset > d.Size {
+		offset = 0
+	} else if offset > 0 {
+		infof("download-file", "resuming download of %v from %v", d.ID, bytesize(offset))
+	}
+
+	if err := os.WriteFile(marker, []byte(strings.ToLower(d.SHA1)), 0640); err != nil {
+		f.Close()
+		return err
+	}
+
+	if sent != nil {
+		sent(offset)
+	}
+
+	if err := cmd.download(b, d, f, offset, sent); err != nil {
+		f.Close()
+		return err
+	} else if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := verify(partial, d.SHA1); err != nil {
+		os.Remove(partial)
+		os.Remove(marker)
+		return err
+	}
+
+	if err := os.Rename(partial, dest); err != nil {
+		return err
+	}
+
+	os.Remove(marker)
+
+	if !d.Modified.IsZero() {
+		return os.Chtimes(dest, d.Modified, d.Modified)
+	}
+
+	return nil
+}
+
+// resumable returns true if the '.part.sha1' file records the SHA1 of the file being downloaded.
+// A partial download of a file without a SHA1 cannot be verified and is not resumed.
+func resumable(marker string, d download) bool {
+	if d.SHA1 == "" {
+		return false
+	}
+
+	recorded, err := os.ReadFile(marker)
+
+	return err == nil && strings.EqualFold(strings.TrimSpace(string(recorded)), d.SHA1)
+}
+
+// download writes the file content from offset to the '.part' file. The content is written from
+// the beginning if the server does not return a partial response.
+func (cmd DownloadFile) download(b downloadSource, d download, f *os.File, offset int64, sent func(int64)) error {
+	if offset == d.Size && offset > 0 {
+		return nil
+	}
+
+	content, start, err := b.DownloadFile(d.ID, d.Version, offset)
+	if err != nil {
+		return err
+	}
+
+	defer content.Close()
+
+	if err := f.Truncate(start); err != nil {
+		return err
+	} else if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+
+	if sent != nil {
+		sent(start - offset)
+		_, err = copyWith(f, content, sent)
+	} else {
+		_, err = copyWithProgress(f, content, d.Size-start)
+	}
+
+	return err
+}
+
+// stream writes the file content to stdout and verifies the SHA1 once the content has been
+// written.
+func (cmd DownloadFile) stream(b box.Box, d download, w io.Writer) error {
+	content, _, err := b.DownloadFile(d.ID, d.Version, 0)
+	if err != nil {
+		return err
+	}
+
+	defer content.Close()
+
+	hash := sha1.New()
+	if _, err := copyWithProgress(io.MultiWriter(w, hash), content, d.Size); err != nil {
+		return err
+	}
+
+	if d.SHA1 != "" && !strings.EqualFold(fmt.Sprintf("%x", hash.Sum(nil)), d.SHA1) {
+		return fmt.Errorf("%v: SHA1 mismatch (expected %v, got %x)", d.ID, d.SHA1, hash.Sum(nil))
+	}
+
+	return nil
+}
+
+// copyWithProgress copies the content, reporting the progress in bytes and stopping with
+// ErrInterrupted on an interrupt signal.
+func copyWithProgress(w io.Writer, r io.Reader, size int64) (int64, error) {
+	progress := newProgress("download-file", "bytes")
+	progress.pending.Store(size)
+	progress.requests.Add(1)
+
+	stop := progress.start()
+	defer stop()
+
+	return copyWith(w, r, func(n int64) {
+		progress.done.Add(n)
+		progress.pending.Add(-n)
+	})
+}
+
+// copyWith copies the content, reporting the bytes copied with the sent function and stopping
+// with ErrInterrupted on an interrupt signal.
+func copyWith(w io.Writer, r io.Reader, sent func(int64)) (int64, error) {
+	buffer := make([]byte, 64*1024)
+	copied := int64(0)
+
+	for {
+		if isInterrupted() {
+			return copied, ErrInterrupted
+		}
+
+		N, err := r.Read(buffer)
+		if N > 0 {
+			if _, err := w.Write(buffer[:N]); err != nil {
+				return copied, err
+			}
+
+			copied += int64(N)
+			sent(int64(N))
+		}
+
+		if errors.Is(err, io.EOF) {
+			return copied, nil
+		} else if err != nil {
+			return copied, err
+		}
+	}
+}
+
+// verify compares the SHA1 of a file with the Box SHA1 (if any).
+func verify(file string, expected string) error {
+	if expected == "" {
+		return nil
+	}
+
+	actual, err := sha1sum(file)
+	if err != nil {
+		return err
+	} else if !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("%v: SHA1 mismatch (expected %v, got %v)", file, expected, actual)
+	}
+
+	return nil
+}
+
+// sha1sum returns the hex encoded SHA1 of a file.
+func sha1sum(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+
+	hash := sha1.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// httpSource downloads the file content from a test server, using a Range request to resume a
+// partial download.
+type httpSource struct {
+	url string
+}
+
+func (s httpSource) DownloadFile(fileID uint64, version string, offset int64) (io.ReadCloser, int64, error) {
+	rq, _ := http.NewRequest("GET", s.url, nil)
+	if offset > 0 {
+		rq.Header.Set("Range", fmt.Sprintf("bytes=%v-", offset))
+	}
+
+	response, err := http.DefaultClient.Do(rq)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch response.StatusCode {
+	case http.StatusOK:
+		return response.Body, 0, nil
+
+	case http.StatusPartialContent:
+		return response.Body, offset, nil
+
+	default:
+		response.Body.Close()
+		return nil, 0, fmt.Errorf("%v: error downloading file (%v)", fileID, response.Status)
+	}
+}
+
+func TestDownloadFileResume(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789abcdef", 1024))
+	checksum := fmt.Sprintf("%x", sha1.Sum(content))
+	modified := time.Date(2023, time.June, 1, 12, 30, 0, 0, time.UTC)
+
+	ranges := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
+		ranges = append(ranges, rq.Header.Get("Range"))
+		http.ServeContent(w, rq, "report.pdf", modified, bytes.NewReader(content))
+	}))
+
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		partial  []byte
+		marker   string
+		sha1     string
+		rangeHdr string
+		err      bool
+	}{
+		{"no partial download", nil, "", checksum, "", false},
+		{"partial download", content[:1000], checksum, checksum, "bytes=1000-", false},
+		{"partial download (uppercase SHA1)", content[:1000], strings.ToUpper(checksum), checksum, "bytes=1000-", false},
+		{"partial download of another file", []byte("qwerty"), "da39a3ee5e6b4b0d3255bfef95601890afd80709", checksum, "", false},
+		{"partial download without SHA1", content[:1000], "", checksum, "", false},
+		{"corrupted partial download", []byte("qwerty"), checksum, checksum, "bytes=6-", true},
+	}
+
+	for _, v := range tests {
+		dir := t.TempDir()
+		dest := filepath.Join(dir, "report.pdf")
+		partial := dest + ".part"
+		marker := partial + ".sha1"
+
+		if v.partial != nil {
+			if err := os.WriteFile(partial, v.partial, 0640); err != nil {
+				t.Fatalf("Error writing %v (%v)", partial, err)
+			}
+		}
+
+		if v.marker != "" {
+			if err := os.WriteFile(marker, []byte(v.marker), 0640); err != nil {
+				t.Fatalf("Error writing %v (%v)", marker, err)
+			}
+		}
+
+		ranges = ranges[:0]
+
+		cmd := DownloadFile{}
+		d := download{ID: 1189165332, Name: "report.pdf", Size: int64(len(content)), SHA1: v.sha1, Modified: modified}
+		err := cmd.exec(httpSource{server.URL}, d, dest, func(int64) {})
+
+		if len(ranges) != 1 || ranges[0] != v.rangeHdr {
+			t.Errorf("%v: incorrect Range header - expected:%q, got:%q", v.name, v.rangeHdr, ranges)
+		}
+
+		if v.err {
+			if err == nil {
+				t.Errorf("%v: expected SHA1 mismatch error", v.name)
+			}
+
+			if _, err := os.Stat(dest); err == nil {
+				t.Errorf("%v: unverified download renamed to %v", v.name, dest)
+			}
+		} else if err != nil {
+			t.Errorf("%v: unexpected error (%v)", v.name, err)
+		} else if b, err := os.ReadFile(dest); err != nil {
+			t.Errorf("%v: error reading %v (%v)", v.name, dest, err)
+		} else if string(b) != string(content) {
+			t.Errorf("%v: incorrect content - expected:%v bytes, got:%v bytes", v.name, len(content), len(b))
+		} else if info, err := os.Stat(dest); err != nil || !info.ModTime().Equal(modified) {
+			t.Errorf("%v: incorrect modification time - expected:%v, got:%v", v.name, modified, info.ModTime())
+		}
+
+		for _, f := range []string{partial, marker} {
+			if _, err := os.Stat(f); err == nil {
+				t.Errorf("%v: %v not removed", v.name, filepath.Base(f))
+			}
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/twystd/unboxd/box"
+	"github.com/twystd/unboxd/box/templates"
+	"github.com/twystd/unboxd/credentials"
+)
+
+var FindCmd = Find{
+	command: command{
+		name:  "find",
+		delay: 500 * time.Millisecond,
+	},
+
+	checkpoint: ".checkpoint.find",
+	restart:    false,
+	batch:      0,
+}
+
+type Find struct {
+	command
+	checkpoint string
+	restart    bool
+	batch      uint
+}
+
+type entry struct {
+	Type     string    `json:"type"`
+	ID       uint64    `json:"id"`
+	Name     string    `json:"name"`
+	Path     string    `json:"path"`
+	Size     uint64    `json:"size"`
+	SHA1     string    `json:"sha1,omitempty"`
+	Tags     []string  `json:"tags,omitempty"`
+	Created  time.Time `json:"created"`
+	Modified time.Time `json:"modified"`
+}
+
+// finder holds the state required to evaluate predicates and actions that call the Box API.
+type finder struct {
+	box       box.Box
+	delay     time.Duration
+	now       time.Time
+	templates map[string]templates.TemplateKey
+	metadata  map[string]bool
+	errors    uint
+}
+
+func (cmd *Find) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
+	flagset.StringVar(&cmd.checkpoint, "checkpoint", cmd.checkpoint, "Specifies the path for the checkpoint file")
+	flagset.DurationVar(&cmd.delay, "delay", cmd.delay, "Delay between multiple requests to reduce traffic to Box API")
+	flagset.BoolVar(&cmd.restart, "no-resume", cmd.restart, "Retrieves folder list from the beginning")
+	flagset.UintVar(&cmd.batch, "batch-size", cmd.batch, "Number of calls to the Box API")
+
+	return flagset
+}
+
+func (cmd Find) Execute(flagset *flag.FlagSet, c credentials.ICredentials) error {
+	credentials := c["box"].(box.Credentials)
+
+	args := flagset.Args()
+	if len(args) < 1 {
+		return fmt.Errorf("missing path argument")
+	}
+
+	start := args[0]
+	p := parser{
+		args: args[1:],
+	}
+
+	expr, err := p.parse()
+	if err != nil {
+		return err
+	}
+
+	if p.actions == 0 {
+		e := expr
+		expr = func(f *finder, x entry) bool {
+			if e(f, x) {
+				fmt.Printf("%v\n", x.Path)
+			}
+			return true
+		}
+	}
+
+	b := box.NewBox()
+	if err := b.Authenticate(credentials); err != nil {
+		return err
+	}
+
+	hash := cmd.hash("find", b.Hash(), start)
+
+	entries, err := cmd.exec(b, start, hash)
+	if err != nil {
+		return err
+	}
+
+	// ... -delete implies depth first so that folder contents are processed before the folder
+	sort.Slice(entries, func(i, j int) bool {
+		if p.delete {
+			return entries[i].Path > entries[j].Path
+		} else {
+			return entries[i].Path < entries[j].Path
+		}
+	})
+
+	f := finder{
+		box:       b,
+		delay:     cmd.delay,
+		now:       time.Now(),
+		templates: nil,
+		metadata:  map[string]bool{},
+	}
+
+	progress := newProgress("find", "entries")
+	progress.pending.Store(int64(len(entries)))
+
+	stop := progress.start()
+	defer stop()
+
+	for _, e := range entries {
+		if isInterrupted() {
+			return ErrInterrupted
+		}
+
+		expr(&f, e)
+		progress.done.Add(1)
+		progress.pending.Add(-1)
+	}
+
+	if f.errors > 0 {
+		return fmt.Errorf("%v errors", f.errors)
+	}
+
+	return nil
+}
+
+func (cmd Find) exec(b box.Box, start string, hash string) ([]entry, error) {
+	t := traversal{
+		tag:        "find",
+		checkpoint: cmd.checkpoint,
+		restart:    cmd.restart,
+		batch:      cmd.batch,
+		delay:      cmd.delay,
+		files:      true,
+	}
+
+	r := resolver(&b)
+	defer save("find", r)
+
+	folderID, prefix, err := root(r, start)
+	if err != nil {
+		return nil, err
+	}
+
+	folders, files, err := t.walk(b, folderID, prefix, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []entry{}
+	dedupe := map[string]bool{}
+
+	for _, f := range folders {
+		key := fmt.Sprintf("folder:%v", f.ID)
+		if !dedupe[key] {
+			dedupe[key] = true
+			entries = append(entries, entry{
+				Type:     "folder",
+				ID:       f.ID,
+				Name:     f.Name,
+				Path:     f.Path,
+				Size:     f.Size,
+				Tags:     f.Tags,
+				Created:  f.Created,
+				Modified: f.Modified,
+			})
+		}
+	}
+
+	for _, f := range files {
+		key := fmt.Sprintf("file:%v", f.ID)
+		if !dedupe[key] {
+			dedupe[key] = true
+			entries = append(entries, entry{
+				Type:     "file",
+				ID:       f.ID,
+				Name:     f.FileName,
+				Path:     f.FilePath,
+				Size:     f.Size,
+				SHA1:     f.SHA1,
+				Tags:     f.Tags,
+				Created:  f.Created,
+				Modified: f.Modified,
+			})
+		}
+	}
+
+	return entries, nil
+}
+
+func (f *finder) fail(e entry, err error) bool {
+	warnf("find", "%v  %v", e.Path, err)
+	f.errors++
+
+	return false
+}
+
+func (f *finder) hasMetadata(e entry, template string) bool {
+	if e.Type != "file" {
+		return false
+	}
+
+	if f.templates == nil {
+		if list, err := f.box.ListTemplates(); err != nil {
+			return f.fail(e, err)
+		} else {
+			f.templates = list
+		}
+	}
+
+	var key templates.TemplateKey
+	for k, v := range f.templates {
+		if string(v) == template || clean(k) == clean(template) {
+			key = v
+			break
+		}
+	}
+
+	if key == "" {
+		return f.fail(e, fmt.Errorf("no template found for name %v", template))
+	}
+
+	cached := fmt.Sprintf("%v:%v", e.ID, key)
+	if v, ok := f.metadata[cached]; ok {
+		return v
+	}
+
+	defer time.Sleep(f.delay)
+
+	if ok, err := f.box.HasMetadata(e.ID, key); err != nil {
+		return f.fail(e, err)
+	} else {
+		f.metadata[cached] = ok
+		return ok
+	}
+}
+
+func (f *finder) delete(e entry) bool {
+	defer time.Sleep(f.delay)
+
+	var err error
+	if e.Type == "folder" {
+		err = f.box.DeleteFolder(e.ID)
+	} else {
+		err = f.box.DeleteFile(fmt.Sprintf("%v", e.ID))
+	}
+
+	if err != nil {
+		return f.fail(e, err)
+	}
+
+	infof("find", "%v  %v deleted", e.ID, e.Path)
+
+	return true
+}
+
+func (f *finder) tag(e entry, tag string) bool {
+	if e.Type != "file" {
+		return f.fail(e, fmt.Errorf("tagging folders is not supported"))
+	}
+
+	defer time.Sleep(f.delay)
+
+	if err := f.box.TagFile(e.ID, tag); err != nil {
+		return f.fail(e, err)
+	}
+
+	infof("find", "%v  %v added tag %v", e.ID, e.Path, tag)
+
+	return true
+}
+
+func (f *finder) untag(e entry, tag string) bool {
+	if e.Type != "file" {
+		return f.fail(e, fmt.Errorf("untagging folders is not supported"))
+	}
+
+	defer time.Sleep(f.delay)
+
+	if err := f.box.UntagFile(e.ID, tag); err != nil {
+		return f.fail(e, err)
+	}
+
+	infof("find", "%v  %v removed tag %v", e.ID, e.Path, tag)
+
+	return true
+}
@@ -0,0 +1,387 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/twystd/unboxd/box/lib"
+)
+
+// expression is a node in a parsed find expression. Predicates and actions are both
+// expressions, evaluated left to right with the usual find short-circuit semantics.
+type expression func(f *finder, e entry) bool
+
+type parser struct {
+	args    []string
+	actions int
+	delete  bool
+	action  bool // true if the last primary was an action
+}
+
+// parse compiles a find expression using the find(1) grammar:
+//
+//	expr    := and { ('-o' | '-or') and }
+//	and     := unary { ['-a' | '-and'] unary }
+//	unary   := ('!' | '-not') unary | '(' expr ')' | primary
+func (p *parser) parse() (expression, error) {
+	if len(p.args) == 0 {
+		return func(f *finder, e entry) bool { return true }, nil
+	}
+
+	expr, err := p.or()
+	if err != nil {
+		return nil, err
+	} else if len(p.args) > 0 {
+		return nil, fmt.Errorf("unexpected '%v' in expression", p.args[0])
+	}
+
+	return expr, nil
+}
+
+func (p *parser) or() (expression, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for len(p.args) > 0 && (p.args[0] == "-o" || p.args[0] == "-or") {
+		p.args = p.args[1:]
+		p.action = false
+		if right, err := p.and(); err != nil {
+			return nil, err
+		} else {
+			l := left
+			left = func(f *finder, e entry) bool { return l(f, e) || right(f, e) }
+		}
+	}
+
+	return left, nil
+}
+
+func (p *parser) and() (expression, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	for len(p.args) > 0 && p.args[0] != "-o" && p.args[0] != "-or" && p.args[0] != ")" {
+		if p.args[0] == "-a" || p.args[0] == "-and" {
+			p.args = p.args[1:]
+			p.action = false
+		}
+
+		if right, err := p.unary(); err != nil {
+			return nil, err
+		} else {
+			l := left
+			left = func(f *finder, e entry) bool { return l(f, e) && right(f, e) }
+		}
+	}
+
+	return left, nil
+}
+
+func (p *parser) unary() (expression, error) {
+	if len(p.args) == 0 {
+		return nil, fmt.Errorf("incomplete expression")
+	}
+
+	switch p.args[0] {
+	case "!", "-not":
+		p.args = p.args[1:]
+		p.action = false
+		if expr, err := p.unary(); err != nil {
+			return nil, err
+		} else {
+			return func(f *finder, e entry) bool { return !expr(f, e) }, nil
+		}
+
+	case "(":
+		p.args = p.args[1:]
+		p.action = false
+		if expr, err := p.or(); err != nil {
+			return nil, err
+		} else if len(p.args) == 0 || p.args[0] != ")" {
+			return nil, fmt.Errorf("missing ')' in expression")
+		} else {
+			p.args = p.args[1:]
+			p.action = false
+			return expr, nil
+		}
+
+	default:
+		return p.primary()
+	}
+}
+
+func (p *parser) primary() (expression, error) {
+	token := p.args[0]
+	p.args = p.args[1:]
+
+	// ... -tag directly following another action is the -add-tag action e.g. -print -tag reviewed
+	if token == "-tag" && p.action {
+		token = "-add-tag"
+	}
+
+	actions := p.actions
+	defer func() { p.action = p.actions > actions }()
+
+	arg := func() (string, error) {
+		if len(p.args) == 0 {
+			return "", fmt.Errorf("missing argument to %v", token)
+		}
+
+		v := p.args[0]
+		p.args = p.args[1:]
+
+		return v, nil
+	}
+
+	switch token {
+	// ... predicates
+	case "-name", "-iname":
+		pattern, err := arg()
+		if err != nil {
+			return nil, err
+		} else if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid %v pattern '%v'", token, pattern)
+		}
+
+		if token == "-iname" {
+			pattern = strings.ToLower(pattern)
+			return func(f *finder, e entry) bool {
+				ok, _ := path.Match(pattern, strings.ToLower(e.Name))
+				return ok
+			}, nil
+		}
+
+		return func(f *finder, e entry) bool {
+			ok, _ := path.Match(pattern, e.Name)
+			return ok
+		}, nil
+
+	case "-path":
+		if pattern, err := arg(); err != nil {
+			return nil, err
+		} else {
+			g := lib.NewGlob(pattern)
+			return func(f *finder, e entry) bool { return g.Match(e.Path) }, nil
+		}
+
+	case "-type":
+		if t, err := arg(); err != nil {
+			return nil, err
+		} else if t != "f" && t != "d" {
+			return nil, fmt.Errorf("invalid -type '%v' (expected 'f' or 'd')", t)
+		} else if t == "f" {
+			return func(f *finder, e entry) bool { return e.Type == "file" }, nil
+		} else {
+			return func(f *finder, e entry) bool { return e.Type == "folder" }, nil
+		}
+
+	case "-size":
+		if v, err := arg(); err != nil {
+			return nil, err
+		} else if compare, err := size(v); err != nil {
+			return nil, err
+		} else {
+			return func(f *finder, e entry) bool { return compare(e.Size) }, nil
+		}
+
+	case "-mtime", "-ctime":
+		if v, err := arg(); err != nil {
+			return nil, err
+		} else if compare, err := days(v); err != nil {
+			return nil, fmt.Errorf("invalid %v '%v'", token, v)
+		} else if token == "-mtime" {
+			return func(f *finder, e entry) bool { return compare(f.now, e.Modified) }, nil
+		} else {
+			return func(f *finder, e entry) bool { return compare(f.now, e.Created) }, nil
+		}
+
+	case "-tag", "-tagged":
+		if tag, err := arg(); err != nil {
+			return nil, err
+		} else {
+			return func(f *finder, e entry) bool {
+				for _, t := range e.Tags {
+					if t == tag {
+						return true
+					}
+				}
+				return false
+			}, nil
+		}
+
+	case "-has-metadata":
+		if template, err := arg(); err != nil {
+			return nil, err
+		} else {
+			return func(f *finder, e entry) bool { return f.hasMetadata(e, template) }, nil
+		}
+
+	case "-sha1":
+		if sha1, err := arg(); err != nil {
+			return nil, err
+		} else {
+			return func(f *finder, e entry) bool { return e.SHA1 != "" && strings.EqualFold(e.SHA1, sha1) }, nil
+		}
+
+	case "-true":
+		return func(f *finder, e entry) bool { return true }, nil
+
+	case "-false":
+		return func(f *finder, e entry) bool { return false }, nil
+
+	// ... actions
+	case "-print":
+		p.actions++
+		return func(f *finder, e entry) bool {
+			fmt.Fprintf(os.Stdout, "%v\n", e.Path)
+			return true
+		}, nil
+
+	case "-print0":
+		p.actions++
+		return func(f *finder, e entry) bool {
+			fmt.Fprintf(os.Stdout, "%v\x00", e.Path)
+			return true
+		}, nil
+
+	case "-json":
+		p.actions++
+		return func(f *finder, e entry) bool {
+			if bytes, err := json.Marshal(e); err != nil {
+				return f.fail(e, err)
+			} else {
+				fmt.Fprintf(os.Stdout, "%v\n", string(bytes))
+				return true
+			}
+		}, nil
+
+	case "-delete":
+		p.actions++
+		p.delete = true
+		return func(f *finder, e entry) bool { return f.delete(e) }, nil
+
+	case "-add-tag":
+		p.actions++
+		if tag, err := arg(); err != nil {
+			return nil, err
+		} else {
+			return func(f *finder, e entry) bool { return f.tag(e, tag) }, nil
+		}
+
+	case "-untag":
+		p.actions++
+		if tag, err := arg(); err != nil {
+			return nil, err
+		} else {
+			return func(f *finder, e entry) bool { return f.untag(e, tag) }, nil
+		}
+
+	case "-exec":
+		p.actions++
+		command := []string{}
+		for len(p.args) > 0 && p.args[0] != ";" {
+			command = append(command, p.args[0])
+			p.args = p.args[1:]
+		}
+
+		if len(p.args) == 0 {
+			return nil, fmt.Errorf("missing ';' terminating -exec")
+		} else if len(command) == 0 {
+			return nil, fmt.Errorf("missing command for -exec")
+		}
+
+		p.args = p.args[1:]
+
+		return func(f *finder, e entry) bool {
+			args := []string{}
+			for _, a := range command {
+				a = strings.ReplaceAll(a, "{id}", fmt.Sprintf("%v", e.ID))
+				a = strings.ReplaceAll(a, "{}", e.Path)
+				args = append(args, a)
+			}
+
+			cmd := exec.Command(args[0], args[1:]...)
+			cmd.Stdin = os.Stdin
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+
+			return cmd.Run() == nil
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown predicate '%v'", token)
+	}
+}
+
+// size parses a find -size argument i.e. [+|-]N[c|k|M|G]. As with find(1) the file size is
+// rounded up to the unit before comparing and the default unit is bytes.
+func size(v string) (func(uint64) bool, error) {
+	match := regexp.MustCompile(`^([+-]?)([0-9]+)([ckMG]?)$`).FindStringSubmatch(v)
+	if match == nil {
+		return nil, fmt.Errorf("invalid -size '%v'", v)
+	}
+
+	N, err := strconv.ParseUint(match[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -size '%v'", v)
+	}
+
+	unit := map[string]float64{
+		"":  1,
+		"c": 1,
+		"k": 1024,
+		"M": 1024 * 1024,
+		"G": 1024 * 1024 * 1024,
+	}[match[3]]
+
+	units := func(size uint64) uint64 {
+		return uint64(math.Ceil(float64(size) / unit))
+	}
+
+	switch match[1] {
+	case "+":
+		return func(size uint64) bool { return units(size) > N }, nil
+	case "-":
+		return func(size uint64) bool { return units(size) < N }, nil
+	default:
+		return func(size uint64) bool { return units(size) == N }, nil
+	}
+}
+
+// days parses a find -mtime/-ctime argument i.e. [+|-]N where N is the age in whole days.
+func days(v string) (func(now, t time.Time) bool, error) {
+	match := regexp.MustCompile(`^([+-]?)([0-9]+)$`).FindStringSubmatch(v)
+	if match == nil {
+		return nil, fmt.Errorf("invalid age '%v'", v)
+	}
+
+	N, err := strconv.ParseInt(match[2], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	age := func(now, t time.Time) int64 {
+		return int64(now.Sub(t) / (24 * time.Hour))
+	}
+
+	switch match[1] {
+	case "+":
+		return func(now, t time.Time) bool { return !t.IsZero() && age(now, t) > N }, nil
+	case "-":
+		return func(now, t time.Time) bool { return !t.IsZero() && age(now, t) < N }, nil
+	default:
+		return func(now, t time.Time) bool { return !t.IsZero() && age(now, t) == N }, nil
+	}
+}
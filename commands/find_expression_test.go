@@ -0,0 +1,230 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFindExpression(t *testing.T) {
+	now := time.Date(2026, time.June, 15, 12, 0, 0, 0, time.UTC)
+
+	report := entry{
+		Type:     "file",
+		ID:       1,
+		Name:     "Report.pdf",
+		Path:     "/projects/alpha/Report.pdf",
+		Size:     2 * 1024 * 1024,
+		SHA1:     "da39a3ee5e6b4b0d3255bfef95601890afd80709",
+		Tags:     []string{"reviewed"},
+		Created:  now.Add(-30 * 24 * time.Hour),
+		Modified: now.Add(-3 * 24 * time.Hour),
+	}
+
+	archive := entry{
+		Type:     "folder",
+		ID:       2,
+		Name:     "archive",
+		Path:     "/projects/alpha/archive",
+		Created:  now.Add(-400 * 24 * time.Hour),
+		Modified: now.Add(-400 * 24 * time.Hour),
+	}
+
+	tests := []struct {
+		expression string
+		entry      entry
+		expected   bool
+	}{
+		{"", report, true},
+		{"-name *.pdf", report, true},
+		{"-name *.PDF", report, false},
+		{"-iname *.PDF", report, true},
+		{"-path /projects/**/*.pdf", report, true},
+		{"-path /photos/**", report, false},
+		{"-type f", report, true},
+		{"-type d", report, false},
+		{"-type d", archive, true},
+		{"-tag reviewed", report, true},
+		{"-tag pending", report, false},
+		{"-tagged reviewed", report, true},
+		{"-sha1 DA39A3EE5E6B4B0D3255BFEF95601890AFD80709", report, true},
+		{"-sha1 da39a3ee5e6b4b0d3255bfef95601890afd80709", archive, false},
+		{"-size +1M", report, true},
+		{"-size 2M", report, true},
+		{"-size -2M", report, false},
+		{"-mtime -7", report, true},
+		{"-mtime +7", report, false},
+		{"-ctime +7", report, true},
+		{"-true", report, true},
+		{"-false", report, false},
+
+		// ... operators
+		{"! -type d", report, true},
+		{"-not -type f", report, false},
+		{"-type f -name *.pdf", report, true},
+		{"-type f -a -name *.txt", report, false},
+		{"-type f -and -name *.pdf", report, true},
+		{"-name *.txt -o -name *.pdf", report, true},
+		{"-name *.txt -or -name *.doc", report, false},
+		{"! ( -name *.txt -o -name *.pdf )", report, false},
+		{"! -name *.txt -name *.pdf", report, true},
+
+		// ... -a binds tighter than -o
+		{"-type d -a -false -o -true", report, true},
+		{"-true -o -false -a -false", report, true},
+		{"( -true -o -false ) -a -false", report, false},
+		{"-type f -o -type d -a -false", report, true},
+		{"-type f -o -type d -a -false", archive, false},
+	}
+
+	for _, v := range tests {
+		p := parser{
+			args: strings.Fields(v.expression),
+		}
+
+		expr, err := p.parse()
+		if err != nil {
+			t.Errorf("Error parsing '%v' (%v)", v.expression, err)
+			continue
+		}
+
+		f := finder{now: now}
+		if result := expr(&f, v.entry); result != v.expected {
+			t.Errorf("Incorrect result for '%v' (%v) - expected:%v, got:%v", v.expression, v.entry.Path, v.expected, result)
+		}
+	}
+}
+
+func TestFindExpressionActions(t *testing.T) {
+	tests := []struct {
+		expression string
+		actions    int
+		delete     bool
+	}{
+		{"-name *.pdf", 0, false},
+		{"-name *.pdf -print", 1, false},
+		{"-name *.tmp -o -name *.bak -delete", 1, true},
+		{"-type f ! -tag reviewed -add-tag pending -print0", 2, false},
+		{"-type f -untag pending -exec echo {} {id} ;", 2, false},
+		{"-type f -tag reviewed -print", 1, false},
+		{"-type f -print -tag reviewed", 2, false},
+		{"-name *.tmp -delete -tag deleted", 2, true},
+		{"-print -a -tag reviewed", 1, false},
+		{"-print -o -tag reviewed", 1, false},
+		{"( -print ) -tag reviewed", 1, false},
+		{"-print ! -tag reviewed", 1, false},
+		{"-print -tagged reviewed", 1, false},
+	}
+
+	for _, v := range tests {
+		p := parser{
+			args: strings.Fields(v.expression),
+		}
+
+		if _, err := p.parse(); err != nil {
+			t.Errorf("Error parsing '%v' (%v)", v.expression, err)
+		} else if p.actions != v.actions {
+			t.Errorf("Incorrect number of actions for '%v' - expected:%v, got:%v", v.expression, v.actions, p.actions)
+		} else if p.delete != v.delete {
+			t.Errorf("Incorrect -delete flag for '%v' - expected:%v, got:%v", v.expression, v.delete, p.delete)
+		}
+	}
+}
+
+func TestFindExpressionErrors(t *testing.T) {
+	tests := []string{
+		"-name",
+		"-name [",
+		"-type x",
+		"-size 10X",
+		"-size +-10",
+		"-mtime 1.5",
+		"-unknown",
+		"( -true",
+		"-true )",
+		"!",
+		"-true -o",
+		"-exec echo {}",
+		"-exec ;",
+	}
+
+	for _, v := range tests {
+		p := parser{
+			args: strings.Fields(v),
+		}
+
+		if _, err := p.parse(); err == nil {
+			t.Errorf("Expected error parsing '%v'", v)
+		}
+	}
+}
+
+func TestFindSize(t *testing.T) {
+	tests := []struct {
+		arg      string
+		size     uint64
+		expected bool
+	}{
+		{"100", 100, true},
+		{"100", 101, false},
+		{"100c", 100, true},
+		{"+100", 101, true},
+		{"+100", 100, false},
+		{"-100", 99, true},
+		{"-100", 100, false},
+		{"1k", 1, true},
+		{"1k", 1024, true},
+		{"1k", 1025, false},
+		{"2k", 1025, true},
+		{"-1k", 0, true},
+		{"-1k", 1, false},
+		{"+1M", 1024 * 1024, false},
+		{"+1M", 1024*1024 + 1, true},
+		{"10M", 10 * 1024 * 1024, true},
+		{"1G", 1024 * 1024 * 1024, true},
+		{"+1G", 2 * 1024 * 1024 * 1024, true},
+		{"-1G", 1024 * 1024 * 1024, false},
+	}
+
+	for _, v := range tests {
+		compare, err := size(v.arg)
+		if err != nil {
+			t.Errorf("Error parsing -size '%v' (%v)", v.arg, err)
+		} else if result := compare(v.size); result != v.expected {
+			t.Errorf("Incorrect -size %v for %v bytes - expected:%v, got:%v", v.arg, v.size, v.expected, result)
+		}
+	}
+}
+
+func TestFindDays(t *testing.T) {
+	now := time.Date(2026, time.June, 15, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	tests := []struct {
+		arg      string
+		t        time.Time
+		expected bool
+	}{
+		{"0", now.Add(-1 * time.Hour), true},
+		{"0", now.Add(-25 * time.Hour), false},
+		{"1", now.Add(-25 * time.Hour), true},
+		{"1", now.Add(-2 * day), false},
+		{"+1", now.Add(-2 * day), true},
+		{"+1", now.Add(-47 * time.Hour), false},
+		{"-1", now.Add(-23 * time.Hour), true},
+		{"-1", now.Add(-24 * time.Hour), false},
+		{"-7", now.Add(-6 * day), true},
+		{"+30", now.Add(-31 * day), true},
+		{"0", time.Time{}, false},
+		{"+0", time.Time{}, false},
+	}
+
+	for _, v := range tests {
+		compare, err := days(v.arg)
+		if err != nil {
+			t.Errorf("Error parsing age '%v' (%v)", v.arg, err)
+		} else if result := compare(now, v.t); result != v.expected {
+			t.Errorf("Incorrect age %v for %v - expected:%v, got:%v", v.arg, v.t, v.expected, result)
+		}
+	}
+}
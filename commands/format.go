@@ -0,0 +1,459 @@
+package commands
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// results is the result set of a list or get command. It is rendered in the global --format
+// output format, after selecting the --columns and sorting by the --sort columns, so that the
+// output options are implemented once for all commands.
+//
+// The row values are strings, numbers, string lists (e.g. tags) or timestamps. The default
+// columns are displayed unless --columns is specified, which may select any of the columns.
+type results struct {
+	columns  []string
+	defaults []string
+	rows     [][]any
+}
+
+// output holds the per-command output options of the listing commands. The output format is
+// the global --format option, so that the listings can be piped into the bulk commands e.g.
+//
+//	unboxd list-files '/inbox/*' --format ids | unboxd tag-file --stdin reviewed
+type output struct {
+	null bool
+}
+
+func (o *output) flags(flagset *flag.FlagSet) {
+	flagset.BoolVar(&o.null, "null", o.null, "Terminates each ID with a NUL character rather than a newline (--format ids)")
+}
+
+// formats is the list of supported --format output formats.
+var formats = []string{"table", "tsv", "csv", "json", "jsonl", "yaml", "ids"}
+
+// validateFormat returns an error if the global --format option is not a supported format (or
+// one of the additional formats supported by the command) or the --template output template is
+// invalid.
+func validateFormat(extra ...string) error {
+	if templated() {
+		_, err := compileTemplate()
+		return err
+	}
+
+	if options.format == "" {
+		return nil
+	}
+
+	supported := append(append([]string{}, formats...), extra...)
+	for _, f := range supported {
+		if options.format == f {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid --format '%v' (expected %v)", options.format, strings.Join(supported, ", "))
+}
+
+// write renders the results to stdout (as a table by default) or, if file is not blank, to the
+// file (as TSV by default).
+func (r results) write(tag string, file string, null bool) error {
+	if file == "" {
+		return r.render(os.Stdout, "table", null)
+	}
+
+	infof(tag, "saving %v rows to file %v", len(r.rows), file)
+
+	if f, err := create(file); err != nil {
+		return err
+	} else {
+		defer f.Close()
+
+		return r.render(f, "tsv", null)
+	}
+}
+
+// create creates an output file, creating the parent directory if necessary.
+func create(file string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(file), 0750); err != nil {
+		return nil, err
+	}
+
+	return os.Create(file)
+}
+
+// render writes the results in the --format output format, or in the default format if --format
+// was not specified, or through the --template output template. For the 'ids' format each ID is
+// terminated by a newline or, if null is set, by a NUL character.
+func (r results) render(w io.Writer, defaultFormat string, null bool) error {
+	format := options.format
+	if format == "" {
+		format = defaultFormat
+	}
+
+	if err := validateFormat(); err != nil {
+		return err
+	}
+
+	rows, err := r.sorted()
+	if err != nil {
+		return err
+	}
+
+	if templated() {
+		if t, err := compileTemplate(); err != nil {
+			return err
+		} else {
+			b := bufio.NewWriter(w)
+			if err := t.execute(b, r.columns, rows); err != nil {
+				return err
+			}
+
+			return b.Flush()
+		}
+	}
+
+	if format == "ids" {
+		return r.ids(w, rows, null)
+	}
+
+	columns := r.defaults
+	if len(options.columns) > 0 {
+		columns = options.columns
+	}
+
+	indices := []int{}
+	names := []string{}
+	for _, c := range columns {
+		if i := r.index(c); i < 0 {
+			return fmt.Errorf("invalid column '%v' (expected %v)", c, strings.Join(r.columns, ", "))
+		} else {
+			indices = append(indices, i)
+			names = append(names, r.columns[i])
+		}
+	}
+
+	table := [][]any{}
+	for _, row := range rows {
+		record := []any{}
+		for _, i := range indices {
+			record = append(record, row[i])
+		}
+
+		table = append(table, record)
+	}
+
+	b := bufio.NewWriter(w)
+
+	switch format {
+	case "tsv":
+		err = delimited(b, '\t', names, table)
+
+	case "csv":
+		err = delimited(b, ',', names, table)
+
+	case "json":
+		err = jsonArray(b, names, table)
+
+	case "jsonl":
+		err = jsonLines(b, names, table)
+
+	case "yaml":
+		err = yaml(b, names, table)
+
+	default:
+		err = aligned(b, names, table)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return b.Flush()
+}
+
+// index returns the index of a column (case-insensitive) or -1 if there is no such column.
+func (r results) index(column string) int {
+	for i, c := range r.columns {
+		if strings.EqualFold(c, strings.TrimSpace(column)) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// sorted returns the rows sorted by the --sort columns (if any) and reversed if --reverse
+// is set. Rows with equal sort values retain their original order.
+func (r results) sorted() ([][]any, error) {
+	rows := append([][]any{}, r.rows...)
+
+	indices := []int{}
+	for _, c := range options.sort {
+		if i := r.index(c); i < 0 {
+			return nil, fmt.Errorf("invalid --sort column '%v' (expected %v)", c, strings.Join(r.columns, ", "))
+		} else {
+			indices = append(indices, i)
+		}
+	}
+
+	if len(indices) > 0 {
+		sort.SliceStable(rows, func(i, j int) bool {
+			for _, k := range indices {
+				if c := compareValues(rows[i][k], rows[j][k]); c != 0 {
+					return c < 0
+				}
+			}
+
+			return false
+		})
+	}
+
+	if options.reverse {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	return rows, nil
+}
+
+func (r results) ids(w io.Writer, rows [][]any, null bool) error {
+	column := r.index("ID")
+	if column < 0 {
+		return fmt.Errorf("missing ID column (columns are %v)", strings.Join(r.columns, ", "))
+	}
+
+	terminator := "\n"
+	if null {
+		terminator = "\x00"
+	}
+
+	b := bufio.NewWriter(w)
+	for _, row := range rows {
+		if _, err := fmt.Fprintf(b, "%v%v", text(row[column]), terminator); err != nil {
+			return err
+		}
+	}
+
+	return b.Flush()
+}
+
+func aligned(w io.Writer, columns []string, rows [][]any) error {
+	table := [][]string{columns}
+	for _, row := range rows {
+		record := []string{}
+		for _, v := range row {
+			if t, ok := v.(time.Time); ok {
+				record = append(record, timestamp(t))
+			} else {
+				record = append(record, text(v))
+			}
+		}
+
+		table = append(table, record)
+	}
+
+	widths := make([]int, len(columns))
+	for _, row := range table {
+		for i, field := range row {
+			if N := len(field); N > widths[i] {
+				widths[i] = N
+			}
+		}
+	}
+
+	for _, row := range table {
+		fields := []string{}
+		for i, field := range row {
+			fields = append(fields, fmt.Sprintf("%-*v", widths[i], field))
+		}
+
+		if _, err := fmt.Fprintln(w, strings.TrimRight(strings.Join(fields, "  "), " ")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func delimited(w io.Writer, delimiter rune, columns []string, rows [][]any) error {
+	records := [][]string{columns}
+	for _, row := range rows {
+		record := []string{}
+		for _, v := range row {
+			record = append(record, text(v))
+		}
+
+		records = append(records, record)
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Comma = delimiter
+	writer.WriteAll(records)
+
+	return writer.Error()
+}
+
+func jsonArray(w io.Writer, columns []string, rows [][]any) error {
+	if _, err := fmt.Fprint(w, "["); err != nil {
+		return err
+	}
+
+	for i, row := range rows {
+		if i > 0 {
+			fmt.Fprint(w, ",")
+		}
+
+		if object, err := jsonObject(columns, row); err != nil {
+			return err
+		} else {
+			fmt.Fprintf(w, "\n  %s", object)
+		}
+	}
+
+	if len(rows) > 0 {
+		fmt.Fprintln(w)
+	}
+
+	_, err := fmt.Fprintln(w, "]")
+
+	return err
+}
+
+func jsonLines(w io.Writer, columns []string, rows [][]any) error {
+	for _, row := range rows {
+		if object, err := jsonObject(columns, row); err != nil {
+			return err
+		} else if _, err := fmt.Fprintf(w, "%s\n", object); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// jsonObject encodes a row as a JSON object with the fields in column order.
+func jsonObject(columns []string, row []any) ([]byte, error) {
+	fields := []string{}
+	for i, c := range columns {
+		if v, err := json.Marshal(value(row[i])); err != nil {
+			return nil, err
+		} else {
+			fields = append(fields, fmt.Sprintf("%q:%s", key(c), v))
+		}
+	}
+
+	return []byte("{" + strings.Join(fields, ",") + "}"), nil
+}
+
+// yaml writes the rows as a YAML sequence of mappings. The values are encoded as JSON, which is
+// valid YAML flow syntax.
+func yaml(w io.Writer, columns []string, rows [][]any) error {
+	if len(rows) == 0 {
+		_, err := fmt.Fprintln(w, "[]")
+		return err
+	}
+
+	for _, row := range rows {
+		for i, c := range columns {
+			prefix := "  "
+			if i == 0 {
+				prefix = "- "
+			}
+
+			if v, err := json.Marshal(value(row[i])); err != nil {
+				return err
+			} else if _, err := fmt.Fprintf(w, "%v%v: %s\n", prefix, key(c), v); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// key returns the JSON/YAML field name for a column e.g. 'Modified' is 'modified'.
+func key(column string) string {
+	return strings.ReplaceAll(strings.ToLower(column), " ", "_")
+}
+
+// value returns the JSON encodable value for a field. Timestamps are RFC3339 strings (or null
+// if not set) and string lists are never null.
+func value(v any) any {
+	switch u := v.(type) {
+	case time.Time:
+		if u.IsZero() {
+			return nil
+		}
+		return u.Format(time.RFC3339)
+
+	case []string:
+		if u == nil {
+			return []string{}
+		}
+		return u
+
+	default:
+		return v
+	}
+}
+
+// text returns the text representation of a field for the table, TSV and CSV formats.
+func text(v any) string {
+	switch u := v.(type) {
+	case nil:
+		return ""
+
+	case string:
+		return u
+
+	case []string:
+		return strings.Join(u, "; ")
+
+	case time.Time:
+		if u.IsZero() {
+			return ""
+		}
+		return u.Format(time.RFC3339)
+
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// compareValues orders numbers numerically, timestamps chronologically and everything else
+// as text (numerically if both values are numeric strings).
+func compareValues(p, q any) int {
+	if t, ok := p.(time.Time); ok {
+		if u, ok := q.(time.Time); ok {
+			return t.Compare(u)
+		}
+	}
+
+	s, t := text(p), text(q)
+	if x, err := strconv.ParseFloat(s, 64); err == nil {
+		if y, err := strconv.ParseFloat(t, 64); err == nil {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+
+	return strings.Compare(s, t)
+}
@@ -0,0 +1,296 @@
+package commands
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+var fixture = results{
+	columns:  []string{"ID", "Name", "Size", "Tags", "Modified"},
+	defaults: []string{"ID", "Name", "Size"},
+	rows: [][]any{
+		{uint64(3), "c.pdf", uint64(1024), []string{"x", "y"}, time.Date(2023, time.June, 1, 12, 30, 0, 0, time.UTC)},
+		{uint64(1), `a, "b".pdf`, uint64(10), []string(nil), time.Time{}},
+		{uint64(20), "b.pdf", uint64(1024), []string{"z"}, time.Date(2023, time.June, 1, 11, 30, 0, 0, time.UTC)},
+	},
+}
+
+// withOptions sets the global output options for the duration of a test.
+func withOptions(t *testing.T, format string, columns []string, sort []string, reverse bool) {
+	saved := options
+	t.Cleanup(func() { options = saved })
+
+	options.format = format
+	options.columns = columns
+	options.sort = sort
+	options.reverse = reverse
+	options.template = ""
+	options.templateFile = ""
+}
+
+func TestRenderFormats(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{"", "" +
+			"ID  Name        Size\n" +
+			"3   c.pdf       1024\n" +
+			"1   a, \"b\".pdf  10\n" +
+			"20  b.pdf       1024\n",
+		},
+		{"table", "" +
+			"ID  Name        Size\n" +
+			"3   c.pdf       1024\n" +
+			"1   a, \"b\".pdf  10\n" +
+			"20  b.pdf       1024\n",
+		},
+		{"tsv", "" +
+			"ID\tName\tSize\n" +
+			"3\tc.pdf\t1024\n" +
+			"1\t\"a, \"\"b\"\".pdf\"\t10\n" +
+			"20\tb.pdf\t1024\n",
+		},
+		{"csv", "" +
+			"ID,Name,Size\n" +
+			"3,c.pdf,1024\n" +
+			"1,\"a, \"\"b\"\".pdf\",10\n" +
+			"20,b.pdf,1024\n",
+		},
+		{"json", "" +
+			"[\n" +
+			"  {\"id\":3,\"name\":\"c.pdf\",\"size\":1024},\n" +
+			"  {\"id\":1,\"name\":\"a, \\\"b\\\".pdf\",\"size\":10},\n" +
+			"  {\"id\":20,\"name\":\"b.pdf\",\"size\":1024}\n" +
+			"]\n",
+		},
+		{"jsonl", "" +
+			"{\"id\":3,\"name\":\"c.pdf\",\"size\":1024}\n" +
+			"{\"id\":1,\"name\":\"a, \\\"b\\\".pdf\",\"size\":10}\n" +
+			"{\"id\":20,\"name\":\"b.pdf\",\"size\":1024}\n",
+		},
+		{"yaml", "" +
+			"- id: 3\n" +
+			"  name: \"c.pdf\"\n" +
+			"  size: 1024\n" +
+			"- id: 1\n" +
+			"  name: \"a, \\\"b\\\".pdf\"\n" +
+			"  size: 10\n" +
+			"- id: 20\n" +
+			"  name: \"b.pdf\"\n" +
+			"  size: 1024\n",
+		},
+		{"ids", "3\n1\n20\n"},
+	}
+
+	for _, v := range tests {
+		withOptions(t, v.format, nil, nil, false)
+
+		var b bytes.Buffer
+		if err := fixture.render(&b, "table", false); err != nil {
+			t.Errorf("Error rendering %v (%v)", v.format, err)
+		} else if b.String() != v.expected {
+			t.Errorf("Incorrect %v output\n   expected:%q\n   got:     %q", v.format, v.expected, b.String())
+		}
+	}
+}
+
+func TestRenderColumns(t *testing.T) {
+	columns := []string{"id", " tags", "MODIFIED"}
+
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{"table", "" +
+			"ID  Tags  Modified\n" +
+			"3   x; y  2023-06-01 12:30:00\n" +
+			"1         -\n" +
+			"20  z     2023-06-01 11:30:00\n",
+		},
+		{"tsv", "" +
+			"ID\tTags\tModified\n" +
+			"3\tx; y\t2023-06-01T12:30:00Z\n" +
+			"1\t\t\n" +
+			"20\tz\t2023-06-01T11:30:00Z\n",
+		},
+		{"jsonl", "" +
+			"{\"id\":3,\"tags\":[\"x\",\"y\"],\"modified\":\"2023-06-01T12:30:00Z\"}\n" +
+			"{\"id\":1,\"tags\":[],\"modified\":null}\n" +
+			"{\"id\":20,\"tags\":[\"z\"],\"modified\":\"2023-06-01T11:30:00Z\"}\n",
+		},
+		{"yaml", "" +
+			"- id: 3\n" +
+			"  tags: [\"x\",\"y\"]\n" +
+			"  modified: \"2023-06-01T12:30:00Z\"\n" +
+			"- id: 1\n" +
+			"  tags: []\n" +
+			"  modified: null\n" +
+			"- id: 20\n" +
+			"  tags: [\"z\"]\n" +
+			"  modified: \"2023-06-01T11:30:00Z\"\n",
+		},
+	}
+
+	for _, v := range tests {
+		withOptions(t, v.format, columns, nil, false)
+
+		var b bytes.Buffer
+		if err := fixture.render(&b, "table", false); err != nil {
+			t.Errorf("Error rendering %v (%v)", v.format, err)
+		} else if b.String() != v.expected {
+			t.Errorf("Incorrect %v output\n   expected:%q\n   got:     %q", v.format, v.expected, b.String())
+		}
+	}
+}
+
+func TestRenderEmpty(t *testing.T) {
+	empty := results{columns: fixture.columns, defaults: fixture.defaults, rows: [][]any{}}
+
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{"table", "ID  Name  Size\n"},
+		{"tsv", "ID\tName\tSize\n"},
+		{"csv", "ID,Name,Size\n"},
+		{"json", "[]\n"},
+		{"jsonl", ""},
+		{"yaml", "[]\n"},
+		{"ids", ""},
+	}
+
+	for _, v := range tests {
+		withOptions(t, v.format, nil, nil, false)
+
+		var b bytes.Buffer
+		if err := empty.render(&b, "table", false); err != nil {
+			t.Errorf("Error rendering %v (%v)", v.format, err)
+		} else if b.String() != v.expected {
+			t.Errorf("Incorrect %v output\n   expected:%q\n   got:     %q", v.format, v.expected, b.String())
+		}
+	}
+}
+
+func TestRenderNullIDs(t *testing.T) {
+	withOptions(t, "ids", nil, nil, false)
+
+	var b bytes.Buffer
+	if err := fixture.render(&b, "table", true); err != nil {
+		t.Fatalf("Error rendering ids (%v)", err)
+	} else if expected := "3\x001\x0020\x00"; b.String() != expected {
+		t.Errorf("Incorrect ids output - expected:%q, got:%q", expected, b.String())
+	}
+}
+
+func TestRenderDefaultFormat(t *testing.T) {
+	withOptions(t, "", nil, nil, false)
+
+	var b bytes.Buffer
+	if err := fixture.render(&b, "tsv", false); err != nil {
+		t.Fatalf("Error rendering (%v)", err)
+	} else if !strings.HasPrefix(b.String(), "ID\tName\tSize\n") {
+		t.Errorf("Incorrect default format - expected:%v, got:%q", "tsv", b.String())
+	}
+}
+
+func TestRenderInvalid(t *testing.T) {
+	tests := []struct {
+		format   string
+		columns  []string
+		sort     []string
+		expected string
+	}{
+		{"xml", nil, nil, "invalid --format 'xml'"},
+		{"table", []string{"ID", "SHA1"}, nil, "invalid column 'SHA1'"},
+		{"table", nil, []string{"SHA1"}, "invalid --sort column 'SHA1'"},
+		{"ids", nil, nil, ""},
+	}
+
+	for _, v := range tests {
+		withOptions(t, v.format, v.columns, v.sort, false)
+
+		var b bytes.Buffer
+		err := fixture.render(&b, "table", false)
+		if v.expected == "" && err != nil {
+			t.Errorf("Unexpected error for %v (%v)", v.format, err)
+		} else if v.expected != "" && (err == nil || !strings.Contains(err.Error(), v.expected)) {
+			t.Errorf("Incorrect error - expected:%v, got:%v", v.expected, err)
+		}
+	}
+
+	// ... ids requires an ID column
+	withOptions(t, "ids", nil, nil, false)
+	r := results{columns: []string{"Name"}, defaults: []string{"Name"}, rows: [][]any{{"a.pdf"}}}
+	if err := r.render(&bytes.Buffer{}, "table", false); err == nil || !strings.Contains(err.Error(), "missing ID column") {
+		t.Errorf("Incorrect error - expected:%v, got:%v", "missing ID column", err)
+	}
+}
+
+func TestSorted(t *testing.T) {
+	tests := []struct {
+		sort     []string
+		reverse  bool
+		expected string
+	}{
+		{nil, false, "3 1 20"},
+		{nil, true, "20 1 3"},
+		{[]string{"id"}, false, "1 3 20"},
+		{[]string{"ID"}, true, "20 3 1"},
+		{[]string{"name"}, false, "1 20 3"},
+		{[]string{"size"}, false, "1 3 20"},
+		{[]string{"size", "name"}, false, "1 20 3"},
+		{[]string{"size", "name"}, true, "3 20 1"},
+		{[]string{"modified"}, false, "1 20 3"},
+		{[]string{"tags"}, false, "1 3 20"},
+	}
+
+	for _, v := range tests {
+		withOptions(t, "", nil, v.sort, v.reverse)
+
+		rows, err := fixture.sorted()
+		if err != nil {
+			t.Fatalf("Error sorting by %v (%v)", v.sort, err)
+		}
+
+		ids := []string{}
+		for _, row := range rows {
+			ids = append(ids, text(row[0]))
+		}
+
+		if strings.Join(ids, " ") != v.expected {
+			t.Errorf("Incorrect sort order for %v (reverse:%v) - expected:%v, got:%v", v.sort, v.reverse, v.expected, strings.Join(ids, " "))
+		}
+	}
+
+	// ... sorting does not reorder the original rows
+	if id := fixture.rows[0][0]; id != uint64(3) {
+		t.Errorf("Original rows reordered - expected:%v, got:%v", 3, id)
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	modified := time.Date(2023, time.June, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		p, q     any
+		expected int
+	}{
+		{uint64(2), uint64(10), -1},
+		{"2", "10", -1},
+		{"b", "a", 1},
+		{"10", "a", -1},
+		{modified, modified.Add(-time.Hour), 1},
+		{modified, modified, 0},
+		{[]string{"a", "b"}, []string{"a", "c"}, -1},
+		{nil, "a", -1},
+	}
+
+	for _, v := range tests {
+		if c := compareValues(v.p, v.q); c != v.expected {
+			t.Errorf("Incorrect comparison of %v and %v - expected:%v, got:%v", v.p, v.q, v.expected, c)
+		}
+	}
+}
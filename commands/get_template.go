@@ -157,7 +157,14 @@ func (cmd GetTemplate) save(schema *templates.Schema, file string) error {
 	}
 }
 
+// print displays the template fields in the --format output format (or through the --template
+// output template) or, if none of --format, --columns or --template is specified, the template
+// schema as indented JSON.
 func (cmd GetTemplate) print(schema *templates.Schema) error {
+	if options.format != "" || len(options.columns) > 0 || templated() {
+		return cmd.results(schema).render(os.Stdout, "table", false)
+	}
+
 	if bytes, err := json.MarshalIndent(schema, "  ", "  "); err != nil {
 		return err
 	} else {
@@ -166,3 +173,23 @@ func (cmd GetTemplate) print(schema *templates.Schema) error {
 
 	return nil
 }
+
+// results returns the template fields as a result set for the output formatter.
+func (cmd GetTemplate) results(schema *templates.Schema) results {
+	r := results{
+		columns:  []string{"Key", "Name", "Type", "Description", "Options"},
+		defaults: []string{"Key", "Name", "Type", "Description", "Options"},
+		rows:     [][]any{},
+	}
+
+	for _, f := range schema.Fields {
+		options := []string{}
+		for _, o := range f.Options {
+			options = append(options, o.Key)
+		}
+
+		r.rows = append(r.rows, []any{f.Key, f.Name, f.Type, f.Description, options})
+	}
+
+	return r
+}
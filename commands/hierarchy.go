@@ -0,0 +1,281 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// hierarchies are the list-folders output formats that draw the folder hierarchy rather than
+// a table.
+var hierarchies = []string{"tree", "dot", "mermaid"}
+
+// hierarchy is the folder hierarchy for the list-folders tree, dot (Graphviz) and mermaid
+// output formats. Listed folders are attached to the nearest listed ancestor folder and the
+// folders without a listed ancestor are the top level nodes under the root.
+type hierarchy struct {
+	root     string
+	count    int
+	nodes    []*node
+	counts   map[string]int
+	depth    uint
+	collapse uint
+}
+
+type node struct {
+	folder   folder
+	label    string
+	children []*node
+}
+
+func isHierarchy(format string) bool {
+	for _, f := range hierarchies {
+		if format == f {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hierarchy builds the folder hierarchy from the list of folders. The root is the common parent
+// of the top level folders (or '.' if they do not have a common parent).
+func (cmd ListFolders) hierarchy(folders []folder, counts map[string]int) hierarchy {
+	h := hierarchy{
+		nodes:    []*node{},
+		counts:   counts,
+		depth:    cmd.depth,
+		collapse: cmd.collapse,
+	}
+
+	sort.Slice(folders, func(i, j int) bool { return folders[i].Path < folders[j].Path })
+
+	index := map[string]*node{}
+	for _, f := range folders {
+		index[f.Path] = &node{folder: f, label: f.Name, children: []*node{}}
+	}
+
+	h.count = len(index)
+
+	parents := map[string]bool{}
+	for _, f := range folders {
+		n := index[f.Path]
+
+		parent := path.Dir(f.Path)
+		for parent != "/" && parent != "." && index[parent] == nil {
+			parent = path.Dir(parent)
+		}
+
+		if p := index[parent]; p != nil {
+			if path.Dir(f.Path) != parent {
+				n.label = strings.TrimPrefix(f.Path, parent+"/")
+			}
+
+			p.children = append(p.children, n)
+		} else {
+			h.nodes = append(h.nodes, n)
+			parents[path.Dir(f.Path)] = true
+		}
+	}
+
+	h.root = "."
+	if len(parents) == 1 {
+		for p := range parents {
+			h.root = p
+		}
+	} else {
+		for _, n := range h.nodes {
+			n.label = n.folder.Path
+		}
+	}
+
+	return h
+}
+
+// write draws the hierarchy to stdout or, if file is not blank, to the file.
+func (h hierarchy) write(tag string, file string) error {
+	var w io.Writer = os.Stdout
+	if file != "" {
+		infof(tag, "saving folder hierarchy to file %v", file)
+
+		if f, err := create(file); err != nil {
+			return err
+		} else {
+			defer f.Close()
+			w = f
+		}
+	}
+
+	b := bufio.NewWriter(w)
+
+	switch options.format {
+	case "dot":
+		h.dot(b)
+
+	case "mermaid":
+		h.mermaid(b)
+
+	default:
+		h.tree(b)
+	}
+
+	return b.Flush()
+}
+
+// visible returns the children of a node (or the top level nodes) to be drawn at the depth,
+// along with the number of collapsed siblings.
+func (h hierarchy) visible(nodes []*node, depth uint) ([]*node, int) {
+	if h.depth > 0 && depth > h.depth {
+		return nil, 0
+	}
+
+	if h.collapse > 0 && uint(len(nodes)) > h.collapse {
+		return nodes[:h.collapse], len(nodes) - int(h.collapse)
+	}
+
+	return nodes, 0
+}
+
+// annotation returns the file count and size of a folder, if the files were counted.
+func (h hierarchy) annotation(n *node) string {
+	if h.counts == nil {
+		return ""
+	}
+
+	count := h.counts[n.folder.Path]
+
+	return fmt.Sprintf("%v %v, %v", count, plural(count, "file"), bytesize(int64(n.folder.Size)))
+}
+
+// tree draws the hierarchy in the style of tree(1) e.g.
+//
+//	/
+//	├── alpha
+//	│   └── pending
+//	└── beta
+func (h hierarchy) tree(w io.Writer) {
+	var draw func(nodes []*node, indent string, depth uint)
+	draw = func(nodes []*node, indent string, depth uint) {
+		list, collapsed := h.visible(nodes, depth)
+		for i, n := range list {
+			branch, next := "├── ", "│   "
+			if i == len(list)-1 && collapsed == 0 {
+				branch, next = "└── ", "    "
+			}
+
+			if annotation := h.annotation(n); annotation != "" {
+				fmt.Fprintf(w, "%v%v%v  (%v)\n", indent, branch, n.label, annotation)
+			} else {
+				fmt.Fprintf(w, "%v%v%v\n", indent, branch, n.label)
+			}
+
+			draw(n.children, indent+next, depth+1)
+		}
+
+		if collapsed > 0 {
+			fmt.Fprintf(w, "%v└── … %v more %v\n", indent, collapsed, plural(collapsed, "folder"))
+		}
+	}
+
+	fmt.Fprintln(w, h.root)
+	draw(h.nodes, "", 1)
+
+	if h.counts != nil {
+		files := 0
+		for _, v := range h.counts {
+			files += v
+		}
+
+		fmt.Fprintf(w, "\n%v %v, %v %v\n", h.count, plural(h.count, "folder"), files, plural(files, "file"))
+	} else {
+		fmt.Fprintf(w, "\n%v %v\n", h.count, plural(h.count, "folder"))
+	}
+}
+
+// dot writes the hierarchy as a Graphviz digraph.
+func (h hierarchy) dot(w io.Writer) {
+	quote := func(s string) string {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+	}
+
+	fmt.Fprintln(w, "digraph folders {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+	fmt.Fprintln(w, "  node [shape=folder];")
+	fmt.Fprintf(w, "  root [label=%v];\n", quote(h.root))
+
+	var draw func(parent string, nodes []*node, depth uint)
+	draw = func(parent string, nodes []*node, depth uint) {
+		list, collapsed := h.visible(nodes, depth)
+		for _, n := range list {
+			id := fmt.Sprintf("f%v", n.folder.ID)
+			label := n.label
+			if annotation := h.annotation(n); annotation != "" {
+				label += "\n" + annotation
+			}
+
+			fmt.Fprintf(w, "  %v [label=%v];\n", id, quote(label))
+			fmt.Fprintf(w, "  %v -> %v;\n", parent, id)
+
+			draw(id, n.children, depth+1)
+		}
+
+		if collapsed > 0 {
+			id := fmt.Sprintf("%v_more", parent)
+			label := fmt.Sprintf("… %v more %v", collapsed, plural(collapsed, "folder"))
+
+			fmt.Fprintf(w, "  %v [label=%v, style=dashed];\n", id, quote(label))
+			fmt.Fprintf(w, "  %v -> %v;\n", parent, id)
+		}
+	}
+
+	draw("root", h.nodes, 1)
+
+	fmt.Fprintln(w, "}")
+}
+
+// mermaid writes the hierarchy as a Mermaid flowchart.
+func (h hierarchy) mermaid(w io.Writer) {
+	quote := func(s string) string {
+		return `["` + strings.NewReplacer(`"`, "#quot;", "\n", "<br/>").Replace(s) + `"]`
+	}
+
+	fmt.Fprintln(w, "graph LR")
+	fmt.Fprintf(w, "  root%v\n", quote(h.root))
+
+	var draw func(parent string, nodes []*node, depth uint)
+	draw = func(parent string, nodes []*node, depth uint) {
+		list, collapsed := h.visible(nodes, depth)
+		for _, n := range list {
+			id := fmt.Sprintf("f%v", n.folder.ID)
+			label := n.label
+			if annotation := h.annotation(n); annotation != "" {
+				label += "\n" + annotation
+			}
+
+			fmt.Fprintf(w, "  %v --> %v%v\n", parent, id, quote(label))
+
+			draw(id, n.children, depth+1)
+		}
+
+		if collapsed > 0 {
+			id := fmt.Sprintf("%v_more", parent)
+			label := fmt.Sprintf("… %v more %v", collapsed, plural(collapsed, "folder"))
+
+			fmt.Fprintf(w, "  %v -.-> %v%v\n", parent, id, quote(label))
+		}
+	}
+
+	draw("root", h.nodes, 1)
+}
+
+func plural(count int, noun string) string {
+	if count == 1 {
+		return noun
+	}
+
+	return noun + "s"
+}
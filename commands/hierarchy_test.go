@@ -0,0 +1,189 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+)
+
+// hierarchyFolders is a folder list with a folder that is re-parented to the nearest listed ancestor
+// (/alpha/x/y/deep) and folder names that need quoting in the dot and mermaid formats.
+var hierarchyFolders = []folder{
+	{ID: 4, Name: "beta", Path: "/beta", Size: 4096},
+	{ID: 1, Name: "alpha", Path: "/alpha", Size: 2048},
+	{ID: 3, Name: "deep", Path: "/alpha/x/y/deep", Size: 512},
+	{ID: 2, Name: "pending", Path: "/alpha/pending", Size: 1024},
+	{ID: 5, Name: `a"b\c`, Path: `/beta/a"b\c`},
+	{ID: 6, Name: "line\nbreak", Path: "/beta/line\nbreak"},
+	{ID: 7, Name: "zeta", Path: "/beta/zeta"},
+}
+
+// draw renders a hierarchy in one of the hierarchy output formats.
+func draw(h hierarchy, format string) string {
+	var b bytes.Buffer
+
+	switch format {
+	case "dot":
+		h.dot(&b)
+
+	case "mermaid":
+		h.mermaid(&b)
+
+	default:
+		h.tree(&b)
+	}
+
+	return b.String()
+}
+
+func TestHierarchy(t *testing.T) {
+	counts := map[string]int{"/alpha": 1, "/alpha/pending": 3}
+
+	tests := []struct {
+		format   string
+		depth    uint
+		collapse uint
+		counts   map[string]int
+		expected string
+	}{
+		{"tree", 0, 0, nil, "" +
+			"/\n" +
+			"├── alpha\n" +
+			"│   ├── pending\n" +
+			"│   └── x/y/deep\n" +
+			"└── beta\n" +
+			"    ├── a\"b\\c\n" +
+			"    ├── line\nbreak\n" +
+			"    └── zeta\n" +
+			"\n" +
+			"7 folders\n",
+		},
+		{"tree", 1, 0, nil, "" +
+			"/\n" +
+			"├── alpha\n" +
+			"└── beta\n" +
+			"\n" +
+			"7 folders\n",
+		},
+		{"tree", 0, 1, nil, "" +
+			"/\n" +
+			"├── alpha\n" +
+			"│   ├── pending\n" +
+			"│   └── … 1 more folder\n" +
+			"└── … 1 more folder\n" +
+			"\n" +
+			"7 folders\n",
+		},
+		{"tree", 2, 2, counts, "" +
+			"/\n" +
+			"├── alpha  (1 file, 2.0kB)\n" +
+			"│   ├── pending  (3 files, 1.0kB)\n" +
+			"│   └── x/y/deep  (0 files, 512B)\n" +
+			"└── beta  (0 files, 4.0kB)\n" +
+			"    ├── a\"b\\c  (0 files, 0B)\n" +
+			"    ├── line\nbreak  (0 files, 0B)\n" +
+			"    └── … 1 more folder\n" +
+			"\n" +
+			"7 folders, 4 files\n",
+		},
+		{"dot", 0, 0, nil, "" +
+			"digraph folders {\n" +
+			"  rankdir=LR;\n" +
+			"  node [shape=folder];\n" +
+			"  root [label=\"/\"];\n" +
+			"  f1 [label=\"alpha\"];\n" +
+			"  root -> f1;\n" +
+			"  f2 [label=\"pending\"];\n" +
+			"  f1 -> f2;\n" +
+			"  f3 [label=\"x/y/deep\"];\n" +
+			"  f1 -> f3;\n" +
+			"  f4 [label=\"beta\"];\n" +
+			"  root -> f4;\n" +
+			"  f5 [label=\"a\\\"b\\\\c\"];\n" +
+			"  f4 -> f5;\n" +
+			"  f6 [label=\"line\\nbreak\"];\n" +
+			"  f4 -> f6;\n" +
+			"  f7 [label=\"zeta\"];\n" +
+			"  f4 -> f7;\n" +
+			"}\n",
+		},
+		{"dot", 1, 1, counts, "" +
+			"digraph folders {\n" +
+			"  rankdir=LR;\n" +
+			"  node [shape=folder];\n" +
+			"  root [label=\"/\"];\n" +
+			"  f1 [label=\"alpha\\n1 file, 2.0kB\"];\n" +
+			"  root -> f1;\n" +
+			"  root_more [label=\"… 1 more folder\", style=dashed];\n" +
+			"  root -> root_more;\n" +
+			"}\n",
+		},
+		{"mermaid", 0, 0, nil, "" +
+			"graph LR\n" +
+			"  root[\"/\"]\n" +
+			"  root --> f1[\"alpha\"]\n" +
+			"  f1 --> f2[\"pending\"]\n" +
+			"  f1 --> f3[\"x/y/deep\"]\n" +
+			"  root --> f4[\"beta\"]\n" +
+			"  f4 --> f5[\"a#quot;b\\c\"]\n" +
+			"  f4 --> f6[\"line<br/>break\"]\n" +
+			"  f4 --> f7[\"zeta\"]\n",
+		},
+		{"mermaid", 2, 1, counts, "" +
+			"graph LR\n" +
+			"  root[\"/\"]\n" +
+			"  root --> f1[\"alpha<br/>1 file, 2.0kB\"]\n" +
+			"  f1 --> f2[\"pending<br/>3 files, 1.0kB\"]\n" +
+			"  f1 -.-> f1_more[\"… 1 more folder\"]\n" +
+			"  root -.-> root_more[\"… 1 more folder\"]\n",
+		},
+	}
+
+	for _, v := range tests {
+		cmd := ListFolders{depth: v.depth, collapse: v.collapse}
+		h := cmd.hierarchy(append([]folder{}, hierarchyFolders...), v.counts)
+
+		if s := draw(h, v.format); s != v.expected {
+			t.Errorf("Incorrect %v output (depth:%v, collapse:%v)\n   expected:%q\n   got:     %q", v.format, v.depth, v.collapse, v.expected, s)
+		}
+	}
+}
+
+func TestHierarchyRoot(t *testing.T) {
+	tests := []struct {
+		folders  []folder
+		expected string
+	}{
+		{
+			[]folder{
+				{ID: 2, Name: "pending", Path: "/alpha/pending"},
+				{ID: 8, Name: "archive", Path: "/alpha/archive"},
+			},
+			"" +
+				"/alpha\n" +
+				"├── archive\n" +
+				"└── pending\n" +
+				"\n" +
+				"2 folders\n",
+		},
+		{
+			[]folder{
+				{ID: 2, Name: "pending", Path: "/alpha/pending"},
+				{ID: 7, Name: "zeta", Path: "/beta/zeta"},
+			},
+			"" +
+				".\n" +
+				"├── /alpha/pending\n" +
+				"└── /beta/zeta\n" +
+				"\n" +
+				"2 folders\n",
+		},
+	}
+
+	for _, v := range tests {
+		h := ListFolders{}.hierarchy(v.folders, nil)
+
+		if s := draw(h, "tree"); s != v.expected {
+			t.Errorf("Incorrect tree output\n   expected:%q\n   got:     %q", v.expected, s)
+		}
+	}
+}
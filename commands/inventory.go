@@ -0,0 +1,520 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/twystd/unboxd/box"
+	"github.com/twystd/unboxd/box/events"
+	"github.com/twystd/unboxd/box/files"
+	"github.com/twystd/unboxd/box/folders"
+	"github.com/twystd/unboxd/credentials"
+	"github.com/twystd/unboxd/inventory"
+)
+
+var InventoryCmd = Inventory{
+	command: command{
+		name:  "inventory",
+		delay: 500 * time.Millisecond,
+	},
+
+	db: "",
+}
+
+// Inventory implements the 'inventory' command for maintaining the inventory database created
+// by list-folders and list-files --db.
+type Inventory struct {
+	command
+	db string
+}
+
+// change is the net effect of the events for a single file or folder.
+type change struct {
+	itemType inventory.ItemType
+	ID       uint64
+	event    string
+}
+
+// updates is the set of Box events that modify a file or folder. Other events (e.g. downloads
+// and previews) are ignored.
+var updates = map[string]bool{
+	"ITEM_CREATE":                true,
+	"ITEM_UPLOAD":                true,
+	"ITEM_COPY":                  true,
+	"ITEM_MOVE":                  true,
+	"ITEM_RENAME":                true,
+	"ITEM_MODIFY":                true,
+	"ITEM_UNDELETE_VIA_TRASH":    true,
+	"ITEM_MAKE_CURRENT_VERSION":  true,
+	"TAG_ITEM_CREATE":            true,
+	"METADATA_INSTANCE_CREATE":   true,
+	"METADATA_INSTANCE_UPDATE":   true,
+	"METADATA_INSTANCE_DELETE":   true,
+	"ITEM_TRASH":                 true,
+	"ITEM_DELETE":                true,
+	"ITEM_REMOVED_FROM_TRASH":    true,
+	"ITEM_RESTORED_FROM_TRASH":   true,
+	"COLLAB_REMOVE_COLLABORATOR": true,
+}
+
+// inventorySource is the subset of the Box API used to refresh the inventory.
+type inventorySource interface {
+	GetFile(fileID uint64) (*files.File, error)
+	GetFolder(folderID uint64) (*folders.Folder, error)
+	ListFiles(folderID uint64) ([]files.File, error)
+	ListFolders(folderID uint64) ([]folders.Folder, error)
+}
+
+var deletions = map[string]bool{
+	"ITEM_TRASH":              true,
+	"ITEM_DELETE":             true,
+	"ITEM_REMOVED_FROM_TRASH": true,
+}
+
+func (cmd *Inventory) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
+	flagset.StringVar(&cmd.db, "db", cmd.db, "Inventory database (defaults to .inventory)")
+	flagset.DurationVar(&cmd.delay, "delay", cmd.delay, "Delay between multiple requests to reduce traffic to Box API")
+
+	return flagset
+}
+
+func (cmd Inventory) Execute(flagset *flag.FlagSet, c credentials.ICredentials) error {
+	args := flagset.Args()
+	if len(args) < 1 {
+		return fmt.Errorf("missing inventory subcommand (refresh)")
+	} else if args[0] != "refresh" {
+		return fmt.Errorf("invalid inventory subcommand '%v' (expected refresh)", args[0])
+	}
+
+	credentials := c["box"].(box.Credentials)
+
+	b := box.NewBox()
+	if err := b.Authenticate(credentials); err != nil {
+		return err
+	}
+
+	v, err := openInventory(cmd.db)
+	if err != nil {
+		return err
+	}
+
+	defer v.Close()
+
+	return cmd.refresh(b, v)
+}
+
+// refresh replays the Box events since the last refresh against the inventory. The first
+// refresh replays the events since the earliest crawl started and, if the stream position has
+// expired, the crawled folders are re-crawled.
+func (cmd Inventory) refresh(b box.Box, v *inventory.Inventory) error {
+	crawls, err := v.Crawls()
+	if err != nil {
+		return err
+	} else if len(crawls) == 0 {
+		return fmt.Errorf("inventory has not been crawled (use list-folders or list-files with --db)")
+	}
+
+	stream, err := v.Stream()
+	if err != nil {
+		return err
+	}
+
+	if stream.Position == "" {
+		stream.Position = earliest(crawls)
+	}
+
+	// ... crawled by a version that did not record the stream position
+	if stream.Position == "" {
+		if position, err := b.StreamPosition(); err != nil {
+			return err
+		} else if err := v.SetStream(inventory.Stream{Position: position, Refreshed: time.Now()}); err != nil {
+			return err
+		}
+
+		infof("inventory", "initialised events stream position (subsequent refreshes will replay the events from now)")
+
+		return nil
+	}
+
+	started := time.Now()
+	changes, position, err := cmd.events(b, stream.Position)
+	if errors.Is(err, events.ErrExpired) {
+		warnf("inventory", "%v - re-crawling %v folders", err, len(crawls))
+		return cmd.recrawl(b, v, crawls)
+	} else if err != nil {
+		return err
+	}
+
+	progress := newProgress("inventory", "changes")
+	progress.pending.Store(int64(len(changes)))
+
+	stop := progress.start()
+	defer stop()
+
+	for _, c := range changes {
+		if isInterrupted() {
+			return ErrInterrupted
+		}
+
+		if err := cmd.apply(&b, v, crawls, c); err != nil {
+			return err
+		}
+
+		progress.done.Add(1)
+		progress.pending.Add(-1)
+	}
+
+	if err := v.SetStream(inventory.Stream{Position: position, Refreshed: started}); err != nil {
+		return err
+	}
+
+	infof("inventory", "applied %v changes", len(changes))
+
+	return nil
+}
+
+// events retrieves the events since the stream position and reduces them to the net change for
+// each affected file and folder, in the order in which the items were first changed.
+func (cmd Inventory) events(b box.Box, position string) ([]change, string, error) {
+	changes := []change{}
+	index := map[string]int{}
+
+	for {
+		chunk, err := b.GetEvents(position)
+		if err != nil {
+			return nil, position, err
+		}
+
+		for _, e := range chunk.Entries {
+			if e.Source.ID == 0 || !updates[e.Type] {
+				continue
+			}
+
+			c := change{
+				itemType: inventory.ItemType(e.Source.Type),
+				ID:       e.Source.ID,
+				event:    e.Type,
+			}
+
+			k := fmt.Sprintf("%v:%v", c.itemType, c.ID)
+			if i, ok := index[k]; ok {
+				changes[i].event = c.event
+			} else {
+				index[k] = len(changes)
+				changes = append(changes, c)
+			}
+		}
+
+		if len(chunk.Entries) == 0 || chunk.Position == position {
+			return changes, chunk.Position, nil
+		}
+
+		position = chunk.Position
+		time.Sleep(cmd.delay)
+	}
+}
+
+// apply updates the inventory for a changed file or folder. Items are only added if they are in
+// a folder that is in the inventory and newly added folders are crawled to retrieve their contents.
+func (cmd Inventory) apply(b inventorySource, v *inventory.Inventory, crawls []inventory.Crawl, c change) error {
+	if deletions[c.event] {
+		return v.Delete(c.itemType, c.ID)
+	}
+
+	defer time.Sleep(cmd.delay)
+
+	var item inventory.Item
+	if c.itemType == inventory.FileItem {
+		if f, err := b.GetFile(c.ID); errors.Is(err, files.ErrNotFound) {
+			return v.Delete(c.itemType, c.ID)
+		} else if err != nil {
+			return err
+		} else {
+			item = inventory.Item{
+				Type:     inventory.FileItem,
+				ID:       f.ID,
+				Parent:   f.Parent,
+				Name:     f.Name,
+				Path:     f.Path,
+				Size:     f.Size,
+				SHA1:     f.SHA1,
+				Tags:     f.Tags,
+				Created:  f.CreatedAt,
+				Modified: f.ModifiedAt,
+				Crawled:  time.Now(),
+			}
+		}
+	} else {
+		if f, err := b.GetFolder(c.ID); errors.Is(err, folders.ErrNotFound) {
+			return v.Delete(c.itemType, c.ID)
+		} else if err != nil {
+			return err
+		} else {
+			item = inventory.Item{
+				Type:     inventory.FolderItem,
+				ID:       f.ID,
+				Parent:   f.Parent,
+				Name:     f.Name,
+				Path:     f.Path,
+				Size:     f.Size,
+				Tags:     f.Tags,
+				Created:  f.CreatedAt,
+				Modified: f.ModifiedAt,
+				Crawled:  time.Now(),
+			}
+		}
+	}
+
+	// ... moved out of the inventoried folders?
+	if !inScope(v, crawls, item) {
+		return v.Delete(c.itemType, c.ID)
+	}
+
+	_, err := v.Get(item.Type, item.ID)
+	added := errors.Is(err, inventory.ErrNotFound)
+
+	// ... crawl new folders e.g. copied or restored from the trash. The folder is only added once
+	//     the contents have been crawled so that an interrupted refresh crawls it again.
+	if added && item.Type == inventory.FolderItem {
+		if err := cmd.crawl(b, v, item, withFiles(crawls)); err != nil {
+			return err
+		}
+	}
+
+	if err := v.Put(item); err != nil {
+		return err
+	}
+
+	infof("inventory", "%-8v %-6v %v  %v", c.event, item.Type, item.ID, item.Path)
+
+	return nil
+}
+
+// crawl retrieves the contents of a folder added to the inventory. Unlike list-folders and
+// list-files the folder is not recorded as a crawl i.e. it is refreshed along with the folder
+// that contains it.
+func (cmd Inventory) crawl(b inventorySource, v *inventory.Inventory, folder inventory.Item, withFiles bool) error {
+	queue := []inventory.Item{folder}
+
+	for len(queue) > 0 {
+		if isInterrupted() {
+			return ErrInterrupted
+		}
+
+		item := queue[0]
+		queue = queue[1:]
+
+		if subfolders, err := cmd.list(b, v, item.ID, item.Path, withFiles); err != nil {
+			return err
+		} else {
+			queue = append(queue, subfolders...)
+		}
+
+		time.Sleep(cmd.delay)
+	}
+
+	return nil
+}
+
+// recrawl re-crawls the inventoried folders when the events are no longer available, recording
+// the stream position beforehand so that changes made during the crawl are replayed by the next
+// refresh.
+//
+// Only folders that are new or have a different path, size or modification time are re-crawled
+// i.e. a change that does not update the size or modification time of the folders containing
+// it is not picked up (use list-folders or list-files --db for a full crawl).
+func (cmd Inventory) recrawl(b box.Box, v *inventory.Inventory, crawls []inventory.Crawl) error {
+	position, err := b.StreamPosition()
+	if err != nil {
+		return err
+	}
+
+	started := time.Now()
+	if err := cmd.rescan(&b, v, crawls); err != nil {
+		return err
+	}
+
+	return v.SetStream(inventory.Stream{Position: position, Refreshed: started})
+}
+
+// rescan re-lists the crawled folders, descending only into the subfolders that have changed
+// since they were last listed.
+func (cmd Inventory) rescan(b inventorySource, v *inventory.Inventory, crawls []inventory.Crawl) error {
+	type pending struct {
+		inventory.Item
+		files bool
+	}
+
+	queue := []pending{}
+	visited := map[uint64]bool{}
+
+	for _, c := range crawls {
+		prefix := c.Path
+		if prefix == "/" {
+			prefix = ""
+		}
+
+		queue = append(queue, pending{inventory.Item{ID: c.Root, Path: prefix}, c.Files})
+	}
+
+	progress := newProgress("inventory", "folders")
+	progress.pending.Store(int64(len(queue)))
+
+	stop := progress.start()
+	defer stop()
+
+	for len(queue) > 0 {
+		if isInterrupted() {
+			return ErrInterrupted
+		}
+
+		p := queue[0]
+		queue = queue[1:]
+
+		if visited[p.ID] {
+			progress.pending.Add(-1)
+			continue
+		}
+
+		visited[p.ID] = true
+
+		// ... the subfolders as last listed, for comparison with the current subfolders
+		previous := map[uint64]inventory.Item{}
+		if children, err := v.Children(p.ID); err != nil {
+			return err
+		} else {
+			for _, child := range children {
+				if child.Type == inventory.FolderItem {
+					previous[child.ID] = child
+				}
+			}
+		}
+
+		subfolders, err := cmd.list(b, v, p.ID, p.Path, p.files)
+		if err != nil {
+			return err
+		}
+
+		for _, f := range subfolders {
+			if u, ok := previous[f.ID]; !ok || u.Path != f.Path || u.Size != f.Size || !u.Modified.Equal(f.Modified) {
+				queue = append(queue, pending{f, p.files})
+			}
+		}
+
+		progress.done.Add(1)
+		progress.pending.Store(int64(len(queue)))
+
+		time.Sleep(cmd.delay)
+	}
+
+	infof("inventory", "re-crawled %v folders", len(visited))
+
+	return nil
+}
+
+// list retrieves the files (if the inventory includes files) and subfolders of a folder and
+// replaces the folder contents in the inventory. Returns the subfolders.
+func (cmd Inventory) list(b inventorySource, v *inventory.Inventory, folderID uint64, prefix string, withFiles bool) ([]inventory.Item, error) {
+	crawled := time.Now()
+
+	if withFiles {
+		l, err := b.ListFiles(folderID)
+		if err != nil {
+			return nil, err
+		}
+
+		items := []inventory.Item{}
+		for _, f := range l {
+			items = append(items, inventory.Item{
+				ID:       f.ID,
+				Name:     f.Name,
+				Path:     prefix + "/" + f.Name,
+				Size:     f.Size,
+				SHA1:     f.SHA1,
+				Tags:     f.Tags,
+				Created:  f.CreatedAt,
+				Modified: f.ModifiedAt,
+			})
+		}
+
+		if err := v.Refresh(folderID, inventory.FileItem, items, crawled); err != nil {
+			return nil, err
+		}
+	}
+
+	l, err := b.ListFolders(folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []inventory.Item{}
+	for _, f := range l {
+		items = append(items, inventory.Item{
+			Type:     inventory.FolderItem,
+			ID:       f.ID,
+			Parent:   folderID,
+			Name:     f.Name,
+			Path:     prefix + "/" + f.Name,
+			Size:     f.Size,
+			Tags:     f.Tags,
+			Created:  f.CreatedAt,
+			Modified: f.ModifiedAt,
+		})
+	}
+
+	if err := v.Refresh(folderID, inventory.FolderItem, items, crawled); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// inScope returns true if the item is in an inventoried folder. Files are only in scope if the
+// inventory includes files.
+func inScope(v *inventory.Inventory, crawls []inventory.Crawl, item inventory.Item) bool {
+	if item.Type == inventory.FileItem && !withFiles(crawls) {
+		return false
+	}
+
+	if item.Parent == 0 {
+		for _, c := range crawls {
+			if c.Root == 0 {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	_, err := v.Get(inventory.FolderItem, item.Parent)
+
+	return err == nil
+}
+
+// earliest returns the events stream position recorded by the earliest crawl, or a blank string
+// if none of the crawls recorded the stream position.
+func earliest(crawls []inventory.Crawl) string {
+	position := ""
+	started := time.Time{}
+
+	for _, c := range crawls {
+		if c.Position != "" && (position == "" || c.Started.Before(started)) {
+			position = c.Position
+			started = c.Started
+		}
+	}
+
+	return position
+}
+
+func withFiles(crawls []inventory.Crawl) bool {
+	for _, c := range crawls {
+		if c.Files {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,201 @@
+package commands
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/twystd/unboxd/box/files"
+	"github.com/twystd/unboxd/box/folders"
+	"github.com/twystd/unboxd/inventory"
+)
+
+// stubSource is an in-memory Box folder tree that records the folders that were listed.
+type stubSource struct {
+	files   map[uint64]files.File
+	folders map[uint64]folders.Folder
+	listed  []uint64
+}
+
+func (s *stubSource) GetFile(fileID uint64) (*files.File, error) {
+	if f, ok := s.files[fileID]; ok {
+		return &f, nil
+	}
+
+	return nil, files.ErrNotFound
+}
+
+func (s *stubSource) GetFolder(folderID uint64) (*folders.Folder, error) {
+	if f, ok := s.folders[folderID]; ok {
+		return &f, nil
+	}
+
+	return nil, folders.ErrNotFound
+}
+
+func (s *stubSource) ListFiles(folderID uint64) ([]files.File, error) {
+	list := []files.File{}
+	for _, f := range s.files {
+		if f.Parent == folderID {
+			list = append(list, f)
+		}
+	}
+
+	return list, nil
+}
+
+func (s *stubSource) ListFolders(folderID uint64) ([]folders.Folder, error) {
+	s.listed = append(s.listed, folderID)
+
+	list := []folders.Folder{}
+	for _, f := range s.folders {
+		if f.Parent == folderID {
+			list = append(list, f)
+		}
+	}
+
+	return list, nil
+}
+
+func newStubSource() *stubSource {
+	modified := time.Date(2023, time.June, 1, 12, 30, 0, 0, time.UTC)
+
+	return &stubSource{
+		folders: map[uint64]folders.Folder{
+			10: {ID: 10, Parent: 0, Name: "alpha", Path: "/alpha", Size: 1056, ModifiedAt: modified},
+			11: {ID: 11, Parent: 10, Name: "pending", Path: "/alpha/pending", Size: 1024, ModifiedAt: modified},
+			20: {ID: 20, Parent: 0, Name: "beta", Path: "/beta", Size: 4096, ModifiedAt: modified},
+		},
+		files: map[uint64]files.File{
+			101: {ID: 101, Parent: 10, Name: "notes.txt", Size: 32, ModifiedAt: modified},
+			111: {ID: 111, Parent: 11, Name: "report.pdf", Size: 1024, ModifiedAt: modified},
+			201: {ID: 201, Parent: 20, Name: "photo.jpg", Size: 4096, ModifiedAt: modified},
+		},
+	}
+}
+
+func tempInventory(t *testing.T, crawls ...inventory.Crawl) *inventory.Inventory {
+	v, err := inventory.Open(filepath.Join(t.TempDir(), ".inventory"))
+	if err != nil {
+		t.Fatalf("Error opening inventory (%v)", err)
+	}
+
+	t.Cleanup(func() { v.Close() })
+
+	for _, c := range crawls {
+		if err := v.SetCrawl(c); err != nil {
+			t.Fatalf("Error recording crawl (%v)", err)
+		}
+	}
+
+	return v
+}
+
+func walked(t *testing.T, v *inventory.Inventory) []string {
+	list := []string{}
+	if err := v.Walk("/", func(item inventory.Item) error { list = append(list, item.Path); return nil }); err != nil {
+		t.Fatalf("Error walking inventory (%v)", err)
+	}
+
+	return list
+}
+
+func TestInventoryEarliest(t *testing.T) {
+	started := time.Date(2023, time.June, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		crawls   []inventory.Crawl
+		expected string
+	}{
+		{[]inventory.Crawl{}, ""},
+		{[]inventory.Crawl{{Root: 1, Started: started}}, ""},
+		{[]inventory.Crawl{{Root: 1, Position: "1002", Started: started}}, "1002"},
+		{
+			[]inventory.Crawl{
+				{Root: 1, Position: "1002", Started: started},
+				{Root: 2, Position: "1001", Started: started.Add(-time.Hour)},
+				{Root: 3, Started: started.Add(-2 * time.Hour)},
+			},
+			"1001",
+		},
+	}
+
+	for _, v := range tests {
+		if position := earliest(v.crawls); position != v.expected {
+			t.Errorf("Incorrect stream position for %v - expected:%v, got:%v", v.crawls, v.expected, position)
+		}
+	}
+}
+
+func TestInventoryApplyCreatedFolder(t *testing.T) {
+	started := time.Date(2023, time.June, 1, 10, 0, 0, 0, time.UTC)
+	crawl := inventory.Crawl{Root: 0, Path: "/", Files: true, Position: "1001", Started: started, Completed: started}
+	v := tempInventory(t, crawl)
+	b := newStubSource()
+
+	crawls, err := v.Crawls()
+	if err != nil {
+		t.Fatalf("Error retrieving crawls (%v)", err)
+	}
+
+	cmd := Inventory{}
+	if err := cmd.apply(b, v, crawls, change{itemType: inventory.FolderItem, ID: 10, event: "ITEM_CREATE"}); err != nil {
+		t.Fatalf("Error applying change (%v)", err)
+	}
+
+	// ... new folder is crawled
+	expected := []string{"/alpha", "/alpha/notes.txt", "/alpha/pending", "/alpha/pending/report.pdf"}
+	if paths := walked(t, v); !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Incorrect inventory - expected:%v, got:%v", expected, paths)
+	}
+
+	// ... but not recorded as a crawl
+	if after, err := v.Crawls(); err != nil {
+		t.Fatalf("Error retrieving crawls (%v)", err)
+	} else if !reflect.DeepEqual(after, crawls) {
+		t.Errorf("Incorrect crawls - expected:%v, got:%v", crawls, after)
+	}
+}
+
+func TestInventoryRescan(t *testing.T) {
+	crawls := []inventory.Crawl{{Root: 0, Path: "/", Files: true}}
+	v := tempInventory(t, crawls...)
+	b := newStubSource()
+
+	cmd := Inventory{}
+	if err := cmd.rescan(b, v, crawls); err != nil {
+		t.Fatalf("Error re-crawling inventory (%v)", err)
+	} else if sort.Slice(b.listed, func(i, j int) bool { return b.listed[i] < b.listed[j] }); !reflect.DeepEqual(b.listed, []uint64{0, 10, 11, 20}) {
+		t.Errorf("Incorrect folders listed on initial crawl - expected:%v, got:%v", []uint64{0, 10, 11, 20}, b.listed)
+	}
+
+	// ... add a file to /alpha/pending and rename /beta/photo.jpg (without updating /beta)
+	modified := time.Date(2023, time.June, 2, 9, 15, 0, 0, time.UTC)
+	b.files[112] = files.File{ID: 112, Parent: 11, Name: "summary.pdf", Size: 512, ModifiedAt: modified}
+	b.folders[10] = folders.Folder{ID: 10, Parent: 0, Name: "alpha", Path: "/alpha", Size: 1568, ModifiedAt: modified}
+	b.folders[11] = folders.Folder{ID: 11, Parent: 10, Name: "pending", Path: "/alpha/pending", Size: 1536, ModifiedAt: modified}
+	b.files[201] = files.File{ID: 201, Parent: 20, Name: "image.jpg", Size: 4096, ModifiedAt: modified}
+	b.listed = nil
+
+	if err := cmd.rescan(b, v, crawls); err != nil {
+		t.Fatalf("Error re-crawling inventory (%v)", err)
+	} else if expected := []uint64{0, 10, 11}; !reflect.DeepEqual(b.listed, expected) {
+		t.Errorf("Incorrect folders re-crawled - expected:%v, got:%v", expected, b.listed)
+	}
+
+	expected := []string{
+		"/alpha",
+		"/alpha/notes.txt",
+		"/alpha/pending",
+		"/alpha/pending/report.pdf",
+		"/alpha/pending/summary.pdf",
+		"/beta",
+		"/beta/photo.jpg",
+	}
+
+	if paths := walked(t, v); !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Incorrect inventory - expected:%v, got:%v", expected, paths)
+	}
+}
@@ -0,0 +1,471 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/twystd/unboxd/box"
+	"github.com/twystd/unboxd/box/lib"
+)
+
+// job is the resumable engine shared by the bulk commands (delete-file, tag-file, untag-file,
+// retag-file and upload-file). It applies an operation to each of a list of tasks with a limited
+// number of concurrent workers and records the status of each task in a checkpoint, so that an
+// interrupted or partially failed job can be resumed without repeating the tasks that have
+// already succeeded.
+type job struct {
+	tag             string
+	checkpoint      string
+	restart         bool
+	workers         uint
+	delay           time.Duration
+	continueOnError bool
+	size            func(args []string) int64
+}
+
+// task is a single work item of a job e.g. a file to delete. Result is the outcome of a
+// successful task (e.g. the uploaded file ID) and State is the checkpointed progress of an
+// incomplete task (e.g. the upload session and uploaded parts of a large file).
+type task struct {
+	Index  int             `json:"index"`
+	Args   []string        `json:"args"`
+	Status string          `json:"status"`
+	Result string          `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	State  json.RawMessage `json:"state,omitempty"`
+}
+
+// taskState gives an operation access to the checkpointed progress of a task, so that an
+// interrupted task can continue from where it left off rather than starting over.
+type taskState struct {
+	saved      json.RawMessage
+	checkpoint func(json.RawMessage)
+}
+
+// restore unmarshals the checkpointed state of the task (if any), returning false if the task
+// has no saved state.
+func (t *taskState) restore(v any) bool {
+	if t == nil || len(t.saved) == 0 {
+		return false
+	}
+
+	return json.Unmarshal(t.saved, v) == nil
+}
+
+// save records the state of the task in the checkpoint.
+func (t *taskState) save(v any) error {
+	if t == nil || t.checkpoint == nil {
+		return nil
+	}
+
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	t.saved = bytes
+	t.checkpoint(bytes)
+
+	return nil
+}
+
+const (
+	taskPending   = "pending"
+	taskSucceeded = "ok"
+	taskFailed    = "failed"
+)
+
+// bulk holds the options shared by the bulk commands. Work items are taken from the command
+// line arguments and, with --from or --stdin, from a file or stdin. The items in a file are
+// either one per line, NUL separated (--null) or a column of a TSV file with a header row
+// (--column) e.g. the output of list-files --format tsv.
+//
+// The work items can be filtered by path with --include, --exclude and --patterns-from.
+type bulk struct {
+	from            string
+	stdin           bool
+	null            bool
+	column          string
+	checkpoint      string
+	restart         bool
+	workers         uint
+	continueOnError bool
+	include         patterns
+	exclude         patterns
+	patterns        string
+}
+
+func (b *bulk) flags(flagset *flag.FlagSet) {
+	flagset.StringVar(&b.from, "from", b.from, "File with a list of work items, one per line ('-' for stdin)")
+	flagset.BoolVar(&b.stdin, "stdin", b.stdin, "Reads the list of work items from stdin")
+	flagset.BoolVar(&b.null, "null", b.null, "Work items in the --from file or stdin are separated by NUL characters")
+	flagset.StringVar(&b.column, "column", b.column, "Reads the work items from the named column of a TSV file with a header row")
+	flagset.StringVar(&b.checkpoint, "checkpoint", b.checkpoint, "Specifies the path for the checkpoint file")
+	flagset.BoolVar(&b.restart, "no-resume", b.restart, "Restarts the job from the beginning")
+	flagset.UintVar(&b.workers, "workers", b.workers, "Maximum number of concurrent requests to the Box API")
+	flagset.BoolVar(&b.continueOnError, "continue-on-error", b.continueOnError, "Continues with the remaining items if an item fails")
+	flagset.Var(&b.include, "include", "Glob pattern for the paths of the work items to include (may be repeated)")
+	flagset.Var(&b.exclude, "exclude", "Glob pattern for the paths of the work items to exclude (may be repeated)")
+	flagset.StringVar(&b.patterns, "patterns-from", b.patterns, "File with gitignore-like include/exclude patterns")
+}
+
+func (b bulk) job(tag string, delay time.Duration) job {
+	return job{
+		tag:             tag,
+		checkpoint:      b.checkpoint,
+		restart:         b.restart,
+		workers:         b.workers,
+		delay:           delay,
+		continueOnError: b.continueOnError,
+	}
+}
+
+// selection returns the --include/--exclude/--patterns-from selection for the work items.
+func (b bulk) selection() (*lib.Selection, error) {
+	return selection(b.include, b.exclude, b.patterns)
+}
+
+// selectedFile returns true if the path of a Box file matches the selection. The file path is
+// only looked up if the selection has any patterns.
+func selectedFile(r *box.Resolver, selection *lib.Selection, fileID uint64) (bool, error) {
+	if selection.IsEmpty() {
+		return true, nil
+	}
+
+	p, err := r.Path(box.FileItem, fileID)
+	if err != nil {
+		return false, err
+	}
+
+	return selection.Match(p, lib.File), nil
+}
+
+// piped returns true if the work items are read from a file or stdin.
+func (b bulk) piped() bool {
+	return b.from != "" || b.stdin
+}
+
+// items returns the work items from the command line arguments followed by the work items in
+// the --from file or stdin (if any).
+func (b bulk) items(args []string) ([]string, error) {
+	items := append([]string{}, args...)
+
+	if !b.piped() {
+		return items, nil
+	} else if b.null && b.column != "" {
+		return nil, fmt.Errorf("--null and --column are mutually exclusive")
+	}
+
+	var r io.Reader = os.Stdin
+	if b.from != "" && b.from != "-" {
+		if f, err := os.Open(b.from); err != nil {
+			return nil, err
+		} else {
+			defer f.Close()
+			r = f
+		}
+	}
+
+	if list, err := readItems(r, b.null, b.column); err != nil {
+		return nil, err
+	} else {
+		return append(items, list...), nil
+	}
+}
+
+// readItems reads a list of work items, either one per line (ignoring blank lines and lines
+// starting with '#'), NUL separated or from the named column of a TSV file.
+func readItems(r io.Reader, null bool, column string) ([]string, error) {
+	items := []string{}
+
+	if column != "" {
+		tsv := csv.NewReader(r)
+		tsv.Comma = '\t'
+		tsv.FieldsPerRecord = -1
+		tsv.LazyQuotes = true
+
+		records, err := tsv.ReadAll()
+		if err != nil {
+			return nil, err
+		} else if len(records) == 0 {
+			return items, nil
+		}
+
+		index := -1
+		for i, c := range records[0] {
+			if strings.EqualFold(strings.TrimSpace(c), column) {
+				index = i
+			}
+		}
+
+		if index < 0 {
+			return nil, fmt.Errorf("missing column '%v' (columns are %v)", column, strings.Join(records[0], ", "))
+		}
+
+		for _, record := range records[1:] {
+			if index < len(record) {
+				if item := strings.TrimSpace(record[index]); item != "" {
+					items = append(items, item)
+				}
+			}
+		}
+
+		return items, nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	if null {
+		scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+			if i := bytes.IndexByte(data, 0); i >= 0 {
+				return i + 1, data[:i], nil
+			} else if atEOF && len(data) > 0 {
+				return len(data), data, nil
+			}
+
+			return 0, nil, nil
+		})
+	}
+
+	for scanner.Scan() {
+		item := strings.TrimSpace(scanner.Text())
+		if item != "" && (null || !strings.HasPrefix(item, "#")) {
+			items = append(items, item)
+		}
+	}
+
+	return items, scanner.Err()
+}
+
+// run executes the operation for each task. A failed task stops the job unless continueOnError
+// is set, in which case the remaining tasks are executed and the job fails once they have all
+// been attempted. The checkpoint is removed once every task has succeeded - otherwise rerunning
+// the command resumes the job, retrying the failed tasks.
+//
+// If the job has a size function, the progress is reported in bytes and the operation reports
+// the bytes transferred using the sent function. An operation that can resume a partially
+// completed task saves its progress using the task state.
+func (j job) run(hash string, items [][]string, exec func(args []string, sent func(int64), state *taskState) (string, error)) ([]task, error) {
+	hdr := checkpointHeader{
+		Hash:    hash,
+		Command: j.tag,
+	}
+
+	journal, resumed, err := openJournal(j.checkpoint, hdr, j.restart)
+	if err != nil {
+		return nil, err
+	}
+
+	defer journal.close()
+
+	tasks := make([]task, len(items))
+	for i, args := range items {
+		tasks[i] = task{Index: i, Args: args, Status: taskPending}
+	}
+
+	// ... match resumed tasks by arguments rather than position so that a job whose task list is
+	//     derived from the current state (e.g. sync) resumes correctly when the list changes
+	previous := map[string]task{}
+	for _, t := range resumed.tasks {
+		previous[strings.Join(t.Args, "\n")] = t
+	}
+
+	skipped := 0
+	for i := range tasks {
+		if t, ok := previous[strings.Join(tasks[i].Args, "\n")]; ok {
+			if t.Status == taskSucceeded {
+				t.Index = i
+				tasks[i] = t
+				skipped++
+			} else if len(t.State) > 0 {
+				tasks[i].State = t.State
+			}
+		}
+	}
+
+	if skipped > 0 {
+		infof(j.tag, "Resuming last operation (%v of %v items already completed)", skipped, len(tasks))
+	}
+
+	s := state{
+		queue:   []QueueItem{},
+		folders: []folder{},
+		files:   []file{},
+		tasks:   tasks,
+	}
+
+	if err := journal.compact(s); err != nil {
+		return tasks, err
+	}
+
+	progress := newProgress(j.tag, "items")
+	size := func(args []string) int64 { return 1 }
+	if j.size != nil {
+		progress.unit = "bytes"
+		size = j.size
+	}
+
+	for _, t := range tasks {
+		if t.Status != taskSucceeded {
+			progress.pending.Add(size(t.Args))
+		}
+	}
+
+	stop := progress.start()
+	defer stop()
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	var failure error
+
+	stopped := func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		return failure != nil
+	}
+
+	queue := make(chan int)
+	workers := j.workers
+	if workers == 0 {
+		workers = 1
+	}
+
+	for w := uint(0); w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range queue {
+				N := size(tasks[i].Args)
+				sent := atomic.Int64{}
+				callback := func(n int64) {
+					if j.size != nil {
+						sent.Add(n)
+						progress.done.Add(n)
+						progress.pending.Add(-n)
+					}
+				}
+
+				state := taskState{
+					saved: tasks[i].State,
+					checkpoint: func(bytes json.RawMessage) {
+						mutex.Lock()
+						defer mutex.Unlock()
+
+						tasks[i].State = bytes
+						journal.task(tasks[i])
+						if err := journal.checkpoint(s); err != nil {
+							warnf(j.tag, "%v", err)
+						}
+					},
+				}
+
+				progress.requests.Add(1)
+				result, err := exec(tasks[i].Args, callback, &state)
+
+				mutex.Lock()
+				if err != nil {
+					tasks[i].Status = taskFailed
+					tasks[i].Error = fmt.Sprintf("%v", err)
+					warnf(j.tag, "%v  %v", strings.Join(tasks[i].Args, " "), err)
+
+					if !j.continueOnError && failure == nil {
+						failure = err
+					}
+				} else {
+					tasks[i].Status = taskSucceeded
+					tasks[i].Result = result
+					tasks[i].Error = ""
+					tasks[i].State = nil
+				}
+
+				journal.task(tasks[i])
+				if err := journal.checkpoint(s); err != nil {
+					warnf(j.tag, "%v", err)
+				}
+				mutex.Unlock()
+
+				progress.done.Add(N - sent.Load())
+				progress.pending.Add(sent.Load() - N)
+
+				time.Sleep(j.delay)
+			}
+		}()
+	}
+
+	for i := range tasks {
+		if isInterrupted() || stopped() {
+			break
+		}
+
+		if tasks[i].Status != taskSucceeded {
+			queue <- i
+		}
+	}
+
+	close(queue)
+	wg.Wait()
+
+	j.report(tasks, skipped)
+
+	succeeded, failed := 0, 0
+	for _, t := range tasks {
+		switch t.Status {
+		case taskSucceeded:
+			succeeded++
+		case taskFailed:
+			failed++
+		}
+	}
+
+	switch {
+	case succeeded == len(tasks):
+		return tasks, journal.complete()
+
+	case failure != nil:
+		return tasks, failure
+
+	case failed+succeeded < len(tasks):
+		return tasks, ErrInterrupted
+
+	default:
+		return tasks, fmt.Errorf("%v of %v items failed", failed, len(tasks))
+	}
+}
+
+// report logs the final success/failure summary for a job with more than one task.
+func (j job) report(tasks []task, skipped int) {
+	if len(tasks) < 2 {
+		return
+	}
+
+	succeeded, failed, pending := 0, 0, 0
+	for _, t := range tasks {
+		switch t.Status {
+		case taskSucceeded:
+			succeeded++
+		case taskFailed:
+			failed++
+		default:
+			pending++
+		}
+	}
+
+	infof(j.tag, "%v items: %v succeeded (%v previously), %v failed, %v not processed", len(tasks), succeeded, skipped, failed, pending)
+
+	for _, t := range tasks {
+		if t.Status == taskFailed {
+			warnf(j.tag, "failed  %v  (%v)", strings.Join(t.Args, " "), t.Error)
+		}
+	}
+}
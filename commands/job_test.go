@@ -0,0 +1,446 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubTask is a job operation that records the tasks it executes and fails the tasks listed in
+// the fail set.
+type stubTask struct {
+	sync.Mutex
+	executed []string
+	fail     map[string]bool
+}
+
+func (s *stubTask) exec(args []string, sent func(int64), state *taskState) (string, error) {
+	item := strings.Join(args, " ")
+
+	s.Lock()
+	s.executed = append(s.executed, item)
+	s.Unlock()
+
+	if s.fail[item] {
+		return "", fmt.Errorf("%v failed", item)
+	}
+
+	return "ok:" + item, nil
+}
+
+// list returns the executed tasks in sorted order.
+func (s *stubTask) list() []string {
+	s.Lock()
+	defer s.Unlock()
+
+	list := append([]string{}, s.executed...)
+	sort.Strings(list)
+
+	return list
+}
+
+func rows(items ...string) [][]string {
+	list := [][]string{}
+	for _, item := range items {
+		list = append(list, strings.Fields(item))
+	}
+
+	return list
+}
+
+func exists(file string) bool {
+	_, err := os.Stat(file)
+
+	return err == nil
+}
+
+func TestJobRun(t *testing.T) {
+	chkpt := filepath.Join(t.TempDir(), ".checkpoint")
+	stub := stubTask{}
+
+	j := job{tag: "test", checkpoint: chkpt, workers: 1}
+	tasks, err := j.run("qwerty", rows("a", "b", "c"), stub.exec)
+	if err != nil {
+		t.Fatalf("Unexpected error (%v)", err)
+	}
+
+	if expected := []string{"a", "b", "c"}; !reflect.DeepEqual(stub.executed, expected) {
+		t.Errorf("Incorrect tasks executed - expected:%v, got:%v", expected, stub.executed)
+	}
+
+	for _, v := range tasks {
+		if v.Status != taskSucceeded || v.Result != "ok:"+v.Args[0] {
+			t.Errorf("Incorrect task status - expected:%v (%v), got:%v (%v)", taskSucceeded, "ok:"+v.Args[0], v.Status, v.Result)
+		}
+	}
+
+	if exists(chkpt) {
+		t.Errorf("Checkpoint %v not removed after completed job", chkpt)
+	}
+}
+
+func TestJobWorkers(t *testing.T) {
+	const workers = 4
+
+	var mutex sync.Mutex
+	active, peak := 0, 0
+	release := make(chan struct{})
+	once := sync.Once{}
+
+	exec := func(args []string, sent func(int64), state *taskState) (string, error) {
+		mutex.Lock()
+		active++
+		if active > peak {
+			peak = active
+		}
+
+		if active == workers {
+			once.Do(func() { close(release) })
+		}
+		mutex.Unlock()
+
+		defer func() {
+			mutex.Lock()
+			active--
+			mutex.Unlock()
+		}()
+
+		// ... the first tasks wait until all the workers are busy
+		select {
+		case <-release:
+			return "", nil
+		case <-time.After(5 * time.Second):
+			return "", fmt.Errorf("timeout waiting for %v concurrent workers", workers)
+		}
+	}
+
+	j := job{tag: "test", checkpoint: filepath.Join(t.TempDir(), ".checkpoint"), workers: workers}
+	if _, err := j.run("qwerty", rows("a", "b", "c", "d", "e", "f", "g", "h"), exec); err != nil {
+		t.Fatalf("Unexpected error (%v)", err)
+	}
+
+	if peak != workers {
+		t.Errorf("Incorrect number of concurrent workers - expected:%v, got:%v", workers, peak)
+	}
+}
+
+func TestJobStopsOnError(t *testing.T) {
+	chkpt := filepath.Join(t.TempDir(), ".checkpoint")
+	stub := stubTask{fail: map[string]bool{"b": true}}
+
+	j := job{tag: "test", checkpoint: chkpt, workers: 1}
+	tasks, err := j.run("qwerty", rows("a", "b", "c", "d"), stub.exec)
+	if err == nil || err.Error() != "b failed" {
+		t.Fatalf("Incorrect error - expected:%v, got:%v", "b failed", err)
+	}
+
+	// ... at most the task already queued when the failure occurred is executed
+	if executed := stub.list(); len(executed) > 3 || !reflect.DeepEqual(executed[:2], []string{"a", "b"}) {
+		t.Errorf("Incorrect tasks executed - expected:%v, got:%v", []string{"a", "b"}, executed)
+	}
+
+	if tasks[1].Status != taskFailed || tasks[1].Error != "b failed" {
+		t.Errorf("Incorrect failed task - expected:%v (%v), got:%v (%v)", taskFailed, "b failed", tasks[1].Status, tasks[1].Error)
+	}
+
+	if tasks[3].Status != taskPending {
+		t.Errorf("Incorrect task status - expected:%v, got:%v", taskPending, tasks[3].Status)
+	}
+
+	if !exists(chkpt) {
+		t.Errorf("Checkpoint %v removed after failed job", chkpt)
+	}
+}
+
+func TestJobContinueOnError(t *testing.T) {
+	chkpt := filepath.Join(t.TempDir(), ".checkpoint")
+	stub := stubTask{fail: map[string]bool{"b": true, "d": true}}
+
+	j := job{tag: "test", checkpoint: chkpt, workers: 2, continueOnError: true}
+	tasks, err := j.run("qwerty", rows("a", "b", "c", "d", "e"), stub.exec)
+	if err == nil || err.Error() != "2 of 5 items failed" {
+		t.Errorf("Incorrect error - expected:%v, got:%v", "2 of 5 items failed", err)
+	}
+
+	if expected := []string{"a", "b", "c", "d", "e"}; !reflect.DeepEqual(stub.list(), expected) {
+		t.Errorf("Incorrect tasks executed - expected:%v, got:%v", expected, stub.list())
+	}
+
+	expected := []string{taskSucceeded, taskFailed, taskSucceeded, taskFailed, taskSucceeded}
+	for i, v := range tasks {
+		if v.Status != expected[i] {
+			t.Errorf("Incorrect status for task %v - expected:%v, got:%v", v.Args, expected[i], v.Status)
+		}
+	}
+
+	if !exists(chkpt) {
+		t.Errorf("Checkpoint %v removed after failed job", chkpt)
+	}
+}
+
+func TestJobResume(t *testing.T) {
+	tests := []struct {
+		items    []string
+		restart  bool
+		expected []string
+	}{
+		{[]string{"a", "b", "c", "d"}, false, []string{"b", "d"}},
+		{[]string{"d", "c", "b", "a"}, false, []string{"b", "d"}},
+		{[]string{"a", "b", "c", "d", "e"}, false, []string{"b", "d", "e"}},
+		{[]string{"a", "b", "c", "d"}, true, []string{"a", "b", "c", "d"}},
+	}
+
+	for _, v := range tests {
+		chkpt := filepath.Join(t.TempDir(), ".checkpoint")
+
+		failed := stubTask{fail: map[string]bool{"b": true, "d": true}}
+		j := job{tag: "test", checkpoint: chkpt, workers: 1, continueOnError: true}
+		if _, err := j.run("qwerty", rows("a", "b", "c", "d"), failed.exec); err == nil {
+			t.Fatalf("Expected error for failed tasks")
+		}
+
+		resumed := stubTask{}
+		j = job{tag: "test", checkpoint: chkpt, workers: 1, restart: v.restart}
+		tasks, err := j.run("qwerty", rows(v.items...), resumed.exec)
+		if err != nil {
+			t.Fatalf("Unexpected error resuming job (%v)", err)
+		}
+
+		if !reflect.DeepEqual(resumed.list(), v.expected) {
+			t.Errorf("Incorrect tasks executed for %v - expected:%v, got:%v", v.items, v.expected, resumed.list())
+		}
+
+		for i, task := range tasks {
+			if task.Index != i || task.Args[0] != v.items[i] || task.Status != taskSucceeded {
+				t.Errorf("Incorrect resumed task %v - expected:%v/%v/%v, got:%v/%v/%v", i, i, v.items[i], taskSucceeded, task.Index, task.Args[0], task.Status)
+			}
+		}
+
+		if exists(chkpt) {
+			t.Errorf("Checkpoint %v not removed after resumed job", chkpt)
+		}
+	}
+}
+
+func TestJobResumeMismatchedHash(t *testing.T) {
+	chkpt := filepath.Join(t.TempDir(), ".checkpoint")
+	stub := stubTask{fail: map[string]bool{"b": true}}
+
+	j := job{tag: "test", checkpoint: chkpt, workers: 1}
+	if _, err := j.run("qwerty", rows("a", "b"), stub.exec); err == nil {
+		t.Fatalf("Expected error for failed task")
+	}
+
+	if _, err := j.run("uiop", rows("a", "b"), stub.exec); err == nil {
+		t.Errorf("Expected error resuming job with a different hash")
+	}
+}
+
+func TestJobResumeInterrupted(t *testing.T) {
+	chkpt := filepath.Join(t.TempDir(), ".checkpoint")
+	t.Cleanup(func() { interrupted.Store(false) })
+
+	interrupt := func(args []string, sent func(int64), state *taskState) (string, error) {
+		if args[0] == "b" {
+			interrupted.Store(true)
+		}
+
+		return "", nil
+	}
+
+	j := job{tag: "test", checkpoint: chkpt, workers: 1}
+	if _, err := j.run("qwerty", rows("a", "b", "c", "d"), interrupt); !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("Incorrect error - expected:%v, got:%v", ErrInterrupted, err)
+	}
+
+	interrupted.Store(false)
+
+	stub := stubTask{}
+	if _, err := j.run("qwerty", rows("a", "b", "c", "d"), stub.exec); err != nil {
+		t.Fatalf("Unexpected error resuming job (%v)", err)
+	}
+
+	// ... 'c' may have been queued before the interrupt was noticed
+	if executed := stub.list(); len(executed) < 1 || len(executed) > 2 || executed[len(executed)-1] != "d" {
+		t.Errorf("Incorrect tasks executed - expected:%v, got:%v", []string{"c", "d"}, executed)
+	}
+}
+
+func TestJobReplayTaskState(t *testing.T) {
+	type progress struct {
+		Offset int64 `json:"offset"`
+	}
+
+	chkpt := filepath.Join(t.TempDir(), ".checkpoint")
+	restored := map[string]int64{}
+
+	exec := func(args []string, sent func(int64), state *taskState) (string, error) {
+		p := progress{}
+		if state.restore(&p) {
+			restored[args[0]] = p.Offset
+			return "", nil
+		}
+
+		if err := state.save(progress{Offset: 1024}); err != nil {
+			return "", err
+		}
+
+		return "", fmt.Errorf("%v failed", args[0])
+	}
+
+	j := job{tag: "test", checkpoint: chkpt, workers: 1, continueOnError: true}
+	if _, err := j.run("qwerty", rows("a", "b"), exec); err == nil {
+		t.Fatalf("Expected error for failed tasks")
+	}
+
+	// ... the task state is replayed from the checkpoint
+	if _, err := j.run("qwerty", rows("a", "b"), exec); err != nil {
+		t.Fatalf("Unexpected error resuming job (%v)", err)
+	}
+
+	if expected := map[string]int64{"a": 1024, "b": 1024}; !reflect.DeepEqual(restored, expected) {
+		t.Errorf("Incorrect restored task state - expected:%v, got:%v", expected, restored)
+	}
+}
+
+func TestJobResumeFrom(t *testing.T) {
+	dir := t.TempDir()
+	chkpt := filepath.Join(dir, ".checkpoint")
+	list := filepath.Join(dir, "files.txt")
+	if err := os.WriteFile(list, []byte("# files\n1001\n1002\n\n1003\n"), 0666); err != nil {
+		t.Fatalf("Error writing file list (%v)", err)
+	}
+
+	run := func(stub *stubTask) error {
+		b := bulk{from: list, checkpoint: chkpt, workers: 1, continueOnError: true}
+		files, err := b.items([]string{"1000"})
+		if err != nil {
+			return err
+		}
+
+		items := [][]string{}
+		for _, f := range files {
+			items = append(items, []string{f})
+		}
+
+		_, err = b.job("test", 0).run(strings.Join(files, "\n"), items, stub.exec)
+
+		return err
+	}
+
+	failed := stubTask{fail: map[string]bool{"1002": true}}
+	if err := run(&failed); err == nil {
+		t.Fatalf("Expected error for failed task")
+	} else if expected := []string{"1000", "1001", "1002", "1003"}; !reflect.DeepEqual(failed.list(), expected) {
+		t.Errorf("Incorrect tasks executed - expected:%v, got:%v", expected, failed.list())
+	}
+
+	resumed := stubTask{}
+	if err := run(&resumed); err != nil {
+		t.Fatalf("Unexpected error resuming job (%v)", err)
+	} else if expected := []string{"1002"}; !reflect.DeepEqual(resumed.list(), expected) {
+		t.Errorf("Incorrect tasks executed - expected:%v, got:%v", expected, resumed.list())
+	}
+}
+
+func TestReadItems(t *testing.T) {
+	tests := []struct {
+		input    string
+		null     bool
+		column   string
+		expected []string
+	}{
+		{"", false, "", []string{}},
+		{"1001\n1002\n", false, "", []string{"1001", "1002"}},
+		{"1001\r\n\r\n  1002  \n1003", false, "", []string{"1001", "1002", "1003"}},
+		{"# files\n1001\n  # 1002\n1003\n", false, "", []string{"1001", "1003"}},
+		{"/alpha/a.pdf\x00/alpha/b c.pdf\x00", true, "", []string{"/alpha/a.pdf", "/alpha/b c.pdf"}},
+		{"/alpha/a.pdf\x00\x00/alpha/b\nc.pdf", true, "", []string{"/alpha/a.pdf", "/alpha/b\nc.pdf"}},
+		{"#a.pdf\x00b.pdf\x00", true, "", []string{"#a.pdf", "b.pdf"}},
+		{"ID\tPath\n1001\t/a.pdf\n1002\t/b.pdf\n", false, "ID", []string{"1001", "1002"}},
+		{"ID\tPath\n1001\t/a.pdf\n1002\t/b.pdf\n", false, "path", []string{"/a.pdf", "/b.pdf"}},
+		{" ID \tPath\n 1001 \t/a.pdf\n", false, "id", []string{"1001"}},
+		{"ID\tPath\n", false, "ID", []string{}},
+		{"", false, "ID", []string{}},
+
+		// ... malformed rows
+		{"ID\tPath\n1001\n1002\t/b.pdf\n\t/c.pdf\n", false, "Path", []string{"/b.pdf", "/c.pdf"}},
+		{"ID\tPath\n1001\t/a.pdf\textra\n1002\t/b \"quoted\".pdf\n", false, "Path", []string{"/a.pdf", `/b "quoted".pdf`}},
+	}
+
+	for _, v := range tests {
+		items, err := readItems(strings.NewReader(v.input), v.null, v.column)
+		if err != nil {
+			t.Errorf("Error reading %q (%v)", v.input, err)
+		} else if !reflect.DeepEqual(items, v.expected) {
+			t.Errorf("Incorrect items for %q - expected:%q, got:%q", v.input, v.expected, items)
+		}
+	}
+}
+
+func TestReadItemsMissingColumn(t *testing.T) {
+	if _, err := readItems(strings.NewReader("ID\tPath\n1001\t/a.pdf\n"), false, "SHA1"); err == nil {
+		t.Errorf("Expected error for missing column")
+	} else if !strings.Contains(err.Error(), "missing column 'SHA1'") {
+		t.Errorf("Incorrect error - expected:%v, got:%v", "missing column 'SHA1'", err)
+	}
+}
+
+func TestBulkItems(t *testing.T) {
+	dir := t.TempDir()
+
+	lines := filepath.Join(dir, "files.txt")
+	null := filepath.Join(dir, "files.nul")
+	tsv := filepath.Join(dir, "files.tsv")
+
+	for file, content := range map[string]string{
+		lines: "1002\n1003\n",
+		null:  "1002\x001003\x00",
+		tsv:   "ID\tPath\n1002\t/a.pdf\n1003\t/b.pdf\n",
+	} {
+		if err := os.WriteFile(file, []byte(content), 0666); err != nil {
+			t.Fatalf("Error writing %v (%v)", file, err)
+		}
+	}
+
+	tests := []struct {
+		bulk     bulk
+		args     []string
+		expected []string
+	}{
+		{bulk{}, []string{"1001"}, []string{"1001"}},
+		{bulk{from: lines}, []string{}, []string{"1002", "1003"}},
+		{bulk{from: lines}, []string{"1001"}, []string{"1001", "1002", "1003"}},
+		{bulk{from: null, null: true}, []string{"1001"}, []string{"1001", "1002", "1003"}},
+		{bulk{from: tsv, column: "ID"}, []string{"1001"}, []string{"1001", "1002", "1003"}},
+		{bulk{from: tsv, column: "Path"}, []string{}, []string{"/a.pdf", "/b.pdf"}},
+	}
+
+	for _, v := range tests {
+		if items, err := v.bulk.items(v.args); err != nil {
+			t.Errorf("Error reading items for %+v (%v)", v.bulk, err)
+		} else if !reflect.DeepEqual(items, v.expected) {
+			t.Errorf("Incorrect items for %+v - expected:%v, got:%v", v.bulk, v.expected, items)
+		}
+	}
+}
+
+func TestBulkItemsInvalid(t *testing.T) {
+	tests := []bulk{
+		{from: filepath.Join(t.TempDir(), "missing.txt")},
+		{from: "files.tsv", null: true, column: "ID"},
+	}
+
+	for _, v := range tests {
+		if _, err := v.items([]string{}); err == nil {
+			t.Errorf("Expected error for %+v", v)
+		}
+	}
+}
@@ -1,19 +1,15 @@
 package commands
 
 import (
-	"encoding/csv"
 	"flag"
-	"fmt"
-	"os"
 	"path"
-	"path/filepath"
 	"sort"
-	"strings"
 	"time"
 
 	"github.com/twystd/unboxd/box"
 	"github.com/twystd/unboxd/box/lib"
 	"github.com/twystd/unboxd/credentials"
+	"github.com/twystd/unboxd/inventory"
 )
 
 var ListFilesCmd = ListFiles{
@@ -23,7 +19,7 @@ var ListFilesCmd = ListFiles{
 	},
 
 	file:       "",
-	checkpoint: ".checkpoint",
+	checkpoint: ".checkpoint.list-files",
 	tags:       false,
 	restart:    false,
 	batch:      0,
@@ -36,6 +32,16 @@ type ListFiles struct {
 	tags       bool
 	restart    bool
 	batch      uint
+	include    patterns
+	exclude    patterns
+	patterns   string
+	root       string
+	relative   bool
+	minDepth   uint
+	maxDepth   uint
+	db         string
+	cached     bool
+	output
 }
 
 type file struct {
@@ -43,6 +49,13 @@ type file struct {
 	FileName string
 	FilePath string
 	Tags     []string
+	Size     uint64
+	SHA1     string
+	Created  time.Time
+	Modified time.Time
+
+	// ContentModified is the modification time of the file content when it was uploaded
+	ContentModified time.Time
 }
 
 var header = struct {
@@ -55,23 +68,26 @@ var header = struct {
 
 func (cmd *ListFiles) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
 	flagset.BoolVar(&cmd.tags, "tags", cmd.tags, "Include tags in folder information")
-	flagset.StringVar(&cmd.file, "file", cmd.file, "TSV file to which to write folder information")
+	flagset.StringVar(&cmd.file, "file", cmd.file, "File to which to write the listing (TSV unless --format is specified)")
 	flagset.StringVar(&cmd.checkpoint, "checkpoint", cmd.checkpoint, "Specifies the path for the checkpoint file")
 	flagset.DurationVar(&cmd.delay, "delay", cmd.delay, "Delay between multiple requests to reduce traffic to Box API")
 	flagset.BoolVar(&cmd.restart, "no-resume", cmd.restart, "Retrieves folder list from the beginning")
 	flagset.UintVar(&cmd.batch, "batch-size", cmd.batch, "Number of calls to the Box API")
+	flagset.Var(&cmd.include, "include", "Glob pattern for paths to include (may be repeated)")
+	flagset.Var(&cmd.exclude, "exclude", "Glob pattern for paths to exclude (may be repeated)")
+	flagset.StringVar(&cmd.patterns, "patterns-from", cmd.patterns, "File with gitignore-like include/exclude patterns")
+	flagset.StringVar(&cmd.root, "root", cmd.root, "Folder ID or path from which to start the listing (defaults to the root folder)")
+	flagset.BoolVar(&cmd.relative, "relative", cmd.relative, "Display paths relative to the --root folder")
+	flagset.UintVar(&cmd.minDepth, "min-depth", cmd.minDepth, "Minimum depth below the --root folder to include in the listing")
+	flagset.UintVar(&cmd.maxDepth, "max-depth", cmd.maxDepth, "Maximum depth below the --root folder to include in the listing (0 for no limit)")
+	flagset.StringVar(&cmd.db, "db", cmd.db, "Inventory database in which to store the folder tree")
+	flagset.BoolVar(&cmd.cached, "cached", cmd.cached, "Retrieves the list from the inventory database instead of Box")
+	cmd.output.flags(flagset)
 
 	return flagset
 }
 
 func (cmd ListFiles) Execute(flagset *flag.FlagSet, c credentials.ICredentials) error {
-	credentials := c["box"].(box.Credentials)
-
-	b := box.NewBox()
-	if err := b.Authenticate(credentials); err != nil {
-		return err
-	}
-
 	glob := ""
 
 	args := flagset.Args()
@@ -79,10 +95,32 @@ func (cmd ListFiles) Execute(flagset *flag.FlagSet, c credentials.ICredentials)
 		glob = args[0]
 	}
 
-	hash := cmd.hash("list-files", b.Hash(), glob)
+	if err := validateFormat(); err != nil {
+		return err
+	}
+
+	selection, err := selection(cmd.include, cmd.exclude, cmd.patterns)
+	if err != nil {
+		return err
+	}
 
 	// .. get files
-	list, err := cmd.exec(b, glob, hash)
+	var list []file
+	if cmd.cached {
+		list, err = cmd.execCached(glob, selection)
+	} else {
+		credentials := c["box"].(box.Credentials)
+
+		b := box.NewBox()
+		if err := b.Authenticate(credentials); err != nil {
+			return err
+		}
+
+		hash := cmd.hash("list-files", b.Hash(), glob, selection.String(), scope(cmd.root, cmd.relative, cmd.minDepth, cmd.maxDepth))
+
+		list, err = cmd.exec(b, glob, selection, hash)
+	}
+
 	if err != nil {
 		return err
 	}
@@ -98,24 +136,32 @@ func (cmd ListFiles) Execute(flagset *flag.FlagSet, c credentials.ICredentials)
 	}
 
 	// .. save/print
-	if cmd.file != "" {
-		return cmd.save(files)
-	} else {
-		return cmd.print(files)
-	}
+	return cmd.results(files).write("list-files", cmd.file, cmd.null)
 }
 
-func (cmd ListFiles) exec(b box.Box, glob string, hash string) ([]file, error) {
+func (cmd ListFiles) exec(b box.Box, glob string, selection *lib.Selection, hash string) ([]file, error) {
 	list := []file{}
 
-	folders, err := cmd.listFiles(b, 0, "", hash)
+	r := resolver(&b)
+	defer save("list-files", r)
+
+	folderID, prefix, err := root(r, cmd.root)
+	if err != nil {
+		return nil, err
+	}
+
+	folders, err := cmd.listFiles(b, folderID, prefix, hash)
 	if err != nil {
 		return nil, err
 	}
 
 	g := lib.NewGlob(glob)
 	for _, f := range folders {
-		if g.Match(f.FilePath) {
+		if cmd.relative {
+			f.FilePath = relative(prefix, f.FilePath)
+		}
+
+		if g.Match(f.FilePath) && selection.Match(f.FilePath, lib.File) {
 			list = append(list, f)
 		}
 	}
@@ -123,189 +169,84 @@ func (cmd ListFiles) exec(b box.Box, glob string, hash string) ([]file, error) {
 	return list, nil
 }
 
-func (cmd ListFiles) print(files []file) error {
-	sort.Slice(files, func(i, j int) bool { return files[i].FilePath < files[j].FilePath })
-
-	recalc := func(widths []int, record []string) []int {
-		for i, field := range record {
-			if N := len(field); N > widths[i] {
-				widths[i] = N
-			}
-		}
-
-		return widths
-	}
+func (cmd ListFiles) execCached(glob string, selection *lib.Selection) ([]file, error) {
+	list := []file{}
 
-	var hdr []string
-	if cmd.tags {
-		hdr = header.withTags
-	} else {
-		hdr = header.normal
+	v, err := readInventory(cmd.db)
+	if err != nil {
+		return nil, err
 	}
 
-	widths := recalc(make([]int, len(hdr)), hdr)
-	table := [][]string{hdr}
-	for _, file := range files {
-		record := cmd.toRecord(file)
-		widths = recalc(widths, record)
-		table = append(table, record)
-	}
+	defer v.Close()
 
-	columns := []string{}
-	for _, w := range widths {
-		columns = append(columns, fmt.Sprintf("%%-%vv", w))
+	_, files, err := cached("list-files", v, cmd.root, cmd.relative, cmd.minDepth, cmd.maxDepth)
+	if err != nil {
+		return nil, err
 	}
 
-	format := fmt.Sprintf("%v\n", strings.Join(columns, "  "))
-	for _, row := range table {
-		args := []any{}
-		for _, v := range row {
-			args = append(args, v)
+	g := lib.NewGlob(glob)
+	for _, f := range files {
+		if g.Match(f.FilePath) && selection.Match(f.FilePath, lib.File) {
+			list = append(list, f)
 		}
-
-		fmt.Printf(format, args...)
 	}
 
-	return nil
+	return list, nil
 }
 
-func (cmd ListFiles) save(files []file) error {
-	infof("list-files", "saving %v files to TSV file %v\n", len(files), cmd.file)
-
+// results returns the files (sorted by path) as a result set for the output formatter.
+func (cmd ListFiles) results(files []file) results {
 	sort.Slice(files, func(i, j int) bool { return files[i].FilePath < files[j].FilePath })
 
-	var hdr []string
-	if cmd.tags {
-		hdr = header.withTags
-	} else {
-		hdr = header.normal
+	r := results{
+		columns:  []string{"ID", "Folder", "Filename", "Path", "Size", "SHA1", "Tags", "Created", "Modified"},
+		defaults: header.normal,
+		rows:     [][]any{},
 	}
 
-	table := [][]string{hdr}
-	for _, file := range files {
-		record := cmd.toRecord(file)
-		table = append(table, record)
+	if cmd.tags {
+		r.defaults = header.withTags
 	}
 
-	if err := os.MkdirAll(filepath.Dir(cmd.file), 0750); err != nil {
-		return err
-	} else if f, err := os.Create(cmd.file); err != nil {
-		return err
-	} else {
-		w := csv.NewWriter(f)
-		w.Comma = '\t'
-		w.WriteAll(table)
-
-		return w.Error()
+	for _, f := range files {
+		r.rows = append(r.rows, []any{
+			f.ID,
+			path.Dir(f.FilePath),
+			f.FileName,
+			f.FilePath,
+			f.Size,
+			f.SHA1,
+			f.Tags,
+			f.Created,
+			f.Modified,
+		})
 	}
+
+	return r
 }
 
 func (cmd ListFiles) listFiles(b box.Box, folderID uint64, prefix string, hash string) ([]file, error) {
-	pipe, folders, files, err := resume(cmd.checkpoint, hash, cmd.restart)
-	if err != nil {
-		return nil, err
-	}
-
-	if len(pipe) > 0 {
-		infof("list-files", "Resuming last operation")
-	} else {
-		pipe = append(pipe, QueueItem{ID: folderID, Path: prefix})
-	}
-
-	count := uint(0)
-	tail := 0
-	for tail < len(pipe) {
-		item := pipe[tail]
-
-		// get files for current folder
-		if l, err := b.ListFiles(item.ID); err != nil {
-			if errx := checkpoint(cmd.checkpoint, pipe[tail:], folders, files, hash); errx != nil {
-				warnf("list-files", "%v", errx)
-			}
-
-			return files, err
-		} else {
-			for _, f := range l {
-				path := item.Path + "/" + f.Name
-				files = append(files, file{
-					ID:       f.ID,
-					FileName: f.Name,
-					FilePath: path,
-					Tags:     f.Tags,
-				})
-			}
-		}
-
-		// get subfolders for current folder
-		if l, err := b.ListFolders(item.ID); err != nil {
-			if errx := checkpoint(cmd.checkpoint, pipe[tail:], folders, files, hash); errx != nil {
-				warnf("list-files", "%v", errx)
-			}
-
-			return files, err
-		} else {
-			for _, f := range l {
-				path := item.Path + "/" + f.Name
-				folders = append(folders, folder{
-					ID:   f.ID,
-					Name: f.Name,
-					Tags: f.Tags,
-					Path: path,
-				})
-
-				pipe = append(pipe, QueueItem{ID: f.ID, Path: path})
-			}
-		}
-
-		count++
-		if cmd.batch != 0 && count > cmd.batch {
-			break
-		}
-
-		tail++
-		if tail < len(pipe) {
-			time.Sleep(cmd.delay)
-		}
-	}
-
-	// ... incomplete?
-	if len(pipe[tail:]) > 0 {
-		if err := checkpoint(cmd.checkpoint, pipe[tail:], folders, files, hash); err != nil {
-			return files, err
+	t := traversal{
+		tag:        "list-files",
+		checkpoint: cmd.checkpoint,
+		restart:    cmd.restart,
+		batch:      cmd.batch,
+		delay:      cmd.delay,
+		files:      true,
+		minDepth:   cmd.minDepth,
+		maxDepth:   cmd.maxDepth,
+	}
+
+	if cmd.db != "" {
+		if v, err := inventory.Open(cmd.db); err != nil {
+			return nil, err
 		} else {
-			return files, fmt.Errorf("interrupted")
+			defer v.Close()
+			t.inventory = v
 		}
 	}
 
-	// ... complete!
-	if err := checkpoint(cmd.checkpoint, []QueueItem{}, []folder{}, []file{}, ""); err != nil {
-		return files, err
-	}
-
-	return files, nil
-}
-
-func (cmd ListFiles) toRecord(f file) []string {
-	id := fmt.Sprintf("%v", f.ID)
-	folder := path.Dir(f.FilePath)
-	filename := f.FileName
-	tags := strings.Join(f.Tags, "; ")
-
-	var record []string
-	if cmd.tags {
-		record = []string{
-			id,
-			folder,
-			filename,
-			tags,
-		}
-	} else {
-		record = []string{
-			id,
-			folder,
-			filename,
-		}
-	}
+	_, files, err := t.walk(b, folderID, prefix, hash)
 
-	return record
+	return files, err
 }
@@ -1,18 +1,15 @@
 package commands
 
 import (
-	"encoding/csv"
 	"flag"
-	"fmt"
-	"os"
-	"path/filepath"
+	"path"
 	"sort"
-	"strings"
 	"time"
 
 	"github.com/twystd/unboxd/box"
 	"github.com/twystd/unboxd/box/lib"
 	"github.com/twystd/unboxd/credentials"
+	"github.com/twystd/unboxd/inventory"
 )
 
 var ListFoldersCmd = ListFolders{
@@ -22,7 +19,7 @@ var ListFoldersCmd = ListFolders{
 	},
 
 	file:       "",
-	checkpoint: ".checkpoint",
+	checkpoint: ".checkpoint.list-folders",
 	tags:       false,
 	restart:    false,
 	batch:      0,
@@ -35,34 +32,56 @@ type ListFolders struct {
 	tags       bool
 	restart    bool
 	batch      uint
+	include    patterns
+	exclude    patterns
+	patterns   string
+	root       string
+	relative   bool
+	minDepth   uint
+	maxDepth   uint
+	db         string
+	cached     bool
+	counts     bool
+	depth      uint
+	collapse   uint
+	output
 }
 
 type folder struct {
-	ID   uint64   `json:"ID"`
-	Name string   `json:"name"`
-	Path string   `json:"path"`
-	Tags []string `json:"tags,omitempty"`
+	ID       uint64    `json:"ID"`
+	Name     string    `json:"name"`
+	Path     string    `json:"path"`
+	Tags     []string  `json:"tags,omitempty"`
+	Size     uint64    `json:"size,omitempty"`
+	Created  time.Time `json:"created"`
+	Modified time.Time `json:"modified"`
 }
 
 func (cmd *ListFolders) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
 	flagset.BoolVar(&cmd.tags, "tags", cmd.tags, "Include tags in folder information")
-	flagset.StringVar(&cmd.file, "file", cmd.file, "TSV file to which to write folder information")
+	flagset.StringVar(&cmd.file, "file", cmd.file, "File to which to write the listing (TSV unless --format is specified)")
 	flagset.StringVar(&cmd.checkpoint, "checkpoint", cmd.checkpoint, "Specifies the path for the checkpoint file")
 	flagset.DurationVar(&cmd.delay, "delay", cmd.delay, "Delay between multiple requests to reduce traffic to Box API")
 	flagset.BoolVar(&cmd.restart, "no-resume", cmd.restart, "Retrieves folder list from the beginning")
 	flagset.UintVar(&cmd.batch, "batch-size", cmd.batch, "Number of calls to the Box API")
+	flagset.Var(&cmd.include, "include", "Glob pattern for paths to include (may be repeated)")
+	flagset.Var(&cmd.exclude, "exclude", "Glob pattern for paths to exclude (may be repeated)")
+	flagset.StringVar(&cmd.patterns, "patterns-from", cmd.patterns, "File with gitignore-like include/exclude patterns")
+	flagset.StringVar(&cmd.root, "root", cmd.root, "Folder ID or path from which to start the listing (defaults to the root folder)")
+	flagset.BoolVar(&cmd.relative, "relative", cmd.relative, "Display paths relative to the --root folder")
+	flagset.UintVar(&cmd.minDepth, "min-depth", cmd.minDepth, "Minimum depth below the --root folder to include in the listing")
+	flagset.UintVar(&cmd.maxDepth, "max-depth", cmd.maxDepth, "Maximum depth below the --root folder to include in the listing (0 for no limit)")
+	flagset.StringVar(&cmd.db, "db", cmd.db, "Inventory database in which to store the folder tree")
+	flagset.BoolVar(&cmd.cached, "cached", cmd.cached, "Retrieves the list from the inventory database instead of Box")
+	flagset.BoolVar(&cmd.counts, "counts", cmd.counts, "Includes the number of files and size of each folder (lists the files in each folder)")
+	flagset.UintVar(&cmd.depth, "depth", cmd.depth, "Maximum depth of the --format tree, dot or mermaid hierarchy (0 for no limit)")
+	flagset.UintVar(&cmd.collapse, "collapse", cmd.collapse, "Collapses sibling folders in excess of N in the --format tree, dot or mermaid hierarchy (0 for no limit)")
+	cmd.output.flags(flagset)
 
 	return flagset
 }
 
 func (cmd ListFolders) Execute(flagset *flag.FlagSet, c credentials.ICredentials) error {
-	credentials := c["box"].(box.Credentials)
-
-	b := box.NewBox()
-	if err := b.Authenticate(credentials); err != nil {
-		return err
-	}
-
 	var base string
 
 	args := flagset.Args()
@@ -72,10 +91,38 @@ func (cmd ListFolders) Execute(flagset *flag.FlagSet, c credentials.ICredentials
 		base = ""
 	}
 
-	hash := cmd.hash("list-folders", b.Hash(), base)
+	if err := validateFormat(hierarchies...); err != nil {
+		return err
+	}
+
+	selection, err := selection(cmd.include, cmd.exclude, cmd.patterns)
+	if err != nil {
+		return err
+	}
 
 	// .. get folder list
-	list, err := cmd.exec(b, base, hash)
+	var list []folder
+	var files []file
+	if cmd.cached {
+		list, files, err = cmd.execCached(base, selection)
+	} else {
+		credentials := c["box"].(box.Credentials)
+
+		b := box.NewBox()
+		if err := b.Authenticate(credentials); err != nil {
+			return err
+		}
+
+		counts := ""
+		if cmd.counts {
+			counts = "counts"
+		}
+
+		hash := cmd.hash("list-folders", b.Hash(), base, selection.String(), scope(cmd.root, cmd.relative, cmd.minDepth, cmd.maxDepth), counts)
+
+		list, files, err = cmd.exec(b, base, selection, hash)
+	}
+
 	if err != nil {
 		return err
 	}
@@ -94,187 +141,145 @@ func (cmd ListFolders) Execute(flagset *flag.FlagSet, c credentials.ICredentials
 		}
 	}
 
-	// .. save/print
-	if cmd.file != "" {
-		return cmd.save(folders)
-	} else {
-		return cmd.print(folders)
-	}
-}
-
-func (cmd ListFolders) exec(b box.Box, glob string, hash string) ([]folder, error) {
-	list := []folder{}
-
-	folders, err := cmd.listFolders(b, 0, "", hash)
-	if err != nil {
-		return nil, err
+	// .. count files
+	var counts map[string]int
+	if cmd.counts {
+		counts = map[string]int{}
+		for _, f := range files {
+			counts[path.Dir(f.FilePath)]++
+		}
 	}
 
-	g := lib.NewGlob(glob)
-	for _, f := range folders {
-		if g.Match(f.Path) {
-			list = append(list, f)
-		}
+	// .. save/print
+	if isHierarchy(options.format) {
+		return cmd.hierarchy(folders, counts).write("list-folders", cmd.file)
 	}
 
-	return list, nil
+	return cmd.results(folders, counts).write("list-folders", cmd.file, cmd.null)
 }
 
-func (cmd ListFolders) print(folders []folder) error {
-	sort.Slice(folders, func(i, j int) bool { return folders[i].Path < folders[j].Path })
+func (cmd ListFolders) exec(b box.Box, glob string, selection *lib.Selection, hash string) ([]folder, []file, error) {
+	list := []folder{}
 
-	table := [][]string{
-		[]string{"ID", "Path", "Tags"},
+	r := resolver(&b)
+	defer save("list-folders", r)
+
+	folderID, prefix, err := root(r, cmd.root)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	widths := []int{
-		len(table[0][0]),
-		len(table[0][1]),
-		len(table[0][2]),
+	folders, files, err := cmd.listFolders(b, folderID, prefix, hash)
+	if err != nil {
+		return nil, nil, err
 	}
 
+	g := lib.NewGlob(glob)
 	for _, f := range folders {
-		id := fmt.Sprintf("%v", f.ID)
-		path := fmt.Sprintf("%v", f.Path)
-		tags := fmt.Sprintf("%v", strings.Join(f.Tags, ";"))
-
-		if N := len(id); N > widths[0] {
-			widths[0] = N
-		}
-
-		if N := len(path); N > widths[1] {
-			widths[1] = N
+		if cmd.relative {
+			f.Path = relative(prefix, f.Path)
 		}
 
-		if cmd.tags {
-			if N := len(tags); N > widths[2] {
-				widths[2] = N
-			}
+		if g.Match(f.Path) && selection.Match(f.Path, lib.Folder) {
+			list = append(list, f)
 		}
-
-		table = append(table, []string{id, path, tags})
-	}
-
-	var format string
-	if cmd.tags {
-		format = fmt.Sprintf("%%-%vv  %%-%vv  %%-%vv\n", widths[0], widths[1], widths[2])
-	} else {
-		format = fmt.Sprintf("%%-%vv  %%-%vv\n", widths[0], widths[1])
 	}
 
-	if cmd.tags {
-		for _, row := range table {
-			fmt.Printf(format, row[0], row[1], row[2])
-		}
-	} else {
-		for _, row := range table {
-			fmt.Printf(format, row[0], row[1])
+	if cmd.relative {
+		for i := range files {
+			files[i].FilePath = relative(prefix, files[i].FilePath)
 		}
 	}
 
-	return nil
+	return list, files, nil
 }
 
-func (cmd ListFolders) save(folders []folder) error {
-	infof("list-folders", "saving %v folders to TSV file %v\n", len(folders), cmd.file)
-
-	sort.Slice(folders, func(i, j int) bool { return folders[i].Path < folders[j].Path })
+func (cmd ListFolders) execCached(glob string, selection *lib.Selection) ([]folder, []file, error) {
+	list := []folder{}
 
-	records := [][]string{
-		[]string{"ID", "Path"},
+	v, err := readInventory(cmd.db)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if cmd.tags {
-		records = [][]string{
-			[]string{"ID", "Path", "Tags"},
-		}
+	defer v.Close()
+
+	folders, files, err := cached("list-folders", v, cmd.root, cmd.relative, cmd.minDepth, cmd.maxDepth)
+	if err != nil {
+		return nil, nil, err
 	}
 
+	g := lib.NewGlob(glob)
 	for _, f := range folders {
-		id := fmt.Sprintf("%v", f.ID)
-		path := fmt.Sprintf("%v", f.Path)
-		tags := fmt.Sprintf("%v", strings.Join(f.Tags, ";"))
-
-		if cmd.tags {
-			records = append(records, []string{id, path, tags})
-		} else {
-			records = append(records, []string{id, path})
+		if g.Match(f.Path) && selection.Match(f.Path, lib.Folder) {
+			list = append(list, f)
 		}
 	}
 
-	if err := os.MkdirAll(filepath.Dir(cmd.file), 0750); err != nil {
-		return err
-	} else if f, err := os.Create(cmd.file); err != nil {
-		return err
-	} else {
-		w := csv.NewWriter(f)
-		w.Comma = '\t'
-		w.WriteAll(records)
-
-		return w.Error()
-	}
+	return list, files, nil
 }
 
-func (cmd ListFolders) listFolders(b box.Box, folderID uint64, prefix string, hash string) ([]folder, error) {
-	pipe, folders, _, err := resume(cmd.checkpoint, hash, cmd.restart)
-	if err != nil {
-		return nil, err
+// results returns the folders (sorted by path) as a result set for the output formatter. The
+// result set includes the number of files in each folder if the files were counted.
+func (cmd ListFolders) results(folders []folder, counts map[string]int) results {
+	sort.Slice(folders, func(i, j int) bool { return folders[i].Path < folders[j].Path })
+
+	r := results{
+		columns:  []string{"ID", "Path", "Name", "Tags", "Size", "Created", "Modified"},
+		defaults: []string{"ID", "Path"},
+		rows:     [][]any{},
 	}
 
-	if len(pipe) > 0 {
-		infof("list-folders", "Resuming last operation")
-	} else {
-		pipe = append(pipe, QueueItem{ID: folderID, Path: prefix})
+	if cmd.tags {
+		r.defaults = []string{"ID", "Path", "Tags"}
 	}
 
-	count := uint(0)
-	tail := 0
-	for tail < len(pipe) {
-		item := pipe[tail]
-		if l, err := b.ListFolders(item.ID); err != nil {
-			if errx := checkpoint(cmd.checkpoint, pipe[tail:], folders, []file{}, hash); errx != nil {
-				warnf("list-folders", "%v", errx)
-			}
+	if counts != nil {
+		r.columns = append(r.columns, "Files")
+		r.defaults = append(r.defaults, "Files", "Size")
+	}
 
-			return folders, err
-		} else {
-			for _, f := range l {
-				path := item.Path + "/" + f.Name
-				folders = append(folders, folder{
-					ID:   f.ID,
-					Name: f.Name,
-					Tags: f.Tags,
-					Path: path,
-				})
-
-				pipe = append(pipe, QueueItem{ID: f.ID, Path: path})
-			}
+	for _, f := range folders {
+		row := []any{
+			f.ID,
+			f.Path,
+			f.Name,
+			f.Tags,
+			f.Size,
+			f.Created,
+			f.Modified,
 		}
 
-		count++
-		if cmd.batch != 0 && count > cmd.batch {
-			break
+		if counts != nil {
+			row = append(row, counts[f.Path])
 		}
 
-		tail++
-		if tail < len(pipe) {
-			time.Sleep(cmd.delay)
-		}
+		r.rows = append(r.rows, row)
 	}
 
-	// ... incomplete?
-	if len(pipe[tail:]) > 0 {
-		if err := checkpoint(cmd.checkpoint, pipe[tail:], folders, []file{}, hash); err != nil {
-			return folders, err
-		} else {
-			return folders, fmt.Errorf("interrupted")
-		}
+	return r
+}
+
+func (cmd ListFolders) listFolders(b box.Box, folderID uint64, prefix string, hash string) ([]folder, []file, error) {
+	t := traversal{
+		tag:        "list-folders",
+		checkpoint: cmd.checkpoint,
+		restart:    cmd.restart,
+		batch:      cmd.batch,
+		delay:      cmd.delay,
+		files:      cmd.counts,
+		minDepth:   cmd.minDepth,
+		maxDepth:   cmd.maxDepth,
 	}
 
-	// ... complete!
-	if err := checkpoint(cmd.checkpoint, []QueueItem{}, []folder{}, []file{}, ""); err != nil {
-		return folders, err
+	if cmd.db != "" {
+		if v, err := inventory.Open(cmd.db); err != nil {
+			return nil, nil, err
+		} else {
+			defer v.Close()
+			t.inventory = v
+		}
 	}
 
-	return folders, nil
+	return t.walk(b, folderID, prefix, hash)
 }
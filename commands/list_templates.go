@@ -3,6 +3,8 @@ package commands
 import (
 	"flag"
 	"fmt"
+	"os"
+	"sort"
 	"time"
 
 	"github.com/twystd/unboxd/box"
@@ -38,14 +40,32 @@ func (cmd ListTemplates) Execute(flagset *flag.FlagSet, c credentials.ICredentia
 	} else if len(templates) == 0 {
 		return fmt.Errorf("no templates defined")
 	} else {
-		for k, v := range templates {
-			fmt.Printf("%-16s  %s\n", k, v)
-		}
+		return cmd.results(templates).render(os.Stdout, "table", false)
 	}
-
-	return nil
 }
 
 func (cmd ListTemplates) exec(b box.Box) (map[string]templates.TemplateKey, error) {
 	return b.ListTemplates()
 }
+
+// results returns the templates (sorted by name) as a result set for the output formatter.
+func (cmd ListTemplates) results(list map[string]templates.TemplateKey) results {
+	names := []string{}
+	for k := range list {
+		names = append(names, k)
+	}
+
+	sort.Strings(names)
+
+	r := results{
+		columns:  []string{"Name", "Key"},
+		defaults: []string{"Name", "Key"},
+		rows:     [][]any{},
+	}
+
+	for _, name := range names {
+		r.rows = append(r.rows, []any{name, string(list[name])})
+	}
+
+	return r
+}
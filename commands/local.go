@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/twystd/unboxd/box/lib"
+)
+
+// localFile is a regular file in the local directory tree. Path is the path of the file on the
+// local filesystem and Rel is the path relative to the local directory e.g. /photos/a.jpg.
+type localFile struct {
+	Path     string
+	Rel      string
+	Size     int64
+	Modified time.Time
+}
+
+// scanLocal walks a local directory tree, returning the selected regular files and the folders
+// to be mirrored i.e. the selected directories and the directories containing selected files.
+// Symbolic links are either skipped or followed (avoiding cycles) and special files are skipped.
+//
+// The paths of the files, directories, symbolic links and special files that were not selected
+// (or were skipped) are also returned, so that a directory is not deleted along with content
+// that was not selected.
+func scanLocal(tag string, dir string, selection *lib.Selection, follow bool) ([]localFile, []string, []string, error) {
+	locals := []localFile{}
+	dirs := map[string]bool{}
+	skipped := []string{}
+	visited := map[string]bool{}
+
+	var walk func(root string, base string) error
+	walk = func(root string, base string) error {
+		real, err := filepath.EvalSymlinks(root)
+		if err != nil {
+			return err
+		} else if visited[real] {
+			warnf(tag, "skipping %v (symbolic link cycle)", root)
+			skipped = append(skipped, base)
+			return nil
+		}
+
+		visited[real] = true
+		if base != "/" && selection.Match(base, lib.Folder) {
+			dirs[base] = true
+		} else if base != "/" {
+			skipped = append(skipped, base)
+		}
+
+		return filepath.WalkDir(real, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(real, p)
+			if err != nil {
+				return err
+			}
+
+			rel = path.Join(base, filepath.ToSlash(rel))
+			if rel == base && d.IsDir() {
+				return nil
+			}
+
+			mode := d.Type()
+			switch {
+			case mode&fs.ModeSymlink != 0:
+				if !follow {
+					warnf(tag, "skipping symbolic link %v", p)
+					skipped = append(skipped, rel)
+					return nil
+				}
+
+				info, err := os.Stat(p)
+				if err != nil {
+					warnf(tag, "skipping broken symbolic link %v", p)
+					skipped = append(skipped, rel)
+					return nil
+				} else if info.IsDir() {
+					return walk(p, rel)
+				} else if info.Mode().IsRegular() && selection.Match(rel, lib.File) {
+					locals = append(locals, localFile{Path: p, Rel: rel, Size: info.Size(), Modified: info.ModTime()})
+				} else {
+					skipped = append(skipped, rel)
+				}
+
+			case d.IsDir():
+				if selection.Match(rel, lib.Folder) {
+					dirs[rel] = true
+				} else {
+					skipped = append(skipped, rel)
+				}
+
+			case mode.IsRegular():
+				if !selection.Match(rel, lib.File) {
+					skipped = append(skipped, rel)
+				} else if info, err := d.Info(); err != nil {
+					return err
+				} else {
+					locals = append(locals, localFile{Path: p, Rel: rel, Size: info.Size(), Modified: info.ModTime()})
+				}
+
+			default:
+				warnf(tag, "skipping special file %v (%v)", p, mode.Type())
+				skipped = append(skipped, rel)
+			}
+
+			return nil
+		})
+	}
+
+	if err := walk(dir, "/"); err != nil {
+		return nil, nil, nil, err
+	}
+
+	for _, f := range locals {
+		for p := path.Dir(f.Rel); p != "/"; p = path.Dir(p) {
+			dirs[p] = true
+		}
+	}
+
+	list := []string{}
+	for p := range dirs {
+		list = append(list, p)
+	}
+
+	sort.Strings(list)
+	sort.Slice(locals, func(i, j int) bool { return locals[i].Rel < locals[j].Rel })
+	sort.Strings(skipped)
+
+	return locals, list, skipped, nil
+}
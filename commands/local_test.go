@@ -0,0 +1,142 @@
+//go:build !windows
+
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/twystd/unboxd/box/lib"
+)
+
+// tree creates a local directory tree with a symbolic link to a subdirectory, symbolic link
+// cycles, a broken symbolic link and a FIFO:
+//
+//	a.txt
+//	broken -> missing
+//	docs/notes.tmp
+//	docs/report.pdf
+//	docs/sub/deep.pdf
+//	docs/up -> ..
+//	empty/
+//	fifo
+//	link -> docs
+//	loop -> .
+func tree(t *testing.T) string {
+	dir := t.TempDir()
+
+	for _, d := range []string{"docs/sub", "empty"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0750); err != nil {
+			t.Fatalf("Error creating %v (%v)", d, err)
+		}
+	}
+
+	for _, f := range []string{"a.txt", "docs/notes.tmp", "docs/report.pdf", "docs/sub/deep.pdf"} {
+		if err := os.WriteFile(filepath.Join(dir, f), []byte(f), 0640); err != nil {
+			t.Fatalf("Error writing %v (%v)", f, err)
+		}
+	}
+
+	links := map[string]string{
+		"broken":  "missing",
+		"docs/up": "..",
+		"link":    "docs",
+		"loop":    ".",
+	}
+
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(dir, link)); err != nil {
+			t.Fatalf("Error creating symbolic link %v (%v)", link, err)
+		}
+	}
+
+	if err := syscall.Mkfifo(filepath.Join(dir, "fifo"), 0640); err != nil {
+		t.Fatalf("Error creating FIFO (%v)", err)
+	}
+
+	return dir
+}
+
+func TestScanLocal(t *testing.T) {
+	dir := tree(t)
+
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		follow  bool
+		files   string
+		dirs    string
+		skipped string
+	}{
+		{
+			name:    "symbolic links skipped",
+			files:   "/a.txt /docs/notes.tmp /docs/report.pdf /docs/sub/deep.pdf",
+			dirs:    "/docs /docs/sub /empty",
+			skipped: "/broken /docs/up /fifo /link /loop",
+		},
+		{
+			name:    "symbolic links followed",
+			follow:  true,
+			files:   "/a.txt /docs/notes.tmp /docs/report.pdf /docs/sub/deep.pdf /link/notes.tmp /link/report.pdf /link/sub/deep.pdf",
+			dirs:    "/docs /docs/sub /empty /link /link/sub",
+			skipped: "/broken /docs/up /fifo /link/up /loop",
+		},
+		{
+			name:    "include",
+			include: []string{"*.pdf"},
+			files:   "/docs/report.pdf /docs/sub/deep.pdf",
+			dirs:    "/docs /docs/sub",
+			skipped: "/a.txt /broken /docs /docs/notes.tmp /docs/sub /docs/up /empty /fifo /link /loop",
+		},
+		{
+			name:    "exclude",
+			exclude: []string{"*.tmp", "/empty"},
+			follow:  true,
+			files:   "/a.txt /docs/report.pdf /docs/sub/deep.pdf /link/report.pdf /link/sub/deep.pdf",
+			dirs:    "/docs /docs/sub /link /link/sub",
+			skipped: "/broken /docs/notes.tmp /docs/up /empty /fifo /link/notes.tmp /link/up /loop",
+		},
+		{
+			name:    "unselected followed directory",
+			include: []string{"/docs/**", "/link/sub/**"},
+			follow:  true,
+			files:   "/docs/notes.tmp /docs/report.pdf /docs/sub/deep.pdf /link/sub/deep.pdf",
+			dirs:    "/docs /docs/sub /link /link/sub",
+			skipped: "/a.txt /broken /docs /docs/up /empty /fifo /link /link/notes.tmp /link/report.pdf /link/sub /link/up /loop",
+		},
+	}
+
+	for _, v := range tests {
+		locals, dirs, skipped, err := scanLocal("test", dir, lib.NewSelection(v.include, v.exclude), v.follow)
+		if err != nil {
+			t.Fatalf("%v: error scanning %v (%v)", v.name, dir, err)
+		}
+
+		files := []string{}
+		for _, f := range locals {
+			files = append(files, f.Rel)
+
+			if expected := filepath.Join(dir, filepath.FromSlash(strings.Replace(f.Rel, "/link/", "/docs/", 1))); f.Path != expected {
+				if real, err := filepath.EvalSymlinks(f.Path); err != nil || real != expected {
+					t.Errorf("%v: incorrect path for %v - expected:%v, got:%v", v.name, f.Rel, expected, f.Path)
+				}
+			}
+		}
+
+		if s := strings.Join(files, " "); s != v.files {
+			t.Errorf("%v: incorrect files\n   expected:%v\n   got:     %v", v.name, v.files, s)
+		}
+
+		if s := strings.Join(dirs, " "); s != v.dirs {
+			t.Errorf("%v: incorrect directories\n   expected:%v\n   got:     %v", v.name, v.dirs, s)
+		}
+
+		if s := strings.Join(skipped, " "); s != v.skipped {
+			t.Errorf("%v: incorrect skipped paths\n   expected:%v\n   got:     %v", v.name, v.skipped, s)
+		}
+	}
+}
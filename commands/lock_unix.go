@@ -0,0 +1,51 @@
+//go:build !windows
+
+package commands
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lock is an exclusive advisory lock on a lock file. The lock is released by the OS if the
+// process exits without releasing it.
+type lock struct {
+	f *os.File
+}
+
+func acquire(file string) (*lock, error) {
+	for retries := 0; retries < 3; retries++ {
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_RDWR, 0666)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		// ... the lock file may have been released and removed by another process between
+		//     opening and locking it
+		if locked, err := f.Stat(); err != nil {
+			f.Close()
+			return nil, err
+		} else if current, err := os.Stat(file); err == nil && os.SameFile(locked, current) {
+			return &lock{f: f}, nil
+		}
+
+		f.Close()
+	}
+
+	return nil, fmt.Errorf("unable to lock %v", file)
+}
+
+func (l *lock) release() {
+	if l != nil && l.f != nil {
+		os.Remove(l.f.Name())
+		syscall.Flock(int(l.f.Fd()), syscall.LOCK_UN)
+		l.f.Close()
+		l.f = nil
+	}
+}
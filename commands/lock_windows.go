@@ -0,0 +1,33 @@
+//go:build windows
+
+package commands
+
+import (
+	"fmt"
+	"os"
+)
+
+// lock is an exclusive lock implemented by creating the lock file. A lock file left behind
+// by a process that exited without releasing it has to be deleted manually.
+type lock struct {
+	file string
+}
+
+func acquire(file string) (*lock, error) {
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0666)
+	if err != nil {
+		return nil, fmt.Errorf("lock file %v exists", file)
+	}
+
+	fmt.Fprintf(f, "%v\n", os.Getpid())
+	f.Close()
+
+	return &lock{file: file}, nil
+}
+
+func (l *lock) release() {
+	if l != nil && l.file != "" {
+		os.Remove(l.file)
+		l.file = ""
+	}
+}
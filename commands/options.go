@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"strings"
+)
+
+var options = struct {
+	cache   string
+	quiet   bool
+	format  string
+	columns []string
+	sort    []string
+	reverse bool
+
+	template     string
+	templateFile string
+}{
+	cache:   "",
+	quiet:   false,
+	format:  "",
+	columns: nil,
+	sort:    nil,
+	reverse: false,
+
+	template:     "",
+	templateFile: "",
+}
+
+// SetPathCache sets the file used to cache resolved Box paths between invocations. Resolved
+// paths are only cached for the current invocation if the file is blank.
+func SetPathCache(file string) {
+	options.cache = file
+}
+
+// SetQuiet disables the progress display for long running commands.
+func SetQuiet(quiet bool) {
+	options.quiet = quiet
+}
+
+// SetOutput sets the output format, the comma separated list of columns to display and the
+// comma separated list of columns by which to sort the output of the list and get commands. The
+// commands use their default format and columns if format or columns are blank.
+func SetOutput(format string, columns string, sort string, reverse bool) {
+	split := func(s string) []string {
+		list := []string{}
+		for _, v := range strings.Split(s, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				list = append(list, v)
+			}
+		}
+
+		return list
+	}
+
+	options.format = strings.ToLower(strings.TrimSpace(format))
+	options.columns = split(columns)
+	options.sort = split(sort)
+	options.reverse = reverse
+}
+
+// SetTemplate sets the user-defined text/template (or the file containing the template) through
+// which the list and get commands render their output.
+func SetTemplate(template string, file string) {
+	options.template = template
+	options.templateFile = file
+}
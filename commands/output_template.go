@@ -0,0 +1,214 @@
+package commands
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// outputTemplate holds the user-defined --template or --template-file text/template for the list
+// and get commands. The template is executed for each item (with the item fields keyed by column
+// name e.g. {{.ID}} {{.Path}}), or once for the whole result set if it refers to .Items e.g.
+//
+//	{{range .Items}}| {{.Path}} | {{bytes .Size}} |{{"\n"}}{{end}}
+type outputTemplate struct {
+	t     *template.Template
+	whole bool
+}
+
+// templateFuncs are the helper functions available to output templates.
+var templateFuncs = template.FuncMap{
+	"date":      templateDate,
+	"timestamp": templateTimestamp,
+	"bytes":     templateBytes,
+	"join":      templateJoin,
+	"json":      templateJSON,
+	"csv":       templateCSV,
+}
+
+// templated returns true if an output template was specified with --template or --template-file.
+func templated() bool {
+	return options.template != "" || options.templateFile != ""
+}
+
+// compileTemplate parses the --template or --template-file output template.
+func compileTemplate() (*outputTemplate, error) {
+	text := options.template
+	if options.template != "" && options.templateFile != "" {
+		return nil, fmt.Errorf("--template and --template-file are mutually exclusive")
+	} else if options.templateFile != "" {
+		if bytes, err := os.ReadFile(options.templateFile); err != nil {
+			return nil, err
+		} else {
+			text = string(bytes)
+		}
+	}
+
+	if options.format != "" {
+		return nil, fmt.Errorf("--format and --template are mutually exclusive")
+	}
+
+	t, err := template.New("output").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	return &outputTemplate{
+		t:     t,
+		whole: t.Tree != nil && refersTo(t.Tree.Root, "Items"),
+	}, nil
+}
+
+// execute renders the rows through the template, either once for the whole result set or for
+// each row (each followed by a newline).
+func (o outputTemplate) execute(w io.Writer, columns []string, rows [][]any) error {
+	items := []map[string]any{}
+	for _, row := range rows {
+		item := map[string]any{}
+		for i, c := range columns {
+			item[c] = row[i]
+		}
+
+		items = append(items, item)
+	}
+
+	if o.whole {
+		return o.t.Execute(w, map[string]any{
+			"Columns": columns,
+			"Items":   items,
+			"Count":   len(items),
+		})
+	}
+
+	for _, item := range items {
+		if err := o.t.Execute(w, item); err != nil {
+			return err
+		} else if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// refersTo returns true if any field in the template tree starts with the identifier
+// e.g. {{range .Items}}.
+func refersTo(node parse.Node, field string) bool {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n != nil {
+			for _, v := range n.Nodes {
+				if refersTo(v, field) {
+					return true
+				}
+			}
+		}
+
+	case *parse.ActionNode:
+		return refersTo(n.Pipe, field)
+
+	case *parse.PipeNode:
+		if n != nil {
+			for _, c := range n.Cmds {
+				if refersTo(c, field) {
+					return true
+				}
+			}
+		}
+
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if refersTo(arg, field) {
+				return true
+			}
+		}
+
+	case *parse.FieldNode:
+		return len(n.Ident) > 0 && n.Ident[0] == field
+
+	case *parse.ChainNode:
+		return refersTo(n.Node, field)
+
+	case *parse.IfNode:
+		return refersTo(n.Pipe, field) || refersTo(n.List, field) || refersTo(n.ElseList, field)
+
+	case *parse.RangeNode:
+		return refersTo(n.Pipe, field) || refersTo(n.List, field) || refersTo(n.ElseList, field)
+
+	case *parse.WithNode:
+		return refersTo(n.Pipe, field) || refersTo(n.List, field) || refersTo(n.ElseList, field)
+
+	case *parse.TemplateNode:
+		return refersTo(n.Pipe, field)
+	}
+
+	return false
+}
+
+// templateDate formats a timestamp with a Go time layout e.g. {{date "2006-01-02" .Modified}}.
+// Zero timestamps are formatted as an empty string.
+func templateDate(layout string, v any) string {
+	if t, ok := v.(time.Time); ok && !t.IsZero() {
+		return t.Format(layout)
+	}
+
+	return ""
+}
+
+// templateTimestamp formats a timestamp as e.g. 2023-06-01 09:14:21.
+func templateTimestamp(v any) string {
+	if t, ok := v.(time.Time); ok {
+		return timestamp(t)
+	}
+
+	return ""
+}
+
+// templateBytes formats a size as a human readable byte count e.g. {{bytes .Size}} is 1.5MB.
+func templateBytes(v any) string {
+	switch n := v.(type) {
+	case uint64:
+		return bytesize(int64(n))
+	case int64:
+		return bytesize(n)
+	case int:
+		return bytesize(int64(n))
+	default:
+		return text(v)
+	}
+}
+
+// templateJoin joins a list (e.g. tags) with a separator e.g. {{join ", " .Tags}}.
+func templateJoin(separator string, v any) string {
+	if list, ok := v.([]string); ok {
+		return strings.Join(list, separator)
+	}
+
+	return text(v)
+}
+
+// templateJSON encodes a value as JSON e.g. {"path":{{json .Path}}}.
+func templateJSON(v any) (string, error) {
+	if bytes, err := json.Marshal(value(v)); err != nil {
+		return "", err
+	} else {
+		return string(bytes), nil
+	}
+}
+
+// templateCSV quotes a value as a CSV field (if necessary) e.g. {{csv .Path}},{{.Size}}.
+func templateCSV(v any) string {
+	var b strings.Builder
+
+	w := csv.NewWriter(&b)
+	w.Write([]string{text(v)})
+	w.Flush()
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
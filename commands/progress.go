@@ -0,0 +1,143 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progress tracks the progress of a long running operation and (unless --quiet) renders it
+// to stderr. On a terminal the progress line is redrawn a few times a second, otherwise it
+// is logged periodically. The same summary is logged on SIGUSR1/SIGINFO.
+type progress struct {
+	tag      string
+	unit     string
+	started  time.Time
+	done     atomic.Int64
+	pending  atomic.Int64
+	found    atomic.Int64
+	requests atomic.Int64
+	retries  atomic.Int64
+	quit     chan struct{}
+	wg       sync.WaitGroup
+}
+
+const refreshInterval = 250 * time.Millisecond
+const logInterval = 30 * time.Second
+
+func newProgress(tag string, unit string) *progress {
+	return &progress{
+		tag:     tag,
+		unit:    unit,
+		started: time.Now(),
+		quit:    make(chan struct{}),
+	}
+}
+
+// start starts rendering the progress and handling the interrupt and progress signals. The
+// returned function stops both.
+func (p *progress) start() func() {
+	unwatch := watch(p.tag, p.String)
+
+	if !options.quiet {
+		tty := isTTY(os.Stderr)
+		interval := logInterval
+		if tty {
+			interval = refreshInterval
+		}
+
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+					if tty {
+						fmt.Fprintf(os.Stderr, "\r\033[K%v  %v", p.tag, p.String())
+					} else {
+						infof(p.tag, "%v", p.String())
+					}
+
+				case <-p.quit:
+					if tty {
+						fmt.Fprintf(os.Stderr, "\r\033[K")
+					}
+					return
+				}
+			}
+		}()
+	}
+
+	return func() {
+		close(p.quit)
+		p.wg.Wait()
+		unwatch()
+	}
+}
+
+func (p *progress) String() string {
+	elapsed := time.Since(p.started)
+	done := p.done.Load()
+	pending := p.pending.Load()
+	requests := p.requests.Load()
+
+	fields := []string{
+		fmt.Sprintf("%v %v/%v", p.unit, p.format(done), p.format(done+pending)),
+	}
+
+	if found := p.found.Load(); found > 0 {
+		fields = append(fields, fmt.Sprintf("found %v", found))
+	}
+
+	if s := elapsed.Seconds(); s > 0 {
+		fields = append(fields, fmt.Sprintf("%.1f req/s", float64(requests)/s))
+	}
+
+	fields = append(fields, fmt.Sprintf("retries %v", p.retries.Load()))
+	fields = append(fields, fmt.Sprintf("elapsed %v", elapsed.Round(time.Second)))
+
+	if done > 0 && pending > 0 {
+		eta := time.Duration(float64(elapsed) * float64(pending) / float64(done))
+		fields = append(fields, fmt.Sprintf("ETA %v", eta.Round(time.Second)))
+	}
+
+	return strings.Join(fields, "  ")
+}
+
+func (p *progress) format(v int64) string {
+	if p.unit == "bytes" {
+		return bytesize(v)
+	}
+
+	return fmt.Sprintf("%v", v)
+}
+
+func bytesize(v int64) string {
+	const K = 1024
+
+	switch {
+	case v < K:
+		return fmt.Sprintf("%vB", v)
+	case v < K*K:
+		return fmt.Sprintf("%.1fkB", float64(v)/K)
+	case v < K*K*K:
+		return fmt.Sprintf("%.1fMB", float64(v)/(K*K))
+	default:
+		return fmt.Sprintf("%.1fGB", float64(v)/(K*K*K))
+	}
+}
+
+func isTTY(f *os.File) bool {
+	if info, err := f.Stat(); err != nil {
+		return false
+	} else {
+		return info.Mode()&os.ModeCharDevice != 0
+	}
+}
@@ -0,0 +1,300 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/twystd/unboxd/box/lib"
+	"github.com/twystd/unboxd/credentials"
+	"github.com/twystd/unboxd/inventory"
+)
+
+var QueryCmd = Query{
+	command: command{
+		name:  "query",
+		delay: 0,
+	},
+
+	db:     "",
+	cached: true,
+}
+
+// Query implements the 'query' command, which answers queries from the local inventory
+// database without using the Box API.
+type Query struct {
+	command
+	db             string
+	cached         bool
+	file           string
+	itemType       string
+	tags           patterns
+	minSize        string
+	maxSize        string
+	modifiedAfter  string
+	modifiedBefore string
+	sha1           string
+	count          bool
+	include        patterns
+	exclude        patterns
+	patterns       string
+	root           string
+	relative       bool
+	minDepth       uint
+	maxDepth       uint
+	output
+}
+
+// filter holds the parsed --min-size, --max-size, --modified-after, --modified-before and
+// --sha1 query options.
+type filter struct {
+	minSize        *uint64
+	maxSize        *uint64
+	modifiedAfter  time.Time
+	modifiedBefore time.Time
+	sha1           string
+}
+
+func (cmd *Query) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
+	flagset.StringVar(&cmd.db, "db", cmd.db, "Inventory database to query (defaults to .inventory)")
+	flagset.BoolVar(&cmd.cached, "cached", cmd.cached, "Answers the query from the inventory database (always true)")
+	flagset.StringVar(&cmd.file, "file", cmd.file, "File to which to write the query results (TSV unless --format is specified)")
+	flagset.StringVar(&cmd.itemType, "type", cmd.itemType, "Restricts the results to files or folders")
+	flagset.Var(&cmd.tags, "tag", "Restricts the results to items with the tag (may be repeated)")
+	flagset.StringVar(&cmd.minSize, "min-size", cmd.minSize, "Restricts the results to items at least this size (e.g. 100, 64k, 10M, 1G)")
+	flagset.StringVar(&cmd.maxSize, "max-size", cmd.maxSize, "Restricts the results to items at most this size (e.g. 100, 64k, 10M, 1G)")
+	flagset.StringVar(&cmd.modifiedAfter, "modified-after", cmd.modifiedAfter, "Restricts the results to items modified after the date/time (e.g. 2023-06-01)")
+	flagset.StringVar(&cmd.modifiedBefore, "modified-before", cmd.modifiedBefore, "Restricts the results to items modified before the date/time (e.g. 2023-06-01)")
+	flagset.StringVar(&cmd.sha1, "sha1", cmd.sha1, "Restricts the results to files with the SHA1 checksum")
+	flagset.BoolVar(&cmd.count, "count", cmd.count, "Displays the number of matching files and folders rather than the items")
+	flagset.Var(&cmd.include, "include", "Glob pattern for paths to include (may be repeated)")
+	flagset.Var(&cmd.exclude, "exclude", "Glob pattern for paths to exclude (may be repeated)")
+	flagset.StringVar(&cmd.patterns, "patterns-from", cmd.patterns, "File with gitignore-like include/exclude patterns")
+	flagset.StringVar(&cmd.root, "root", cmd.root, "Folder ID or path from which to start the query (defaults to the root folder)")
+	flagset.BoolVar(&cmd.relative, "relative", cmd.relative, "Display paths relative to the --root folder")
+	flagset.UintVar(&cmd.minDepth, "min-depth", cmd.minDepth, "Minimum depth below the --root folder to include in the results")
+	flagset.UintVar(&cmd.maxDepth, "max-depth", cmd.maxDepth, "Maximum depth below the --root folder to include in the results (0 for no limit)")
+
+	cmd.output.flags(flagset)
+
+	return flagset
+}
+
+func (cmd Query) Execute(flagset *flag.FlagSet, c credentials.ICredentials) error {
+	if !cmd.cached {
+		return fmt.Errorf("query only supports the cached inventory")
+	}
+
+	if cmd.itemType != "" && cmd.itemType != "file" && cmd.itemType != "folder" {
+		return fmt.Errorf("invalid --type '%v' (expected 'file' or 'folder')", cmd.itemType)
+	}
+
+	if err := validateFormat(); err != nil {
+		return err
+	}
+
+	f, err := cmd.filter()
+	if err != nil {
+		return err
+	}
+
+	glob := ""
+	if args := flagset.Args(); len(args) > 0 {
+		glob = args[0]
+	}
+
+	v, err := readInventory(cmd.db)
+	if err != nil {
+		return err
+	}
+
+	defer v.Close()
+
+	r, err := cmd.exec(v, glob, f)
+	if err != nil {
+		return err
+	}
+
+	return r.write("query", cmd.file, cmd.null)
+}
+
+func (cmd Query) filter() (filter, error) {
+	f := filter{
+		sha1: strings.ToLower(strings.TrimSpace(cmd.sha1)),
+	}
+
+	if cmd.minSize != "" {
+		if v, err := parseSize(cmd.minSize); err != nil {
+			return f, fmt.Errorf("invalid --min-size '%v' (%w)", cmd.minSize, err)
+		} else {
+			f.minSize = &v
+		}
+	}
+
+	if cmd.maxSize != "" {
+		if v, err := parseSize(cmd.maxSize); err != nil {
+			return f, fmt.Errorf("invalid --max-size '%v' (%w)", cmd.maxSize, err)
+		} else {
+			f.maxSize = &v
+		}
+	}
+
+	if cmd.modifiedAfter != "" {
+		if t, err := parseDate(cmd.modifiedAfter); err != nil {
+			return f, fmt.Errorf("invalid --modified-after '%v' (%w)", cmd.modifiedAfter, err)
+		} else {
+			f.modifiedAfter = t
+		}
+	}
+
+	if cmd.modifiedBefore != "" {
+		if t, err := parseDate(cmd.modifiedBefore); err != nil {
+			return f, fmt.Errorf("invalid --modified-before '%v' (%w)", cmd.modifiedBefore, err)
+		} else {
+			f.modifiedBefore = t
+		}
+	}
+
+	return f, nil
+}
+
+func (cmd Query) exec(v *inventory.Inventory, glob string, f filter) (results, error) {
+	r := results{
+		columns:  []string{"Type", "ID", "Path", "Size", "SHA1", "Tags", "Created", "Modified"},
+		defaults: []string{"Type", "ID", "Path", "Size", "Modified", "Tags"},
+		rows:     [][]any{},
+	}
+
+	selection, err := selection(cmd.include, cmd.exclude, cmd.patterns)
+	if err != nil {
+		return r, err
+	}
+
+	folders, files, err := cached("query", v, cmd.root, cmd.relative, cmd.minDepth, cmd.maxDepth)
+	if err != nil {
+		return r, err
+	}
+
+	g := lib.NewGlob(glob)
+	match := func(path string, kind lib.Kind, tags []string) bool {
+		for _, tag := range cmd.tags {
+			found := false
+			for _, t := range tags {
+				found = found || t == tag
+			}
+
+			if !found {
+				return false
+			}
+		}
+
+		return g.Match(path) && selection.Match(path, kind)
+	}
+
+	if cmd.itemType != "file" && f.sha1 == "" {
+		for _, item := range folders {
+			if match(item.Path, lib.Folder, item.Tags) && f.match(item.Size, item.Modified, "") {
+				r.rows = append(r.rows, []any{"folder", item.ID, item.Path, item.Size, "", item.Tags, item.Created, item.Modified})
+			}
+		}
+	}
+
+	if cmd.itemType != "folder" {
+		for _, item := range files {
+			if match(item.FilePath, lib.File, item.Tags) && f.match(item.Size, item.Modified, item.SHA1) {
+				r.rows = append(r.rows, []any{"file", item.ID, item.FilePath, item.Size, item.SHA1, item.Tags, item.Created, item.Modified})
+			}
+		}
+	}
+
+	sort.SliceStable(r.rows, func(i, j int) bool { return r.rows[i][2].(string) < r.rows[j][2].(string) })
+
+	if cmd.count {
+		return cmd.counts(r), nil
+	}
+
+	return r, nil
+}
+
+// counts replaces the query results with the number of matching files and folders.
+func (cmd Query) counts(r results) results {
+	counts := map[string]uint64{}
+	for _, row := range r.rows {
+		counts[row[0].(string)]++
+	}
+
+	return results{
+		columns:  []string{"Folders", "Files", "Total"},
+		defaults: []string{"Folders", "Files", "Total"},
+		rows: [][]any{
+			{counts["folder"], counts["file"], uint64(len(r.rows))},
+		},
+	}
+}
+
+func (f filter) match(size uint64, modified time.Time, sha1 string) bool {
+	switch {
+	case f.minSize != nil && size < *f.minSize:
+		return false
+
+	case f.maxSize != nil && size > *f.maxSize:
+		return false
+
+	case !f.modifiedAfter.IsZero() && !modified.After(f.modifiedAfter):
+		return false
+
+	case !f.modifiedBefore.IsZero() && !modified.Before(f.modifiedBefore):
+		return false
+
+	case f.sha1 != "" && !strings.EqualFold(sha1, f.sha1):
+		return false
+	}
+
+	return true
+}
+
+// parseSize parses a size in bytes with an optional k, M or G (1024 based) suffix.
+func parseSize(v string) (uint64, error) {
+	match := regexp.MustCompile(`^([0-9]+)([kKMG]?)$`).FindStringSubmatch(strings.TrimSpace(v))
+	if match == nil {
+		return 0, fmt.Errorf("expected N[k|M|G]")
+	}
+
+	N, err := strconv.ParseUint(match[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	switch match[2] {
+	case "k", "K":
+		N *= 1024
+	case "M":
+		N *= 1024 * 1024
+	case "G":
+		N *= 1024 * 1024 * 1024
+	}
+
+	return N, nil
+}
+
+// parseDate parses an RFC3339 date/time or a date and optional time in UTC.
+func parseDate(v string) (time.Time, error) {
+	formats := []string{
+		time.RFC3339,
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+		"2006-01-02",
+	}
+
+	for _, format := range formats {
+		if t, err := time.Parse(format, strings.TrimSpace(v)); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("expected YYYY-MM-DD, YYYY-MM-DD HH:mm:ss or RFC3339")
+}
@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/twystd/unboxd/inventory"
+)
+
+func TestQueryFilter(t *testing.T) {
+	modified := time.Date(2023, time.June, 1, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		query    Query
+		size     uint64
+		sha1     string
+		expected bool
+	}{
+		{Query{}, 1024, "", true},
+		{Query{minSize: "1k"}, 1024, "", true},
+		{Query{minSize: "1k"}, 1023, "", false},
+		{Query{maxSize: "1k"}, 1024, "", true},
+		{Query{maxSize: "1K"}, 1025, "", false},
+		{Query{minSize: "1M", maxSize: "1G"}, 2 * 1024 * 1024, "", true},
+		{Query{minSize: "1M", maxSize: "1G"}, 2 * 1024 * 1024 * 1024, "", false},
+		{Query{modifiedAfter: "2023-06-01"}, 0, "", true},
+		{Query{modifiedAfter: "2023-06-01 09:30:00"}, 0, "", false},
+		{Query{modifiedBefore: "2023-06-02"}, 0, "", true},
+		{Query{modifiedBefore: "2023-06-01T11:00:00+02:00"}, 0, "", false},
+		{Query{modifiedAfter: "2023-05-01", modifiedBefore: "2023-06-01 10:00"}, 0, "", true},
+		{Query{sha1: "DA39A3EE5E6B4B0D3255BFEF95601890AFD80709"}, 0, "da39a3ee5e6b4b0d3255bfef95601890afd80709", true},
+		{Query{sha1: "da39a3ee5e6b4b0d3255bfef95601890afd80709"}, 0, "", false},
+	}
+
+	for _, v := range tests {
+		f, err := v.query.filter()
+		if err != nil {
+			t.Errorf("Error parsing filter %+v (%v)", v.query, err)
+		} else if match := f.match(v.size, modified, v.sha1); match != v.expected {
+			t.Errorf("Incorrect match for %+v - expected:%v, got:%v", v.query, v.expected, match)
+		}
+	}
+}
+
+func TestQueryFilterInvalid(t *testing.T) {
+	tests := []Query{
+		{minSize: "-1"},
+		{minSize: "1.5M"},
+		{maxSize: "10MB"},
+		{modifiedAfter: "yesterday"},
+		{modifiedBefore: "2023-13-01"},
+	}
+
+	for _, q := range tests {
+		if _, err := q.filter(); err == nil {
+			t.Errorf("Expected error parsing filter %+v", q)
+		}
+	}
+}
+
+func TestReadInventory(t *testing.T) {
+	db := filepath.Join(t.TempDir(), ".inventory")
+
+	// ... missing inventory is not created
+	expected := fmt.Sprintf("no inventory at %v (run list-files --db first)", db)
+	if _, err := readInventory(db); err == nil || err.Error() != expected {
+		t.Errorf("Incorrect error - expected:%v, got:%v", expected, err)
+	} else if _, err := os.Stat(db); err == nil {
+		t.Errorf("Missing inventory %v created", db)
+	}
+
+	if v, err := inventory.Open(db); err != nil {
+		t.Fatalf("Error creating inventory (%v)", err)
+	} else {
+		v.Close()
+	}
+
+	// ... existing inventory can be opened read-only more than once
+	p, err := readInventory(db)
+	if err != nil {
+		t.Fatalf("Error opening inventory (%v)", err)
+	}
+
+	defer p.Close()
+
+	q, err := readInventory(db)
+	if err != nil {
+		t.Fatalf("Error opening inventory a second time (%v)", err)
+	}
+
+	defer q.Close()
+
+	if err := p.Put(inventory.Item{Type: inventory.FileItem, ID: 1, Path: "/a.txt"}); err == nil {
+		t.Errorf("Expected error writing to read-only inventory")
+	}
+}
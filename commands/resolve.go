@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/twystd/unboxd/box"
+	"github.com/twystd/unboxd/credentials"
+)
+
+var ResolveCmd = Resolve{
+	command: command{
+		name:  "resolve",
+		delay: 500 * time.Millisecond,
+	},
+
+	itemType: "",
+}
+
+type Resolve struct {
+	command
+	itemType string
+}
+
+func (cmd *Resolve) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
+	flagset.StringVar(&cmd.itemType, "type", cmd.itemType, "Restricts resolution to 'file' or 'folder' items")
+
+	return flagset
+}
+
+func (cmd Resolve) Execute(flagset *flag.FlagSet, c credentials.ICredentials) error {
+	credentials := c["box"].(box.Credentials)
+
+	var t box.ItemType
+	switch cmd.itemType {
+	case "":
+		t = box.AnyItem
+	case "file":
+		t = box.FileItem
+	case "folder":
+		t = box.FolderItem
+	default:
+		return fmt.Errorf("invalid item type '%v' (expected 'file' or 'folder')", cmd.itemType)
+	}
+
+	args := flagset.Args()
+	if len(args) == 0 {
+		return fmt.Errorf("missing ID or path argument")
+	}
+
+	b := box.NewBox()
+	if err := b.Authenticate(credentials); err != nil {
+		return err
+	}
+
+	r := resolver(&b)
+	defer save("resolve", r)
+
+	for _, arg := range args {
+		if item, err := cmd.exec(r, arg, t); err != nil {
+			return err
+		} else {
+			fmt.Printf("%-14v  %-6v  %v\n", item.ID, item.Type, item.Path)
+		}
+	}
+
+	return nil
+}
+
+func (cmd Resolve) exec(r *box.Resolver, arg string, t box.ItemType) (box.Item, error) {
+	item, err := r.Lookup(arg, t)
+	if err != nil {
+		return box.Item{}, err
+	} else if item.Path != "" {
+		return item, nil
+	}
+
+	// ... ID - file and folder IDs are distinct so check both if the type is not specified
+	if t != box.AnyItem {
+		if item.Path, err = r.Path(t, item.ID); err != nil {
+			return box.Item{}, err
+		}
+
+		return item, nil
+	}
+
+	file, errf := r.Path(box.FileItem, item.ID)
+	folder, errd := r.Path(box.FolderItem, item.ID)
+
+	switch {
+	case errf == nil && errd == nil:
+		return box.Item{}, fmt.Errorf("%v: %w - matches file %v and folder %v (specify --type)", arg, box.ErrAmbiguous, file, folder)
+
+	case errf == nil:
+		return box.Item{Type: box.FileItem, ID: item.ID, Path: file}, nil
+
+	case errd == nil:
+		return box.Item{Type: box.FolderItem, ID: item.ID, Path: folder}, nil
+
+	default:
+		return box.Item{}, fmt.Errorf("%v: %w (%v, %v)", arg, box.ErrNotFound, errf, errd)
+	}
+}
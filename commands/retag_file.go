@@ -3,6 +3,7 @@ package commands
 import (
 	"flag"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/twystd/unboxd/box"
@@ -14,13 +15,22 @@ var RetagFileCmd = RetagFile{
 		name:  "retag-file",
 		delay: 500 * time.Millisecond,
 	},
+
+	bulk: bulk{
+		checkpoint: ".checkpoint.retag-file",
+		workers:    1,
+	},
 }
 
 type RetagFile struct {
 	command
+	bulk
 }
 
 func (cmd *RetagFile) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
+	cmd.bulk.flags(flagset)
+	flagset.DurationVar(&cmd.delay, "delay", cmd.delay, "Delay between multiple requests to reduce traffic to Box API")
+
 	return flagset
 }
 
@@ -32,39 +42,66 @@ func (cmd RetagFile) Execute(flagset *flag.FlagSet, c credentials.ICredentials)
 		return err
 	}
 
-	var fileID uint64
+	r := resolver(&b)
+	defer save("retag-file", r)
+
 	var oldTag string
 	var newTag string
 
 	args := flagset.Args()
-
-	if len(args) < 1 {
-		return fmt.Errorf("missing file ID")
-	} else if v, err := getFileID(args[0]); err != nil {
-		return err
-	} else {
-		fileID = v
+	N := 3
+	if cmd.piped() {
+		N = 2
 	}
 
-	if len(args) < 2 {
+	if len(args) == 0 && !cmd.piped() {
+		return fmt.Errorf("missing file ID or path")
+	} else if len(args) < N-1 {
 		return fmt.Errorf("missing 'old' tag")
+	} else if len(args) < N {
+		return fmt.Errorf("missing 'new' tag")
 	} else {
-		oldTag = args[1]
+		oldTag = args[len(args)-2]
+		newTag = args[len(args)-1]
 	}
 
-	if len(args) < 3 {
-		return fmt.Errorf("missing 'new' tag")
-	} else {
-		newTag = args[2]
+	files, err := cmd.items(args[:len(args)-2])
+	if err != nil {
+		return err
+	} else if len(files) == 0 {
+		return fmt.Errorf("missing file ID or path")
 	}
 
-	if err := cmd.exec(b, fileID, oldTag, newTag); err != nil {
+	selection, err := cmd.selection()
+	if err != nil {
 		return err
 	}
 
-	infof("retag-file", "%v replaced tag %v with %v\n", fileID, oldTag, newTag)
+	items := [][]string{}
+	for _, file := range files {
+		items = append(items, []string{file})
+	}
 
-	return nil
+	hash := cmd.hash("retag-file", b.Hash(), "", oldTag, newTag, selection.String(), strings.Join(files, "\n"))
+	j := cmd.job("retag-file", cmd.delay)
+
+	_, err = j.run(hash, items, func(args []string, sent func(int64), state *taskState) (string, error) {
+		if fileID, err := getFileID(r, args[0]); err != nil {
+			return "", err
+		} else if ok, err := selectedFile(r, selection, fileID); err != nil {
+			return "", err
+		} else if !ok {
+			infof("retag-file", "%v skipped (not selected)", args[0])
+			return fmt.Sprintf("%v", fileID), nil
+		} else if err := cmd.exec(b, fileID, oldTag, newTag); err != nil {
+			return "", err
+		} else {
+			infof("retag-file", "%v replaced tag %v with %v\n", fileID, oldTag, newTag)
+			return fmt.Sprintf("%v", fileID), nil
+		}
+	})
+
+	return err
 }
 
 func (cmd RetagFile) exec(b box.Box, fileID uint64, oldTag, newTag string) error {
@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"sync/atomic"
+)
+
+// ErrInterrupted is returned by a long running command that stopped before completing, either
+// because it was interrupted by a signal or because it reached the --batch-size limit. The
+// command can be resumed from the checkpoint (if any).
+var ErrInterrupted = errors.New("interrupted")
+
+// ExitInterrupted is the process exit status for a command that returned ErrInterrupted.
+const ExitInterrupted = 130
+
+var interrupted atomic.Bool
+
+// watch handles the interrupt and progress signals for the duration of a long running command.
+// The first interrupt signal (SIGINT, SIGTERM or SIGHUP) sets a flag that the command polls
+// once the in-flight request has completed, so that it can write a checkpoint before exiting.
+// A second interrupt signal exits immediately. A progress signal (SIGUSR1 or SIGINFO) logs the
+// summary returned by the progress function.
+//
+// The returned function stops handling the signals.
+func watch(tag string, progress func() string) func() {
+	interrupts := make(chan os.Signal, 1)
+	info := make(chan os.Signal, 1)
+	done := make(chan struct{})
+
+	signal.Notify(interrupts, interruptSignals...)
+	if len(infoSignals) > 0 {
+		signal.Notify(info, infoSignals...)
+	}
+
+	go func() {
+		for {
+			select {
+			case s := <-interrupts:
+				if interrupted.Swap(true) {
+					warnf(tag, "%v - exiting without checkpoint", s)
+					os.Exit(ExitInterrupted)
+				}
+
+				warnf(tag, "%v - stopping after current request", s)
+
+			case <-info:
+				infof(tag, "%v", progress())
+
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(interrupts)
+		signal.Stop(info)
+		close(done)
+	}
+}
+
+func isInterrupted() bool {
+	return interrupted.Load()
+}
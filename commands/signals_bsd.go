@@ -0,0 +1,11 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package commands
+
+import (
+	"syscall"
+)
+
+func init() {
+	infoSignals = append(infoSignals, syscall.SIGINFO)
+}
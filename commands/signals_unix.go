@@ -0,0 +1,12 @@
+//go:build !windows
+
+package commands
+
+import (
+	"os"
+	"syscall"
+)
+
+var interruptSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP}
+
+var infoSignals = []os.Signal{syscall.SIGUSR1}
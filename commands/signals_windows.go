@@ -0,0 +1,12 @@
+//go:build windows
+
+package commands
+
+import (
+	"os"
+	"syscall"
+)
+
+var interruptSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP}
+
+var infoSignals = []os.Signal{}
@@ -0,0 +1,794 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/twystd/unboxd/box"
+	"github.com/twystd/unboxd/box/lib"
+	"github.com/twystd/unboxd/credentials"
+)
+
+var SyncCmd = Sync{
+	command: command{
+		name:  "sync",
+		delay: 0,
+	},
+
+	direction:  "up",
+	policy:     "source",
+	state:      ".sync-state",
+	checkpoint: ".checkpoint.sync",
+	workers:    4,
+	parts:      4,
+	symlinks:   "skip",
+}
+
+// Sync implements the 'sync' command, which makes a Box folder (--direction up) or a local
+// directory (--direction down) match the other side. The sync computes a plan by comparing the
+// size, modification time and SHA1 of each file and then creates the missing folders, transfers
+// the new and changed files and (with --delete) deletes the extraneous files and folders.
+//
+// The sync state (the size, modification time and SHA1 of each file when last synced) is kept in
+// the --state file so that unchanged local files are not rehashed and a file that has changed on
+// both sides since the last sync is reported as a conflict.
+type Sync struct {
+	command
+	direction       string
+	policy          string
+	dryRun          bool
+	delete          bool
+	state           string
+	checkpoint      string
+	restart         bool
+	workers         uint
+	parts           uint
+	continueOnError bool
+	include         patterns
+	exclude         patterns
+	patterns        string
+	symlinks        string
+	output
+}
+
+// syncAction is a step of a sync plan.
+type syncAction struct {
+	Op     string
+	Path   string
+	Size   int64
+	Reason string
+	local  *localFile
+	remote *file
+	folder uint64
+}
+
+const (
+	syncUpload   = "upload"
+	syncDownload = "download"
+	syncMkdir    = "mkdir"
+	syncDelete   = "delete"
+	syncSkip     = "skip"
+)
+
+// syncEntry is the state of a file when it was last synced.
+type syncEntry struct {
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	SHA1     string    `json:"sha1"`
+}
+
+// syncPlan is the sync plan along with the updated sync state for the files that are already in
+// sync.
+type syncPlan struct {
+	actions   []syncAction
+	entries   map[string]syncEntry
+	unchanged int
+	conflicts int
+}
+
+func (cmd *Sync) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
+	flagset.StringVar(&cmd.direction, "direction", cmd.direction, "Sync direction (up: local to Box, down: Box to local)")
+	flagset.StringVar(&cmd.policy, "policy", cmd.policy, "Policy for files that differ (source: the source wins, newer: the newer file wins)")
+	flagset.BoolVar(&cmd.dryRun, "dry-run", cmd.dryRun, "Displays the sync plan without changing anything")
+	flagset.BoolVar(&cmd.delete, "delete", cmd.delete, "Deletes the files and folders that do not exist in the source")
+	flagset.StringVar(&cmd.state, "state", cmd.state, "File in which to keep the sync state")
+	flagset.Var(&cmd.include, "include", "Glob pattern for paths to include (may be repeated)")
+	flagset.Var(&cmd.exclude, "exclude", "Glob pattern for paths to exclude (may be repeated)")
+	flagset.StringVar(&cmd.patterns, "patterns-from", cmd.patterns, "File with gitignore-like include/exclude patterns")
+	flagset.StringVar(&cmd.symlinks, "symlinks", cmd.symlinks, "Symbolic link handling (skip or follow)")
+	flagset.StringVar(&cmd.checkpoint, "checkpoint", cmd.checkpoint, "Specifies the path for the checkpoint file")
+	flagset.BoolVar(&cmd.restart, "no-resume", cmd.restart, "Restarts the transfers from the beginning")
+	flagset.UintVar(&cmd.workers, "workers", cmd.workers, "Maximum number of files to transfer concurrently")
+	flagset.UintVar(&cmd.parts, "part-workers", cmd.parts, "Maximum number of parts of a large file to upload concurrently")
+	flagset.BoolVar(&cmd.continueOnError, "continue-on-error", cmd.continueOnError, "Continues with the remaining files if a file fails")
+	flagset.DurationVar(&cmd.delay, "delay", cmd.delay, "Delay between multiple requests to reduce traffic to Box API")
+	cmd.output.flags(flagset)
+
+	return flagset
+}
+
+func (cmd Sync) Execute(flagset *flag.FlagSet, c credentials.ICredentials) error {
+	args := flagset.Args()
+	if len(args) < 1 {
+		return fmt.Errorf("missing local directory argument")
+	} else if len(args) < 2 {
+		return fmt.Errorf("missing Box folder argument")
+	}
+
+	if cmd.direction != "up" && cmd.direction != "down" {
+		return fmt.Errorf("invalid --direction '%v' (expected up or down)", cmd.direction)
+	} else if cmd.policy != "source" && cmd.policy != "newer" {
+		return fmt.Errorf("invalid --policy '%v' (expected source or newer)", cmd.policy)
+	} else if cmd.symlinks != "skip" && cmd.symlinks != "follow" {
+		return fmt.Errorf("invalid --symlinks option '%v' (expected skip or follow)", cmd.symlinks)
+	} else if err := validateFormat(); err != nil {
+		return err
+	}
+
+	dir, err := filepath.Abs(args[0])
+	if err != nil {
+		return err
+	}
+
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		return fmt.Errorf("%v is not a directory", dir)
+	} else if err != nil && (cmd.direction == "up" || !errors.Is(err, os.ErrNotExist)) {
+		return err
+	}
+
+	selection, err := selection(cmd.include, cmd.exclude, cmd.patterns)
+	if err != nil {
+		return err
+	}
+
+	credentials := c["box"].(box.Credentials)
+
+	b := box.NewBox()
+	if err := b.Authenticate(credentials); err != nil {
+		return err
+	}
+
+	r := resolver(&b)
+	defer save("sync", r)
+
+	// ... local and Box trees
+	locals, localDirs, skipped, err := cmd.scan(dir, selection)
+	if err != nil {
+		return err
+	}
+
+	rootID, prefix, exists, err := cmd.root(r, args[1])
+	if err != nil {
+		return err
+	}
+
+	hash := cmd.hash("sync", b.Hash(), fmt.Sprintf("%v", rootID), dir, selection.String(), cmd.symlinks)
+
+	ids := map[string]uint64{"/": rootID}
+	remote := map[string]file{}
+	unselected := skipped
+	if cmd.direction == "up" {
+		unselected = []string{}
+	}
+	if exists {
+		t := traversal{
+			tag:   "sync",
+			delay: cmd.delay,
+			files: true,
+		}
+
+		listed, listedFiles, err := t.walk(b, rootID, prefix, hash)
+		if err != nil {
+			return err
+		}
+
+		for _, f := range listed {
+			if rel := relative(prefix, f.Path); selection.Match(rel, lib.Folder) {
+				ids[rel] = f.ID
+			} else if cmd.direction == "up" {
+				unselected = append(unselected, rel)
+			}
+		}
+
+		for _, f := range listedFiles {
+			if rel := relative(prefix, f.FilePath); selection.Match(rel, lib.File) {
+				remote[rel] = f
+			} else if cmd.direction == "up" {
+				unselected = append(unselected, rel)
+			}
+		}
+	}
+
+	// ... plan
+	state, err := loadSyncState(cmd.state, fmt.Sprintf("%v:%v", rootID, dir))
+	if err != nil {
+		return err
+	}
+
+	plan, err := cmd.plan(locals, localDirs, remote, ids, unselected, state.entries)
+	if err != nil {
+		return err
+	}
+
+	cmd.summary(plan)
+
+	if cmd.dryRun {
+		return cmd.results(plan).write("sync", "", cmd.null)
+	}
+
+	// ... sync
+	for k, v := range plan.entries {
+		state.set(k, v)
+	}
+
+	defer func() {
+		if err := state.save(); err != nil {
+			warnf("sync", "error saving sync state (%v)", err)
+		}
+	}()
+
+	if cmd.direction == "up" && !exists {
+		if item, err := r.MkdirAll(args[1]); err != nil {
+			return err
+		} else {
+			ids["/"] = item.ID
+			state.key = fmt.Sprintf("%v:%v", item.ID, dir)
+		}
+	}
+
+	if err := cmd.mkdirs(b, r, plan, ids, prefix, dir); err != nil {
+		return err
+	}
+
+	if err := cmd.transfer(b, plan, ids, dir, state, hash); err != nil {
+		return err
+	}
+
+	return cmd.remove(b, plan, dir, state)
+}
+
+// scan lists the local directory tree, excluding the sync state and checkpoint files. A missing
+// local directory is empty. The paths that were not selected (including the sync state and
+// checkpoint files) are returned along with the selected files and directories.
+func (cmd Sync) scan(dir string, selection *lib.Selection) ([]localFile, []string, []string, error) {
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		return []localFile{}, []string{}, []string{}, nil
+	}
+
+	locals, dirs, skipped, err := scanLocal("sync", dir, selection, cmd.symlinks == "follow")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	ignore := map[string]bool{}
+	for _, f := range []string{cmd.state, cmd.state + ".tmp", cmd.checkpoint, cmd.checkpoint + ".lock"} {
+		if p, err := filepath.Abs(f); err == nil {
+			ignore[p] = true
+		}
+	}
+
+	list := []localFile{}
+	for _, f := range locals {
+		if p, err := filepath.Abs(f.Path); err != nil || !ignore[p] {
+			list = append(list, f)
+		} else {
+			skipped = append(skipped, f.Rel)
+		}
+	}
+
+	return list, dirs, skipped, nil
+}
+
+// root resolves the Box folder. Returns false if the folder is a path that does not exist (yet).
+func (cmd Sync) root(r *box.Resolver, spec string) (uint64, string, bool, error) {
+	rootID, prefix, err := root(r, spec)
+	if errors.Is(err, box.ErrNotFound) && strings.HasPrefix(strings.TrimSpace(spec), "/") {
+		if cmd.direction == "down" {
+			return 0, "", false, err
+		}
+
+		return 0, strings.TrimSuffix(path.Clean(strings.TrimSpace(spec)), "/"), false, nil
+	}
+
+	return rootID, prefix, err == nil, err
+}
+
+// plan compares the local and Box trees and returns the actions required to make the target
+// match the source. The unselected paths are the files and folders in the target that were not
+// selected, which are never deleted.
+func (cmd Sync) plan(locals []localFile, localDirs []string, remote map[string]file, ids map[string]uint64, unselected []string, previous map[string]syncEntry) (syncPlan, error) {
+	plan := syncPlan{
+		actions: []syncAction{},
+		entries: map[string]syncEntry{},
+	}
+
+	up := cmd.direction == "up"
+
+	// ... folders
+	local := map[string]localFile{}
+	dirs := map[string]bool{"/": true}
+	for _, d := range localDirs {
+		dirs[d] = true
+	}
+
+	for _, f := range locals {
+		local[f.Rel] = f
+	}
+
+	sources, targets := dirs, map[string]bool{}
+	for p := range ids {
+		targets[p] = true
+	}
+
+	if !up {
+		sources, targets = targets, dirs
+
+		// ... the parent folders of the Box files are source folders, even if not selected
+		for p := range remote {
+			for d := path.Dir(p); d != "/"; d = path.Dir(d) {
+				sources[d] = true
+			}
+		}
+	}
+
+	for _, p := range sorted(sources) {
+		if !targets[p] {
+			plan.actions = append(plan.actions, syncAction{Op: syncMkdir, Path: p, Reason: "new folder"})
+		}
+	}
+
+	// ... files
+	paths := map[string]bool{}
+	for p := range local {
+		paths[p] = true
+	}
+
+	for p := range remote {
+		paths[p] = true
+	}
+
+	for _, p := range sorted(paths) {
+		l, inLocal := local[p]
+		f, inRemote := remote[p]
+
+		switch {
+		case inLocal && !inRemote && up:
+			plan.actions = append(plan.actions, syncAction{Op: syncUpload, Path: p, Size: l.Size, Reason: "new file", local: &l})
+
+		case !inLocal && inRemote && !up:
+			plan.actions = append(plan.actions, syncAction{Op: syncDownload, Path: p, Size: int64(f.Size), Reason: "new file", remote: &f})
+
+		case inLocal && inRemote:
+			sha1, err := localSHA1(l, previous[p])
+			if err != nil {
+				return plan, err
+			}
+
+			entry := syncEntry{Size: l.Size, Modified: l.Modified, SHA1: sha1}
+			if int64(f.Size) == l.Size && strings.EqualFold(sha1, f.SHA1) {
+				plan.entries[p] = entry
+				plan.unchanged++
+				continue
+			}
+
+			action := cmd.compare(p, l, f, entry, previous)
+			if strings.HasPrefix(action.Reason, "conflict") {
+				plan.conflicts++
+				warnf("sync", "%v  %v", p, action.Reason)
+			}
+
+			plan.actions = append(plan.actions, action)
+		}
+	}
+
+	// ... extraneous files and folders (the top-most folder only). A folder that contains
+	//     unselected items is kept and the selected items in it are deleted individually
+	if cmd.delete {
+		deleted := []string{}
+		covered := func(p string) bool {
+			for _, d := range deleted {
+				if strings.HasPrefix(p, d+"/") {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		// ... an unselected folder that is also a target folder is deleted (or kept) on its own merits
+		kept := func(p string) bool {
+			for _, u := range unselected {
+				if strings.HasPrefix(u, p+"/") && !targets[u] {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		for _, p := range sorted(targets) {
+			if !sources[p] && !covered(p) && !kept(p) {
+				plan.actions = append(plan.actions, syncAction{Op: syncDelete, Path: p, Reason: "extraneous folder", folder: ids[p]})
+				deleted = append(deleted, p)
+			}
+		}
+
+		for _, p := range sorted(paths) {
+			l, inLocal := local[p]
+			f, inRemote := remote[p]
+
+			if up && inRemote && !inLocal && !covered(p) {
+				plan.actions = append(plan.actions, syncAction{Op: syncDelete, Path: p, Size: int64(f.Size), Reason: "extraneous file", remote: &f})
+			} else if !up && inLocal && !inRemote && !covered(p) {
+				plan.actions = append(plan.actions, syncAction{Op: syncDelete, Path: p, Size: l.Size, Reason: "extraneous file", local: &l})
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+// compare returns the action for a file that differs between the local directory and Box,
+// according to the --policy. A file that has changed on both sides since the last sync is
+// reported as a conflict.
+func (cmd Sync) compare(p string, l localFile, f file, entry syncEntry, previous map[string]syncEntry) syncAction {
+	up := cmd.direction == "up"
+
+	action := syncAction{Op: syncUpload, Path: p, Size: l.Size, Reason: "changed", local: &l, remote: &f}
+	sourceNewer := l.Modified.After(f.modified())
+	if !up {
+		action = syncAction{Op: syncDownload, Path: p, Size: int64(f.Size), Reason: "changed", local: &l, remote: &f}
+		sourceNewer = f.modified().After(l.Modified)
+	}
+
+	conflict := false
+	if last, ok := previous[p]; ok {
+		conflict = !strings.EqualFold(entry.SHA1, last.SHA1) && !strings.EqualFold(f.SHA1, last.SHA1)
+	}
+
+	switch {
+	case cmd.policy == "newer" && !sourceNewer && conflict:
+		action.Op, action.Reason = syncSkip, "conflict - target is newer"
+
+	case cmd.policy == "newer" && !sourceNewer:
+		action.Op, action.Reason = syncSkip, "target is newer"
+
+	case conflict && cmd.policy == "newer":
+		action.Reason = "conflict - source is newer"
+
+	case conflict:
+		action.Reason = "conflict - source wins"
+	}
+
+	return action
+}
+
+// summary logs the number of actions of each type in the plan.
+func (cmd Sync) summary(plan syncPlan) {
+	counts := map[string]int{}
+	for _, a := range plan.actions {
+		counts[a.Op]++
+	}
+
+	infof("sync", "plan: %v to upload, %v to download, %v %v to create, %v to delete, %v skipped, %v unchanged, %v %v",
+		counts[syncUpload],
+		counts[syncDownload],
+		counts[syncMkdir], plural(counts[syncMkdir], "folder"),
+		counts[syncDelete],
+		counts[syncSkip],
+		plan.unchanged,
+		plan.conflicts, plural(plan.conflicts, "conflict"))
+}
+
+// results returns the plan as a result set for the output formatter.
+func (cmd Sync) results(plan syncPlan) results {
+	r := results{
+		columns:  []string{"Action", "Path", "Size", "Reason"},
+		defaults: []string{"Action", "Path", "Reason"},
+		rows:     [][]any{},
+	}
+
+	for _, a := range plan.actions {
+		r.rows = append(r.rows, []any{a.Op, a.Path, a.Size, a.Reason})
+	}
+
+	return r
+}
+
+// mkdirs creates the missing Box folders (up) or local directories (down).
+func (cmd Sync) mkdirs(b box.Box, r *box.Resolver, plan syncPlan, ids map[string]uint64, prefix string, dir string) error {
+	list := []string{}
+	for _, a := range plan.actions {
+		if a.Op == syncMkdir {
+			list = append(list, a.Path)
+		}
+	}
+
+	if cmd.direction == "up" {
+		return mkdirs("sync", b, r, list, ids, prefix)
+	}
+
+	for _, p := range list {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.FromSlash(p)), 0750); err != nil {
+			return err
+		}
+	}
+
+	return os.MkdirAll(dir, 0750)
+}
+
+// transfer uploads or downloads the new and changed files as a resumable bulk job, updating the
+// sync state as each file is transferred.
+func (cmd Sync) transfer(b box.Box, plan syncPlan, ids map[string]uint64, dir string, state *syncState, hash string) error {
+	actions := map[string]syncAction{}
+	items := [][]string{}
+	for _, a := range plan.actions {
+		// ... the version identifies the file contents so that a file changed since it was
+		//     transferred by an interrupted sync is not skipped when the sync is resumed
+		switch {
+		case a.Op == syncUpload && a.local != nil:
+			actions[a.Path] = a
+			items = append(items, []string{a.Op, a.Path, fmt.Sprintf("%v:%v", a.local.Size, a.local.Modified.UnixNano())})
+
+		case a.Op == syncDownload && a.remote != nil:
+			actions[a.Path] = a
+			items = append(items, []string{a.Op, a.Path, a.remote.SHA1})
+		}
+	}
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	j := job{
+		tag:             "sync",
+		checkpoint:      cmd.checkpoint,
+		restart:         cmd.restart,
+		workers:         cmd.workers,
+		delay:           cmd.delay,
+		continueOnError: cmd.continueOnError,
+		size: func(args []string) int64 {
+			return actions[args[1]].Size
+		},
+	}
+
+	uploader := UploadFile{
+		parts:      cmd.parts,
+		onConflict: "version",
+	}
+
+	downloader := DownloadFile{
+		overwrite: true,
+	}
+
+	_, err := j.run(hash, items, func(args []string, sent func(int64), s *taskState) (string, error) {
+		a := actions[args[1]]
+
+		if a.Op == syncUpload {
+			fileID, _, err := uploader.exec(b, a.local.Path, ids[path.Dir(a.Path)], sent, s)
+			if err != nil {
+				return "", err
+			}
+
+			if sha1, err := localSHA1(*a.local, state.get(a.Path)); err == nil {
+				state.set(a.Path, syncEntry{Size: a.local.Size, Modified: a.local.Modified, SHA1: sha1})
+			}
+
+			infof("sync", "%v  %v  uploaded", fileID, a.Path)
+			return fileID, nil
+		}
+
+		f := a.remote
+		dest := filepath.Join(dir, filepath.FromSlash(a.Path))
+		d := download{
+			ID:       f.ID,
+			Name:     f.FileName,
+			Size:     int64(f.Size),
+			SHA1:     f.SHA1,
+			Modified: f.modified(),
+		}
+
+		if err := os.MkdirAll(filepath.Dir(dest), 0750); err != nil {
+			return "", err
+		} else if err := downloader.exec(&b, d, dest, sent); err != nil {
+			return "", err
+		}
+
+		if info, err := os.Stat(dest); err == nil {
+			state.set(a.Path, syncEntry{Size: info.Size(), Modified: info.ModTime(), SHA1: f.SHA1})
+		}
+
+		infof("sync", "%v  %v  downloaded", f.ID, a.Path)
+		return fmt.Sprintf("%v", f.ID), nil
+	})
+
+	return err
+}
+
+// remove deletes the extraneous files and folders from Box (up) or the local directory (down).
+func (cmd Sync) remove(b box.Box, plan syncPlan, dir string, state *syncState) error {
+	for _, a := range plan.actions {
+		if a.Op != syncDelete {
+			continue
+		} else if isInterrupted() {
+			return ErrInterrupted
+		}
+
+		var err error
+		switch {
+		case cmd.direction == "up" && a.remote != nil:
+			err = b.DeleteFile(fmt.Sprintf("%v", a.remote.ID))
+
+		case cmd.direction == "up":
+			err = b.DeleteFolderTree(a.folder)
+
+		default:
+			err = os.RemoveAll(filepath.Join(dir, filepath.FromSlash(a.Path)))
+		}
+
+		if err != nil {
+			return fmt.Errorf("%v: %w", a.Path, err)
+		}
+
+		state.remove(a.Path)
+		infof("sync", "%v  deleted (%v)", a.Path, a.Reason)
+	}
+
+	return nil
+}
+
+// localSHA1 returns the SHA1 of a local file, from the sync state if the size and modification
+// time are unchanged since the last sync.
+func localSHA1(f localFile, last syncEntry) (string, error) {
+	if last.SHA1 != "" && last.Size == f.Size && last.Modified.Equal(f.Modified) {
+		return last.SHA1, nil
+	}
+
+	return sha1sum(f.Path)
+}
+
+// modified returns the content modification time of a Box file i.e. the modification time of
+// the local file when it was uploaded, falling back to the Box modified_at for files uploaded
+// without a content modification time.
+func (f file) modified() time.Time {
+	return contentModified(f.ContentModified, f.Modified)
+}
+
+func sorted(set map[string]bool) []string {
+	list := []string{}
+	for k := range set {
+		list = append(list, k)
+	}
+
+	sort.Strings(list)
+
+	return list
+}
+
+// syncState is the persisted state of the files in a local directory and Box folder pair when
+// they were last synced. A state file may hold the state for multiple pairs.
+type syncState struct {
+	sync.Mutex
+	file    string
+	key     string
+	entries map[string]syncEntry
+}
+
+type syncStateFile struct {
+	Version int                             `json:"version"`
+	Pairs   map[string]map[string]syncEntry `json:"pairs"`
+}
+
+const syncStateVersion = 1
+
+// loadSyncState loads the sync state for a local directory and Box folder pair. A missing state
+// file is an empty state.
+func loadSyncState(file string, key string) (*syncState, error) {
+	state := syncState{
+		file:    file,
+		key:     key,
+		entries: map[string]syncEntry{},
+	}
+
+	if file == "" {
+		return &state, nil
+	}
+
+	bytes, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return &state, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	f := syncStateFile{}
+	if err := json.Unmarshal(bytes, &f); err != nil {
+		return nil, fmt.Errorf("invalid sync state file %v (%w)", file, err)
+	} else if f.Version != syncStateVersion {
+		return nil, fmt.Errorf("unsupported sync state file version %v (expected %v)", f.Version, syncStateVersion)
+	}
+
+	if entries, ok := f.Pairs[key]; ok && entries != nil {
+		state.entries = entries
+	}
+
+	return &state, nil
+}
+
+func (s *syncState) get(p string) syncEntry {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.entries[p]
+}
+
+func (s *syncState) set(p string, entry syncEntry) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.entries[p] = entry
+}
+
+// remove deletes the state of a file or, for a folder, the state of all the files in the folder.
+func (s *syncState) remove(p string) {
+	s.Lock()
+	defer s.Unlock()
+
+	for k := range s.entries {
+		if k == p || strings.HasPrefix(k, p+"/") {
+			delete(s.entries, k)
+		}
+	}
+}
+
+// save writes the sync state to a temporary file and then renames it to the state file, so that
+// an interrupted save does not corrupt the state. The state of other directory/folder pairs in
+// the state file is preserved.
+func (s *syncState) save() error {
+	if s.file == "" {
+		return nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	f := syncStateFile{
+		Version: syncStateVersion,
+		Pairs:   map[string]map[string]syncEntry{},
+	}
+
+	if bytes, err := os.ReadFile(s.file); err == nil {
+		if err := json.Unmarshal(bytes, &f); err != nil || f.Version != syncStateVersion {
+			f = syncStateFile{Version: syncStateVersion}
+		}
+	}
+
+	if f.Pairs == nil {
+		f.Pairs = map[string]map[string]syncEntry{}
+	}
+
+	f.Pairs[s.key] = s.entries
+
+	bytes, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := s.file + ".tmp"
+	if err := os.MkdirAll(filepath.Dir(s.file), 0750); err != nil {
+		return err
+	} else if err := os.WriteFile(tmp, bytes, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.file)
+}
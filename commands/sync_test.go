@@ -0,0 +1,296 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyncPlan(t *testing.T) {
+	dir := t.TempDir()
+	modified := time.Date(2023, time.June, 1, 12, 30, 0, 0, time.UTC)
+
+	local := func(rel string, content string) localFile {
+		p := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.WriteFile(p, []byte(content), 0666); err != nil {
+			t.Fatalf("Error writing %v (%v)", p, err)
+		}
+
+		return localFile{Path: p, Rel: rel, Size: int64(len(content)), Modified: modified}
+	}
+
+	checksum := func(f localFile) string {
+		if sha1, err := sha1sum(f.Path); err != nil {
+			t.Fatalf("Error calculating SHA1 for %v (%v)", f.Path, err)
+			return ""
+		} else {
+			return sha1
+		}
+	}
+
+	a := local("/a.txt", "alpha")
+	b := local("/b.txt", "bravo")
+	d := local("/d.txt", "delta")
+
+	locals := []localFile{a, b, d}
+	remote := map[string]file{
+		"/a.txt": {ID: 1, FileName: "a.txt", FilePath: "/a.txt", Size: 5, SHA1: checksum(a)},
+		"/c.txt": {ID: 3, FileName: "c.txt", FilePath: "/c.txt", Size: 7, SHA1: "c"},
+
+		// ... uploaded after the local file was modified but with an older content modification time
+		"/d.txt": {
+			ID:              4,
+			FileName:        "d.txt",
+			FilePath:        "/d.txt",
+			Size:            9,
+			SHA1:            "d",
+			Modified:        modified.Add(time.Hour),
+			ContentModified: modified.Add(-time.Hour),
+		},
+	}
+
+	tests := []struct {
+		direction string
+		delete    bool
+		expected  []string
+	}{
+		{"up", false, []string{"mkdir /sub", "upload /b.txt", "upload /d.txt"}},
+		{"up", true, []string{"mkdir /sub", "upload /b.txt", "upload /d.txt", "delete /c.txt"}},
+		{"down", false, []string{"download /c.txt", "skip /d.txt"}},
+		{"down", true, []string{"download /c.txt", "skip /d.txt", "delete /sub", "delete /b.txt"}},
+	}
+
+	for _, v := range tests {
+		cmd := Sync{direction: v.direction, policy: "newer", delete: v.delete}
+		plan, err := cmd.plan(locals, []string{"/sub"}, remote, map[string]uint64{"/": 100}, nil, map[string]syncEntry{})
+		if err != nil {
+			t.Fatalf("Error planning sync (%v)", err)
+		}
+
+		actions := []string{}
+		for _, a := range plan.actions {
+			actions = append(actions, fmt.Sprintf("%v %v", a.Op, a.Path))
+		}
+
+		if strings.Join(actions, ";") != strings.Join(v.expected, ";") {
+			t.Errorf("Incorrect %v plan (delete:%v)\n   expected:%v\n   got:     %v", v.direction, v.delete, v.expected, actions)
+		}
+
+		if plan.unchanged != 1 {
+			t.Errorf("Incorrect number of unchanged files - expected:%v, got:%v", 1, plan.unchanged)
+		}
+
+		if entry, ok := plan.entries["/a.txt"]; !ok || entry.SHA1 != checksum(a) {
+			t.Errorf("Incorrect sync state for unchanged file - expected:%v, got:%+v", checksum(a), entry)
+		}
+	}
+}
+
+func TestSyncPlanDeleteUnselected(t *testing.T) {
+	tests := []struct {
+		direction  string
+		locals     []localFile
+		dirs       []string
+		remote     map[string]file
+		ids        map[string]uint64
+		unselected []string
+		expected   []string
+	}{
+		{
+			direction: "down",
+			locals: []localFile{
+				{Rel: "/cache/b.txt", Size: 1},
+				{Rel: "/cache/clean/c.txt", Size: 1},
+				{Rel: "/old/d.txt", Size: 1},
+			},
+			dirs:       []string{"/cache", "/cache/clean", "/old"},
+			remote:     map[string]file{},
+			ids:        map[string]uint64{"/": 100},
+			unselected: []string{"/cache/a.tmp"},
+			expected:   []string{"delete /cache/clean", "delete /old", "delete /cache/b.txt"},
+		},
+		{
+			direction:  "down",
+			locals:     []localFile{},
+			dirs:       []string{"/cache"},
+			remote:     map[string]file{},
+			ids:        map[string]uint64{"/": 100},
+			unselected: []string{"/cache/a.tmp", "/cache/tmp", "/cache/tmp/b.tmp"},
+			expected:   []string{},
+		},
+		{
+			direction: "up",
+			locals:    []localFile{},
+			remote: map[string]file{
+				"/old/x/y.pdf": {ID: 1, FilePath: "/old/x/y.pdf", Size: 1},
+				"/old/z.pdf":   {ID: 2, FilePath: "/old/z.pdf", Size: 1},
+			},
+			ids:        map[string]uint64{"/": 100, "/old": 101, "/old/x": 102},
+			unselected: []string{"/old/keep.tmp"},
+			expected:   []string{"delete /old/x", "delete /old/z.pdf"},
+		},
+		{
+			direction: "up",
+			locals:    []localFile{},
+			remote: map[string]file{
+				"/old/x/y.pdf": {ID: 1, FilePath: "/old/x/y.pdf", Size: 1},
+			},
+			ids:        map[string]uint64{"/": 100, "/old": 101, "/old/x": 102},
+			unselected: []string{"/other/keep.tmp"},
+			expected:   []string{"delete /old"},
+		},
+	}
+
+	for _, v := range tests {
+		cmd := Sync{direction: v.direction, policy: "source", delete: true}
+		plan, err := cmd.plan(v.locals, v.dirs, v.remote, v.ids, v.unselected, map[string]syncEntry{})
+		if err != nil {
+			t.Fatalf("Error planning sync (%v)", err)
+		}
+
+		actions := []string{}
+		for _, a := range plan.actions {
+			actions = append(actions, fmt.Sprintf("%v %v", a.Op, a.Path))
+		}
+
+		if strings.Join(actions, ";") != strings.Join(v.expected, ";") {
+			t.Errorf("Incorrect %v plan with unselected %v\n   expected:%v\n   got:     %v", v.direction, v.unselected, v.expected, actions)
+		}
+	}
+}
+
+func TestSyncCompare(t *testing.T) {
+	modified := time.Date(2023, time.June, 1, 12, 30, 0, 0, time.UTC)
+	older := modified.Add(-time.Hour)
+	newer := modified.Add(time.Hour)
+
+	l := localFile{Path: "/tmp/report.pdf", Rel: "/report.pdf", Size: 1024, Modified: modified}
+	entry := syncEntry{Size: 1024, Modified: modified, SHA1: "local"}
+	unchanged := map[string]syncEntry{"/report.pdf": {SHA1: "remote"}}
+	changed := map[string]syncEntry{"/report.pdf": {SHA1: "previous"}}
+
+	tests := []struct {
+		direction string
+		policy    string
+		remote    file
+		previous  map[string]syncEntry
+		op        string
+		reason    string
+	}{
+		{"up", "source", file{SHA1: "remote", Modified: newer}, nil, syncUpload, "changed"},
+		{"up", "newer", file{SHA1: "remote", Modified: older}, nil, syncUpload, "changed"},
+		{"up", "newer", file{SHA1: "remote", Modified: newer}, nil, syncSkip, "target is newer"},
+		{"up", "newer", file{SHA1: "remote", Modified: newer, ContentModified: older}, nil, syncUpload, "changed"},
+		{"up", "newer", file{SHA1: "remote", Modified: older, ContentModified: newer}, nil, syncSkip, "target is newer"},
+		{"up", "source", file{SHA1: "remote", Modified: newer}, unchanged, syncUpload, "changed"},
+		{"up", "source", file{SHA1: "remote", Modified: newer}, changed, syncUpload, "conflict - source wins"},
+		{"up", "newer", file{SHA1: "remote", ContentModified: older}, changed, syncUpload, "conflict - source is newer"},
+		{"up", "newer", file{SHA1: "remote", ContentModified: newer}, changed, syncSkip, "conflict - target is newer"},
+		{"down", "source", file{SHA1: "remote", Modified: older}, nil, syncDownload, "changed"},
+		{"down", "newer", file{SHA1: "remote", Modified: newer, ContentModified: older}, nil, syncSkip, "target is newer"},
+		{"down", "newer", file{SHA1: "remote", Modified: older, ContentModified: newer}, nil, syncDownload, "changed"},
+		{"down", "newer", file{SHA1: "remote", ContentModified: newer}, changed, syncDownload, "conflict - source is newer"},
+	}
+
+	for i, v := range tests {
+		cmd := Sync{direction: v.direction, policy: v.policy}
+		action := cmd.compare("/report.pdf", l, v.remote, entry, v.previous)
+
+		if action.Op != v.op || action.Reason != v.reason {
+			t.Errorf("%v: incorrect action - expected:%v (%v), got:%v (%v)", i+1, v.op, v.reason, action.Op, action.Reason)
+		}
+	}
+}
+
+func TestSyncStateSaveAndLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "state", ".sync-state")
+	modified := time.Date(2023, time.June, 1, 12, 30, 0, 0, time.UTC)
+
+	// ... missing state file
+	alpha, err := loadSyncState(file, "1:/alpha")
+	if err != nil {
+		t.Fatalf("Error loading missing sync state (%v)", err)
+	} else if len(alpha.entries) != 0 {
+		t.Errorf("Expected empty sync state, got %v", alpha.entries)
+	}
+
+	alpha.set("/a.txt", syncEntry{Size: 5, Modified: modified, SHA1: "a"})
+	alpha.set("/sub/b.txt", syncEntry{Size: 5, Modified: modified, SHA1: "b"})
+	alpha.set("/sub/c/d.txt", syncEntry{Size: 5, Modified: modified, SHA1: "d"})
+	alpha.set("/subway.txt", syncEntry{Size: 6, Modified: modified, SHA1: "s"})
+	if err := alpha.save(); err != nil {
+		t.Fatalf("Error saving sync state (%v)", err)
+	}
+
+	// ... other pairs are preserved
+	bravo, err := loadSyncState(file, "2:/bravo")
+	if err != nil {
+		t.Fatalf("Error loading sync state (%v)", err)
+	}
+
+	bravo.set("/e.txt", syncEntry{Size: 1, Modified: modified, SHA1: "e"})
+	if err := bravo.save(); err != nil {
+		t.Fatalf("Error saving sync state (%v)", err)
+	}
+
+	alpha, err = loadSyncState(file, "1:/alpha")
+	if err != nil {
+		t.Fatalf("Error reloading sync state (%v)", err)
+	}
+
+	if entry := alpha.get("/sub/b.txt"); entry.SHA1 != "b" || entry.Size != 5 || !entry.Modified.Equal(modified) {
+		t.Errorf("Incorrect sync state entry - expected:%v, got:%+v", "b", entry)
+	}
+
+	// ... removing a folder removes the files in the folder
+	alpha.remove("/sub")
+
+	expected := []string{"/a.txt", "/subway.txt"}
+	if paths := keys(alpha.entries); strings.Join(paths, ";") != strings.Join(expected, ";") {
+		t.Errorf("Incorrect sync state after remove - expected:%v, got:%v", expected, paths)
+	}
+
+	if err := alpha.save(); err != nil {
+		t.Fatalf("Error saving sync state (%v)", err)
+	}
+
+	if bravo, err := loadSyncState(file, "2:/bravo"); err != nil {
+		t.Fatalf("Error reloading sync state (%v)", err)
+	} else if entry := bravo.get("/e.txt"); entry.SHA1 != "e" {
+		t.Errorf("Incorrect sync state for other pair - expected:%v, got:%+v", "e", entry)
+	}
+
+	if _, err := os.Stat(file + ".tmp"); err == nil {
+		t.Errorf("Temporary sync state file %v not removed", file+".tmp")
+	}
+}
+
+func TestSyncStateInvalid(t *testing.T) {
+	tests := []string{
+		`{ "version": 2, "pairs": {} }`,
+		`{ "version": 1, "pairs": `,
+	}
+
+	for _, v := range tests {
+		file := filepath.Join(t.TempDir(), ".sync-state")
+		if err := os.WriteFile(file, []byte(v), 0666); err != nil {
+			t.Fatalf("Error writing sync state (%v)", err)
+		}
+
+		if _, err := loadSyncState(file, "1:/alpha"); err == nil {
+			t.Errorf("Expected error loading sync state %v", v)
+		}
+	}
+}
+
+func keys(entries map[string]syncEntry) []string {
+	set := map[string]bool{}
+	for k := range entries {
+		set[k] = true
+	}
+
+	return sorted(set)
+}
@@ -3,6 +3,7 @@ package commands
 import (
 	"flag"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/twystd/unboxd/box"
@@ -14,13 +15,22 @@ var TagFileCmd = TagFile{
 		name:  "tag-file",
 		delay: 500 * time.Millisecond,
 	},
+
+	bulk: bulk{
+		checkpoint: ".checkpoint.tag-file",
+		workers:    1,
+	},
 }
 
 type TagFile struct {
 	command
+	bulk
 }
 
 func (cmd *TagFile) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
+	cmd.bulk.flags(flagset)
+	flagset.DurationVar(&cmd.delay, "delay", cmd.delay, "Delay between multiple requests to reduce traffic to Box API")
+
 	return flagset
 }
 
@@ -32,32 +42,58 @@ func (cmd TagFile) Execute(flagset *flag.FlagSet, c credentials.ICredentials) er
 		return err
 	}
 
-	var fileID uint64
+	r := resolver(&b)
+	defer save("tag-file", r)
+
 	var tag string
 
 	args := flagset.Args()
 
-	if len(args) < 1 {
-		return fmt.Errorf("missing file ID")
-	} else if v, err := getFileID(args[0]); err != nil {
-		return err
+	if len(args) < 1 && !cmd.piped() {
+		return fmt.Errorf("missing file ID or path")
+	} else if len(args) < 1 || (len(args) < 2 && !cmd.piped()) {
+		return fmt.Errorf("missing tag")
 	} else {
-		fileID = v
+		tag = args[len(args)-1]
 	}
 
-	if len(args) < 2 {
-		return fmt.Errorf("missing tag")
-	} else {
-		tag = args[1]
+	files, err := cmd.items(args[:len(args)-1])
+	if err != nil {
+		return err
+	} else if len(files) == 0 {
+		return fmt.Errorf("missing file ID or path")
 	}
 
-	if err := cmd.exec(b, fileID, tag); err != nil {
+	selection, err := cmd.selection()
+	if err != nil {
 		return err
 	}
 
-	infof("tag-file", "%v added tag %v\n", fileID, tag)
+	items := [][]string{}
+	for _, file := range files {
+		items = append(items, []string{file})
+	}
+
+	hash := cmd.hash("tag-file", b.Hash(), "", tag, selection.String(), strings.Join(files, "\n"))
+	j := cmd.job("tag-file", cmd.delay)
+
+	_, err = j.run(hash, items, func(args []string, sent func(int64), state *taskState) (string, error) {
+		if fileID, err := getFileID(r, args[0]); err != nil {
+			return "", err
+		} else if ok, err := selectedFile(r, selection, fileID); err != nil {
+			return "", err
+		} else if !ok {
+			infof("tag-file", "%v skipped (not selected)", args[0])
+			return fmt.Sprintf("%v", fileID), nil
+		} else if err := cmd.exec(b, fileID, tag); err != nil {
+			return "", err
+		} else {
+			infof("tag-file", "%v added tag %v\n", fileID, tag)
+			return fmt.Sprintf("%v", fileID), nil
+		}
+	})
 
-	return nil
+	return err
 }
 
 func (cmd TagFile) exec(b box.Box, fileID uint64, tag string) error {
@@ -0,0 +1,325 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/twystd/unboxd/box"
+	"github.com/twystd/unboxd/inventory"
+)
+
+// traversal is the checkpointable breadth-first walk of a Box folder tree shared by the
+// list-folders, list-files and find commands. The depth of an item is the number of folders
+// between it and the starting folder i.e. the items in the starting folder have depth 1. A
+// maxDepth of 0 does not limit the depth of the traversal.
+//
+// If the traversal has an inventory, the contents of each folder are written to the inventory
+// as the folder is listed (irrespective of the depth limits).
+type traversal struct {
+	tag        string
+	checkpoint string
+	restart    bool
+	batch      uint
+	delay      time.Duration
+	files      bool
+	minDepth   uint
+	maxDepth   uint
+	inventory  *inventory.Inventory
+}
+
+// maxRetries is the number of times a failed Box API request is retried before the traversal
+// checkpoints and exits.
+const maxRetries = 3
+
+func (t traversal) walk(b box.Box, folderID uint64, prefix string, hash string) ([]folder, []file, error) {
+	hdr := checkpointHeader{
+		Hash:    hash,
+		Command: t.tag,
+		Root:    fmt.Sprintf("%v:%v", folderID, prefix),
+	}
+
+	// ... record the events stream position before crawling so that an inventory refresh replays
+	//     the changes made during the crawl (a resumed crawl keeps the original position)
+	if t.inventory != nil {
+		if position, err := b.StreamPosition(); err != nil {
+			return nil, nil, err
+		} else {
+			hdr.Position = position
+		}
+	}
+
+	journal, resumed, err := openJournal(t.checkpoint, hdr, t.restart)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer journal.close()
+
+	pipe := resumed.queue
+	folders := resumed.folders
+	files := resumed.files
+
+	if len(pipe) > 0 {
+		infof(t.tag, "Resuming last operation")
+	} else {
+		root := QueueItem{ID: folderID, Path: prefix, Depth: 0}
+		pipe = append(pipe, root)
+		journal.push(root)
+
+		if err := journal.commit(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	snapshot := func(tail int) state {
+		return state{queue: pipe[tail:], folders: folders, files: files}
+	}
+
+	progress := newProgress(t.tag, "folders")
+	progress.pending.Store(int64(len(pipe)))
+	progress.found.Store(int64(len(folders) + len(files)))
+
+	stop := progress.start()
+	defer stop()
+
+	count := uint(0)
+	tail := 0
+	for tail < len(pipe) && !isInterrupted() {
+		item := pipe[tail]
+		depth := item.Depth + 1
+		nfiles := len(files)
+
+		// ... discards the uncommitted results for the current folder
+		rollback := func() {
+			files = files[:nfiles]
+			journal.rollback()
+		}
+
+		// get files for current folder
+		if t.files {
+			if l, err := retry(t.tag, progress, b.ListFiles, item.ID); err != nil {
+				rollback()
+				return folders, files, err
+			} else {
+				items := []inventory.Item{}
+				for _, f := range l {
+					v := file{
+						ID:              f.ID,
+						FileName:        f.Name,
+						FilePath:        item.Path + "/" + f.Name,
+						Tags:            f.Tags,
+						Size:            f.Size,
+						SHA1:            f.SHA1,
+						Created:         f.CreatedAt,
+						Modified:        f.ModifiedAt,
+						ContentModified: f.ContentModifiedAt,
+					}
+
+					items = append(items, inventory.Item{
+						ID:       v.ID,
+						Name:     v.FileName,
+						Path:     v.FilePath,
+						Size:     v.Size,
+						SHA1:     v.SHA1,
+						Tags:     v.Tags,
+						Created:  v.Created,
+						Modified: v.Modified,
+					})
+
+					if depth >= t.minDepth {
+						files = append(files, v)
+						journal.file(v)
+					}
+				}
+
+				if err := t.refresh(item.ID, inventory.FileItem, items); err != nil {
+					rollback()
+					return folders, files, err
+				}
+			}
+		}
+
+		// get subfolders for current folder
+		if l, err := retry(t.tag, progress, b.ListFolders, item.ID); err != nil {
+			rollback()
+			return folders, files, err
+		} else {
+			items := []inventory.Item{}
+			nfolders := len(folders)
+			npipe := len(pipe)
+
+			for _, f := range l {
+				path := item.Path + "/" + f.Name
+				v := folder{
+					ID:       f.ID,
+					Name:     f.Name,
+					Tags:     f.Tags,
+					Path:     path,
+					Size:     f.Size,
+					Created:  f.CreatedAt,
+					Modified: f.ModifiedAt,
+				}
+
+				items = append(items, inventory.Item{
+					ID:       v.ID,
+					Name:     v.Name,
+					Path:     v.Path,
+					Size:     v.Size,
+					Tags:     v.Tags,
+					Created:  v.Created,
+					Modified: v.Modified,
+				})
+
+				if depth >= t.minDepth {
+					folders = append(folders, v)
+					journal.folder(v)
+				}
+
+				if t.maxDepth == 0 || depth < t.maxDepth {
+					q := QueueItem{ID: f.ID, Path: path, Depth: depth}
+					pipe = append(pipe, q)
+					journal.push(q)
+				}
+			}
+
+			if err := t.refresh(item.ID, inventory.FolderItem, items); err != nil {
+				folders = folders[:nfolders]
+				pipe = pipe[:npipe]
+				rollback()
+				return folders, files, err
+			}
+		}
+
+		journal.pop(item)
+		tail++
+
+		progress.done.Add(1)
+		progress.pending.Store(int64(len(pipe) - tail))
+		progress.found.Store(int64(len(folders) + len(files)))
+
+		if err := journal.checkpoint(snapshot(tail)); err != nil {
+			warnf(t.tag, "%v", err)
+		}
+
+		count++
+		if t.batch != 0 && count > t.batch {
+			break
+		}
+
+		if tail < len(pipe) && !isInterrupted() {
+			time.Sleep(t.delay)
+		}
+	}
+
+	// ... incomplete?
+	if len(pipe[tail:]) > 0 {
+		if err := journal.compact(snapshot(tail)); err != nil {
+			return folders, files, err
+		} else {
+			return folders, files, ErrInterrupted
+		}
+	}
+
+	// ... complete!
+	if t.inventory != nil {
+		crawl := inventory.Crawl{
+			Root:      folderID,
+			Path:      "/" + strings.TrimPrefix(prefix, "/"),
+			Files:     t.files,
+			Position:  journal.header.Position,
+			Started:   journal.header.Started,
+			Completed: time.Now(),
+		}
+
+		if err := t.inventory.SetCrawl(crawl); err != nil {
+			return folders, files, err
+		}
+	}
+
+	if err := journal.complete(); err != nil {
+		return folders, files, err
+	}
+
+	return folders, files, nil
+}
+
+// refresh replaces the contents of a folder in the inventory (if any).
+func (t traversal) refresh(folderID uint64, itemType inventory.ItemType, items []inventory.Item) error {
+	if t.inventory == nil {
+		return nil
+	}
+
+	return t.inventory.Refresh(folderID, itemType, items, time.Now())
+}
+
+// retry invokes a Box API request, retrying a failed request with exponential backoff.
+func retry[T any](tag string, p *progress, request func(uint64) (T, error), id uint64) (T, error) {
+	backoff := time.Second
+
+	for attempt := 0; ; attempt++ {
+		p.requests.Add(1)
+
+		v, err := request(id)
+		if err == nil || attempt >= maxRetries || isInterrupted() {
+			return v, err
+		}
+
+		warnf(tag, "%v (retrying in %v)", err, backoff)
+		p.retries.Add(1)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// root resolves a folder ID or absolute folder path to the folder ID and the path prefix for
+// the folder contents. The prefix for the root folder is "" so that items in the root folder
+// have paths of the form /<name>.
+func root(r *box.Resolver, spec string) (uint64, string, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "/" {
+		return 0, "", nil
+	}
+
+	item, err := r.Lookup(spec, box.FolderItem)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if item.Path == "" {
+		if item.Path, err = r.Path(box.FolderItem, item.ID); err != nil {
+			return 0, "", err
+		}
+	}
+
+	return item.ID, strings.TrimSuffix(item.Path, "/"), nil
+}
+
+// relative returns a traversal path relative to the start folder path prefix.
+func relative(prefix string, path string) string {
+	return strings.TrimPrefix(path, prefix)
+}
+
+// scope returns a canonical string for the traversal bounds, suitable for including in a
+// checkpoint hash. Returns "" for the default bounds to keep existing checkpoints valid.
+func scope(root string, relative bool, minDepth uint, maxDepth uint) string {
+	list := []string{}
+
+	if root != "" && root != "/" {
+		list = append(list, fmt.Sprintf("root:%v", root))
+	}
+
+	if relative {
+		list = append(list, "relative")
+	}
+
+	if minDepth != 0 {
+		list = append(list, fmt.Sprintf("min-depth:%v", minDepth))
+	}
+
+	if maxDepth != 0 {
+		list = append(list, fmt.Sprintf("max-depth:%v", maxDepth))
+	}
+
+	return strings.Join(list, ";")
+}
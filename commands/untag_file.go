@@ -3,6 +3,7 @@ package commands
 import (
 	"flag"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/twystd/unboxd/box"
@@ -14,13 +15,22 @@ var UntagFileCmd = UntagFile{
 		name:  "untag-file",
 		delay: 500 * time.Millisecond,
 	},
+
+	bulk: bulk{
+		checkpoint: ".checkpoint.untag-file",
+		workers:    1,
+	},
 }
 
 type UntagFile struct {
 	command
+	bulk
 }
 
 func (cmd *UntagFile) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
+	cmd.bulk.flags(flagset)
+	flagset.DurationVar(&cmd.delay, "delay", cmd.delay, "Delay between multiple requests to reduce traffic to Box API")
+
 	return flagset
 }
 
@@ -32,32 +42,58 @@ func (cmd UntagFile) Execute(flagset *flag.FlagSet, c credentials.ICredentials)
 		return err
 	}
 
-	var fileID uint64
+	r := resolver(&b)
+	defer save("untag-file", r)
+
 	var tag string
 
 	args := flagset.Args()
 
-	if len(args) < 1 {
-		return fmt.Errorf("missing file ID")
-	} else if v, err := getFileID(args[0]); err != nil {
-		return err
+	if len(args) < 1 && !cmd.piped() {
+		return fmt.Errorf("missing file ID or path")
+	} else if len(args) < 1 || (len(args) < 2 && !cmd.piped()) {
+		return fmt.Errorf("missing tag")
 	} else {
-		fileID = v
+		tag = args[len(args)-1]
 	}
 
-	if len(args) < 2 {
-		return fmt.Errorf("missing tag")
-	} else {
-		tag = args[1]
+	files, err := cmd.items(args[:len(args)-1])
+	if err != nil {
+		return err
+	} else if len(files) == 0 {
+		return fmt.Errorf("missing file ID or path")
 	}
 
-	if err := cmd.exec(b, fileID, tag); err != nil {
+	selection, err := cmd.selection()
+	if err != nil {
 		return err
 	}
 
-	infof("untag-file", "%v removed tag %v\n", fileID, tag)
+	items := [][]string{}
+	for _, file := range files {
+		items = append(items, []string{file})
+	}
+
+	hash := cmd.hash("untag-file", b.Hash(), "", tag, selection.String(), strings.Join(files, "\n"))
+	j := cmd.job("untag-file", cmd.delay)
+
+	_, err = j.run(hash, items, func(args []string, sent func(int64), state *taskState) (string, error) {
+		if fileID, err := getFileID(r, args[0]); err != nil {
+			return "", err
+		} else if ok, err := selectedFile(r, selection, fileID); err != nil {
+			return "", err
+		} else if !ok {
+			infof("untag-file", "%v skipped (not selected)", args[0])
+			return fmt.Sprintf("%v", fileID), nil
+		} else if err := cmd.exec(b, fileID, tag); err != nil {
+			return "", err
+		} else {
+			infof("untag-file", "%v removed tag %v\n", fileID, tag)
+			return fmt.Sprintf("%v", fileID), nil
+		}
+	})
 
-	return nil
+	return err
 }
 
 func (cmd UntagFile) exec(b box.Box, fileID uint64, tag string) error {
@@ -1,11 +1,19 @@
 package commands
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/twystd/unboxd/box"
+	"github.com/twystd/unboxd/box/files"
+	"github.com/twystd/unboxd/box/lib"
 	"github.com/twystd/unboxd/credentials"
 )
 
@@ -14,50 +22,267 @@ var UploadFileCmd = UploadFile{
 		name:  "upload-file",
 		delay: 500 * time.Millisecond,
 	},
+
+	bulk: bulk{
+		checkpoint: ".checkpoint.upload-file",
+		workers:    1,
+	},
+
+	parts:      4,
+	onConflict: "fail",
 }
 
+// UploadFile implements the 'upload-file' command. Files larger than the Box single request
+// upload limit (50MB) are uploaded in parts using an upload session. A file argument of '-'
+// uploads the content of stdin as the --name file.
+//
+// An upload that conflicts with an existing item with the same name either fails (the default),
+// is skipped, is uploaded as a new version of the existing file or is renamed with a numeric
+// suffix e.g. 'report (1).pdf'. Conflicts are detected with a preflight check before any
+// content is sent.
 type UploadFile struct {
 	command
+	bulk
+	parts      uint
+	name       string
+	onConflict string
+	parents    bool
 }
 
+var conflicts = []string{"fail", "skip", "version", "rename"}
+
 func (cmd *UploadFile) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
+	cmd.bulk.flags(flagset)
+	flagset.StringVar(&cmd.name, "name", cmd.name, "Name of the uploaded file in Box (required when uploading from stdin)")
+	flagset.BoolVar(&cmd.parents, "parents", cmd.parents, "Creates the destination folder and any missing parent folders")
+	flagset.StringVar(&cmd.onConflict, "on-conflict", cmd.onConflict, "Action if a file with the same name exists (fail, skip, version or rename)")
+	flagset.UintVar(&cmd.parts, "part-workers", cmd.parts, "Maximum number of parts of a large file to upload concurrently")
+	flagset.DurationVar(&cmd.delay, "delay", cmd.delay, "Delay between multiple requests to reduce traffic to Box API")
+
 	return flagset
 }
 
 func (cmd UploadFile) Execute(flagset *flag.FlagSet, c credentials.ICredentials) error {
-	credentials := c["box"].(box.Credentials)
-
-	b := box.NewBox()
-	if err := b.Authenticate(credentials); err != nil {
-		return err
+	if !isConflictAction(cmd.onConflict) {
+		return fmt.Errorf("invalid --on-conflict action '%v' (expected %v)", cmd.onConflict, strings.Join(conflicts, ", "))
 	}
 
 	args := flagset.Args()
-	if len(args) < 1 {
+	if len(args) < 1 && !cmd.piped() {
 		return fmt.Errorf("missing file argument")
 	}
 
-	if len(args) < 2 {
+	if len(args) < 1 || (len(args) < 2 && !cmd.piped()) {
 		return fmt.Errorf("missing folder argument")
 	}
 
-	file := args[0]
-	folder := args[1]
+	files, err := cmd.items(args[:len(args)-1])
+	if err != nil {
+		return err
+	} else if len(files) == 0 {
+		return fmt.Errorf("missing file argument")
+	} else if cmd.name != "" && len(files) > 1 {
+		return fmt.Errorf("--name requires a single file")
+	}
+
+	for _, file := range files {
+		if file == "-" && cmd.name == "" {
+			return fmt.Errorf("--name is required to upload from stdin")
+		} else if file == "-" && (cmd.stdin || cmd.from == "-") {
+			return fmt.Errorf("stdin cannot be both the file list and the file to upload")
+		}
+	}
+
+	selection, err := cmd.selection()
+	if err != nil {
+		return err
+	}
+
+	// ... local files are matched against their path e.g. /data/scans/a.pdf or /scans/a.pdf
+	items := [][]string{}
+	for _, file := range files {
+		if p := path.Join("/", filepath.ToSlash(file)); file != "-" && !selection.Match(p, lib.File) {
+			infof("upload-file", "%v skipped (not selected)", file)
+		} else {
+			items = append(items, []string{file})
+		}
+	}
+
+	if len(items) == 0 {
+		infof("upload-file", "no files selected")
+		return nil
+	}
+
+	// ... resolve (or create) the destination folder once the arguments have been validated
+	credentials := c["box"].(box.Credentials)
+
+	b := box.NewBox()
+	if err := b.Authenticate(credentials); err != nil {
+		return err
+	}
+
+	r := resolver(&b)
+	defer save("upload-file", r)
+
+	var folder uint64
+	if cmd.parents {
+		folder, err = makeFolderID(r, args[len(args)-1])
+	} else {
+		folder, err = getFolderID(r, args[len(args)-1])
+	}
 
-	fileID, err := cmd.exec(b, file, folder)
 	if err != nil {
 		return err
 	}
 
-	infof("upload-file", "%v  %v  uploaded", fileID, file)
+	hash := cmd.hash("upload-file", b.Hash(), fmt.Sprintf("%v", folder), selection.String(), strings.Join(files, "\n"))
+	j := cmd.job("upload-file", cmd.delay)
+	j.size = func(args []string) int64 {
+		if info, err := os.Stat(args[0]); err != nil {
+			return 0
+		} else {
+			return info.Size()
+		}
+	}
+
+	_, err = j.run(hash, items, func(args []string, sent func(int64), state *taskState) (string, error) {
+		if fileID, outcome, err := cmd.exec(b, args[0], folder, sent, state); err != nil {
+			return "", err
+		} else {
+			infof("upload-file", "%v  %v  %v", fileID, args[0], outcome)
+			return fileID, nil
+		}
+	})
 
-	return nil
+	return err
 }
 
-func (cmd UploadFile) exec(b box.Box, file string, folder string) (string, error) {
-	if fileID, err := b.UploadFile(file, folder); err != nil {
-		return "", err
+// exec uploads a file (or stdin), resolving a conflict with an existing file according to the
+// --on-conflict action. Returns the Box file ID and a description of the outcome.
+func (cmd UploadFile) exec(b box.Box, file string, folder uint64, sent func(int64), state *taskState) (string, string, error) {
+	var info os.FileInfo
+	var size int64 = -1
+
+	if file != "-" {
+		if v, err := os.Stat(file); err != nil {
+			return "", "", err
+		} else {
+			info = v
+			size = v.Size()
+		}
+	}
+
+	target := files.Target{
+		Folder: fmt.Sprintf("%v", folder),
+		Name:   cmd.filename(file),
+	}
+
+	// ... the progress of an upload that conflicts is rolled back because the file is sent again
+	//     as a new version or with a new name
+	var attempted atomic.Int64
+	progress := func(n int64) {
+		attempted.Add(n)
+		sent(n)
+	}
+
+	for attempt := 1; ; attempt++ {
+		var conflict *files.ConflictError
+
+		// ... the upload can still conflict if the file was created after the preflight check
+		err := b.PreflightUpload(target, size)
+		if err == nil {
+			fileID, err := cmd.upload(b, file, info, target, progress, state)
+			if err == nil && attempt > 1 {
+				return fileID, fmt.Sprintf("uploaded as %v", target.Name), nil
+			} else if err == nil {
+				return fileID, "uploaded", nil
+			} else if !errors.As(err, &conflict) || file == "-" {
+				return "", "", err
+			}
+
+			sent(-attempted.Swap(0))
+		} else if !errors.As(err, &conflict) {
+			return "", "", err
+		}
+
+		switch cmd.onConflict {
+		case "skip":
+			return conflict.ID, "skipped (already exists)", nil
+
+		case "version":
+			if conflict.Type != "file" {
+				return "", "", fmt.Errorf("%w - cannot upload a new version of a %v", conflict, conflict.Type)
+			}
+
+			version := files.Target{
+				Name:   conflict.Name,
+				FileID: conflict.ID,
+				ETag:   conflict.ETag,
+			}
+
+			if err := b.PreflightUpload(version, size); err != nil {
+				return "", "", err
+			} else if fileID, err := cmd.upload(b, file, info, version, progress, state); err != nil {
+				return "", "", err
+			} else {
+				return fileID, "uploaded new version", nil
+			}
+
+		case "rename":
+			if attempt > 100 {
+				return "", "", fmt.Errorf("%w - no available name after %v attempts", conflict, attempt-1)
+			}
+
+			target.Name = renamed(cmd.filename(file), attempt)
+
+		default:
+			return "", "", conflict
+		}
+	}
+}
+
+// upload uploads stdin or a file to the target, using an upload session for large files.
+func (cmd UploadFile) upload(b box.Box, file string, info os.FileInfo, target files.Target, sent func(int64), state *taskState) (string, error) {
+	if info != nil {
+		target.ContentModified = info.ModTime()
+	}
+
+	if file == "-" {
+		return b.UploadReader(os.Stdin, target, sent)
+	} else if info.Size() > files.MaxUploadSize {
+		return cmd.chunked(b, file, info, target, sent, state)
 	} else {
-		return fileID, nil
+		return b.UploadFile(file, target, sent)
 	}
 }
+
+// filename returns the name of the file in Box i.e. --name if specified, otherwise the local
+// file name.
+func (cmd UploadFile) filename(file string) string {
+	if cmd.name != "" {
+		return cmd.name
+	}
+
+	return filepath.Base(file)
+}
+
+func isConflictAction(action string) bool {
+	for _, v := range conflicts {
+		if action == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// renamed adds a numeric suffix to a file name e.g. 'report (1).pdf'.
+func renamed(name string, n int) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	if base == "" {
+		base, ext = name, ""
+	}
+
+	return fmt.Sprintf("%v (%v)%v", base, n, ext)
+}
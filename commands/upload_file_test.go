@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/twystd/unboxd/credentials"
+)
+
+// TestUploadFileValidation checks that the arguments are validated before the destination folder
+// is resolved or created i.e. without any credentials.
+func TestUploadFileValidation(t *testing.T) {
+	dir := t.TempDir()
+	list := filepath.Join(dir, "files.txt")
+	if err := os.WriteFile(list, []byte("a.pdf\nb.pdf\n"), 0666); err != nil {
+		t.Fatalf("Error writing file list (%v)", err)
+	}
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{}, "missing file argument"},
+		{[]string{"/backups"}, "missing folder argument"},
+		{[]string{"--on-conflict", "replace", "a.pdf", "/backups"}, "invalid --on-conflict action"},
+		{[]string{"--parents", "--name", "c.pdf", "a.pdf", "b.pdf", "/backups/new"}, "--name requires a single file"},
+		{[]string{"--parents", "--name", "c.pdf", "--from", list, "/backups/new"}, "--name requires a single file"},
+		{[]string{"--parents", "-", "/backups/new"}, "--name is required to upload from stdin"},
+		{[]string{"--parents", "--stdin", "--name", "c.pdf", "-", "/backups/new"}, "stdin cannot be both"},
+	}
+
+	for _, v := range tests {
+		cmd := UploadFile{bulk: bulk{workers: 1}, parts: 4, onConflict: "fail"}
+		flagset := cmd.Flagset(flag.NewFlagSet("upload-file", flag.ContinueOnError))
+		if err := flagset.Parse(v.args); err != nil {
+			t.Fatalf("Error parsing %v (%v)", v.args, err)
+		}
+
+		if err := cmd.Execute(flagset, credentials.ICredentials{}); err == nil {
+			t.Errorf("Expected error for %v", v.args)
+		} else if !strings.Contains(err.Error(), v.expected) {
+			t.Errorf("Incorrect error for %v - expected:%v, got:%v", v.args, v.expected, err)
+		}
+	}
+}
+
+func TestUploadFileNothingSelected(t *testing.T) {
+	cmd := UploadFile{bulk: bulk{workers: 1}, parts: 4, onConflict: "fail"}
+	flagset := cmd.Flagset(flag.NewFlagSet("upload-file", flag.ContinueOnError))
+	if err := flagset.Parse([]string{"--parents", "--exclude", "*.pdf", "a.pdf", "b.pdf", "/backups/new"}); err != nil {
+		t.Fatalf("Error parsing arguments (%v)", err)
+	}
+
+	if err := cmd.Execute(flagset, credentials.ICredentials{}); err != nil {
+		t.Errorf("Unexpected error (%v)", err)
+	}
+}
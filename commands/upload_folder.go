@@ -0,0 +1,240 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/twystd/unboxd/box"
+	"github.com/twystd/unboxd/box/folders"
+	"github.com/twystd/unboxd/credentials"
+)
+
+var UploadFolderCmd = UploadFolder{
+	command: command{
+		name:  "upload-folder",
+		delay: 0,
+	},
+
+	checkpoint: ".checkpoint.upload-folder",
+	workers:    4,
+	parts:      4,
+	symlinks:   "skip",
+	onConflict: "version",
+}
+
+// UploadFolder implements the 'upload-folder' command, which mirrors a local directory tree to a
+// Box folder. The folder hierarchy is recreated in Box and files are uploaded concurrently,
+// skipping files whose SHA1 matches the Box file with the same path. A changed file is uploaded
+// as a new version of the Box file (unless --on-conflict specifies otherwise).
+//
+// Symbolic links are either skipped (the default) or followed. Special files (devices, named
+// pipes and sockets) are always skipped with a warning.
+//
+// The upload is a resumable bulk job i.e. rerunning an interrupted or partially failed upload
+// skips the files that have already been uploaded.
+type UploadFolder struct {
+	command
+	checkpoint      string
+	restart         bool
+	workers         uint
+	parts           uint
+	continueOnError bool
+	include         patterns
+	exclude         patterns
+	patterns        string
+	symlinks        string
+	onConflict      string
+}
+
+func (cmd *UploadFolder) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
+	flagset.Var(&cmd.include, "include", "Glob pattern for paths to include (may be repeated)")
+	flagset.Var(&cmd.exclude, "exclude", "Glob pattern for paths to exclude (may be repeated)")
+	flagset.StringVar(&cmd.patterns, "patterns-from", cmd.patterns, "File with gitignore-like include/exclude patterns")
+	flagset.StringVar(&cmd.symlinks, "symlinks", cmd.symlinks, "Symbolic link handling (skip or follow)")
+	flagset.StringVar(&cmd.onConflict, "on-conflict", cmd.onConflict, "Action if a changed file exists in Box (fail, skip, version or rename)")
+	flagset.StringVar(&cmd.checkpoint, "checkpoint", cmd.checkpoint, "Specifies the path for the checkpoint file")
+	flagset.BoolVar(&cmd.restart, "no-resume", cmd.restart, "Restarts the upload from the beginning")
+	flagset.UintVar(&cmd.workers, "workers", cmd.workers, "Maximum number of files to upload concurrently")
+	flagset.UintVar(&cmd.parts, "part-workers", cmd.parts, "Maximum number of parts of a large file to upload concurrently")
+	flagset.BoolVar(&cmd.continueOnError, "continue-on-error", cmd.continueOnError, "Continues with the remaining files if a file fails")
+	flagset.DurationVar(&cmd.delay, "delay", cmd.delay, "Delay between multiple requests to reduce traffic to Box API")
+
+	return flagset
+}
+
+func (cmd UploadFolder) Execute(flagset *flag.FlagSet, c credentials.ICredentials) error {
+	args := flagset.Args()
+	if len(args) < 1 {
+		return fmt.Errorf("missing local directory argument")
+	} else if len(args) < 2 {
+		return fmt.Errorf("missing Box folder argument")
+	}
+
+	if cmd.symlinks != "skip" && cmd.symlinks != "follow" {
+		return fmt.Errorf("invalid --symlinks option '%v' (expected skip or follow)", cmd.symlinks)
+	} else if !isConflictAction(cmd.onConflict) {
+		return fmt.Errorf("invalid --on-conflict action '%v' (expected %v)", cmd.onConflict, strings.Join(conflicts, ", "))
+	}
+
+	dir := args[0]
+	if info, err := os.Stat(dir); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%v is not a directory", dir)
+	}
+
+	selection, err := selection(cmd.include, cmd.exclude, cmd.patterns)
+	if err != nil {
+		return err
+	}
+
+	locals, dirs, _, err := scanLocal("upload-folder", dir, selection, cmd.symlinks == "follow")
+	if err != nil {
+		return err
+	}
+
+	credentials := c["box"].(box.Credentials)
+
+	b := box.NewBox()
+	if err := b.Authenticate(credentials); err != nil {
+		return err
+	}
+
+	r := resolver(&b)
+	defer save("upload-folder", r)
+
+	rootID, prefix, err := cmd.root(r, args[1])
+	if err != nil {
+		return err
+	}
+
+	hash := cmd.hash("upload-folder", b.Hash(), fmt.Sprintf("%v", rootID), selection.String(), cmd.symlinks)
+
+	// ... list the Box folder tree
+	t := traversal{
+		tag:   "upload-folder",
+		delay: cmd.delay,
+		files: true,
+	}
+
+	listed, listedFiles, err := t.walk(b, rootID, prefix, hash)
+	if err != nil {
+		return err
+	}
+
+	ids := map[string]uint64{"/": rootID}
+	for _, f := range listed {
+		ids[relative(prefix, f.Path)] = f.ID
+	}
+
+	remote := map[string]file{}
+	for _, f := range listedFiles {
+		remote[relative(prefix, f.FilePath)] = f
+	}
+
+	// ... recreate the folder hierarchy
+	if err := mkdirs("upload-folder", b, r, dirs, ids, prefix); err != nil {
+		return err
+	}
+
+	// ... upload new and changed files
+	items := [][]string{}
+	sizes := map[string]int64{}
+	for _, f := range locals {
+		items = append(items, []string{f.Path, f.Rel})
+		sizes[f.Rel] = f.Size
+	}
+
+	j := job{
+		tag:             "upload-folder",
+		checkpoint:      cmd.checkpoint,
+		restart:         cmd.restart,
+		workers:         cmd.workers,
+		delay:           cmd.delay,
+		continueOnError: cmd.continueOnError,
+		size: func(args []string) int64 {
+			return sizes[args[1]]
+		},
+	}
+
+	uploader := UploadFile{
+		parts:      cmd.parts,
+		onConflict: cmd.onConflict,
+	}
+
+	hash = cmd.hash("upload-folder", hash, dir)
+
+	_, err = j.run(hash, items, func(args []string, sent func(int64), state *taskState) (string, error) {
+		local, rel := args[0], args[1]
+
+		if f, ok := remote[rel]; ok && int64(f.Size) == sizes[rel] && f.SHA1 != "" {
+			if err := verify(local, f.SHA1); err == nil {
+				infof("upload-folder", "%v  %v  unchanged", f.ID, rel)
+				return fmt.Sprintf("%v", f.ID), nil
+			}
+		}
+
+		if fileID, outcome, err := uploader.exec(b, local, ids[path.Dir(rel)], sent, state); err != nil {
+			return "", err
+		} else {
+			infof("upload-folder", "%v  %v  %v", fileID, rel, outcome)
+			return fileID, nil
+		}
+	})
+
+	return err
+}
+
+// root resolves the Box folder, creating it (and any missing parent folders) if it is a path.
+// Returns the folder ID and the path prefix for the folder contents.
+func (cmd UploadFolder) root(r *box.Resolver, spec string) (uint64, string, error) {
+	if strings.HasPrefix(strings.TrimSpace(spec), "/") {
+		if _, err := r.MkdirAll(spec); err != nil {
+			return 0, "", err
+		}
+	}
+
+	return root(r, spec)
+}
+
+// mkdirs creates the missing Box folders, parents before children. The IDs of the created
+// folders are added to the folder ID map.
+func mkdirs(tag string, b box.Box, r *box.Resolver, dirs []string, ids map[string]uint64, prefix string) error {
+	created := 0
+
+	for _, p := range dirs {
+		if _, ok := ids[p]; ok {
+			continue
+		} else if isInterrupted() {
+			return ErrInterrupted
+		}
+
+		id, err := b.CreateFolder(ids[path.Dir(p)], path.Base(p))
+		if errors.Is(err, folders.ErrExists) {
+			// ... created since the folder tree was listed
+			item, err := r.Resolve(prefix+p, box.FolderItem)
+			if err != nil {
+				return err
+			}
+
+			id = item.ID
+		} else if err != nil {
+			return err
+		} else {
+			infof(tag, "%v  %v  created folder", id, p)
+			created++
+		}
+
+		ids[p] = id
+	}
+
+	if created > 0 {
+		infof(tag, "created %v %v", created, plural(created, "folder"))
+	}
+
+	return nil
+}
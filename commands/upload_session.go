@@ -0,0 +1,214 @@
+package commands
+
+import (
+	"crypto/sha1"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/twystd/unboxd/box"
+	"github.com/twystd/unboxd/box/files"
+)
+
+// chunked is the checkpointed state of a chunked upload i.e. the Box upload session and the
+// parts that have already been uploaded. The file size and modification time are recorded so
+// that a file that has changed since the upload was interrupted is uploaded from the beginning.
+type chunked struct {
+	Session  string       `json:"session"`
+	PartSize int64        `json:"part-size"`
+	Expires  time.Time    `json:"expires"`
+	Size     int64        `json:"size"`
+	Modified time.Time    `json:"modified"`
+	Parts    []files.Part `json:"parts"`
+}
+
+// chunked uploads a large file using a Box upload session. The parts are uploaded concurrently
+// and each uploaded part is recorded in the checkpoint, so that an interrupted upload resumes
+// with the remaining parts. The session is committed with the SHA1 digest of the whole file.
+func (cmd UploadFile) chunked(b box.Box, file string, info os.FileInfo, target files.Target, sent func(int64), state *taskState) (string, error) {
+	upload := chunked{}
+	if state.restore(&upload) && cmd.resumable(b, &upload, info) {
+		infof("upload-file", "resuming upload of %v (%v of %v parts already uploaded)", file, len(upload.Parts), parts(upload.Size, upload.PartSize))
+	} else {
+		session, err := b.CreateUploadSession(target, info.Size())
+		if err != nil {
+			return "", err
+		}
+
+		upload = chunked{
+			Session:  session.ID,
+			PartSize: session.PartSize,
+			Expires:  session.Expires,
+			Size:     info.Size(),
+			Modified: info.ModTime(),
+			Parts:    []files.Part{},
+		}
+
+		if err := state.save(upload); err != nil {
+			warnf("upload-file", "%v", err)
+		}
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+
+	uploaded := map[int64]bool{}
+	for _, p := range upload.Parts {
+		uploaded[p.Offset] = true
+		sent(p.Size)
+	}
+
+	session, size := upload.Session, upload.Size
+	queue := make(chan int64)
+	workers := cmd.parts
+	if workers == 0 {
+		workers = 1
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	var failure error
+
+	failed := func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		return failure != nil
+	}
+
+	for w := uint(0); w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			buffer := make([]byte, upload.PartSize)
+			for offset := range queue {
+				part, err := cmd.part(b, f, buffer, session, offset, size)
+
+				mutex.Lock()
+				if err != nil {
+					if failure == nil {
+						failure = err
+					}
+				} else {
+					upload.Parts = append(upload.Parts, part)
+					if err := state.save(upload); err != nil {
+						warnf("upload-file", "%v", err)
+					}
+				}
+				mutex.Unlock()
+
+				if err == nil {
+					sent(part.Size)
+				}
+			}
+		}()
+	}
+
+	for offset := int64(0); offset < size; offset += upload.PartSize {
+		if isInterrupted() || failed() {
+			break
+		}
+
+		if !uploaded[offset] {
+			queue <- offset
+		}
+	}
+
+	close(queue)
+	wg.Wait()
+
+	if failure != nil {
+		return "", failure
+	} else if isInterrupted() {
+		return "", ErrInterrupted
+	}
+
+	sha, err := digest(f)
+	if err != nil {
+		return "", err
+	}
+
+	sort.Slice(upload.Parts, func(i, j int) bool { return upload.Parts[i].Offset < upload.Parts[j].Offset })
+
+	fileID, err := b.CommitUploadSession(upload.Session, upload.Parts, target.ContentModified, sha)
+
+	// ... a session that conflicts with an existing file cannot be committed to another target
+	//     so a retried upload (e.g. --on-conflict version) starts over with a new session
+	var conflict *files.ConflictError
+	if errors.As(err, &conflict) {
+		if err := state.save(chunked{}); err != nil {
+			warnf("upload-file", "%v", err)
+		}
+	}
+
+	return fileID, err
+}
+
+// resumable returns true if a checkpointed upload session can be resumed i.e. the file has not
+// changed and the session has not expired. The checkpointed parts are reconciled with the parts
+// that Box has received.
+func (cmd UploadFile) resumable(b box.Box, upload *chunked, info os.FileInfo) bool {
+	if upload.Session == "" || upload.PartSize <= 0 {
+		return false
+	} else if upload.Size != info.Size() || !upload.Modified.Equal(info.ModTime()) {
+		return false
+	} else if !upload.Expires.IsZero() && time.Now().After(upload.Expires) {
+		return false
+	}
+
+	received, err := b.ListUploadedParts(upload.Session)
+	if errors.Is(err, files.ErrNotFound) {
+		return false
+	} else if err != nil {
+		warnf("upload-file", "%v", err)
+		return false
+	}
+
+	index := map[string]bool{}
+	for _, p := range received {
+		index[p.PartID] = true
+	}
+
+	list := []files.Part{}
+	for _, p := range upload.Parts {
+		if index[p.PartID] {
+			list = append(list, p)
+		}
+	}
+
+	upload.Parts = list
+
+	return true
+}
+
+// part reads and uploads the part of the file at the offset.
+func (cmd UploadFile) part(b box.Box, f *os.File, buffer []byte, session string, offset int64, size int64) (files.Part, error) {
+	N, err := f.ReadAt(buffer, offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return files.Part{}, err
+	}
+
+	return b.UploadPart(session, buffer[:N], offset, size)
+}
+
+// digest calculates the SHA1 digest of the whole file.
+func digest(f *os.File) ([]byte, error) {
+	hash := sha1.New()
+	if _, err := io.Copy(hash, io.NewSectionReader(f, 0, 1<<62)); err != nil {
+		return nil, err
+	}
+
+	return hash.Sum(nil), nil
+}
+
+func parts(size int64, partSize int64) int64 {
+	return (size + partSize - 1) / partSize
+}
@@ -0,0 +1,498 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/twystd/unboxd/box"
+	"github.com/twystd/unboxd/box/lib"
+	"github.com/twystd/unboxd/credentials"
+)
+
+var WatchCmd = Watch{
+	command: command{
+		name:  "watch",
+		delay: 0,
+	},
+
+	settle:     5 * time.Second,
+	after:      "keep",
+	state:      ".watch-state",
+	workers:    2,
+	parts:      4,
+	onConflict: "version",
+}
+
+// Watch implements the 'watch' command, which watches a local directory tree (e.g. an ingest
+// directory fed by a scanner) and uploads new and modified files to a Box folder until it is
+// interrupted. A file is uploaded once it has not been modified for the --settle interval, so
+// that partially written files are not uploaded, and a modified file is uploaded as a new
+// version of the Box file (unless --on-conflict specifies otherwise). Uploaded files are kept,
+// deleted or moved to another directory.
+//
+// The size, modification time and SHA1 of each uploaded file are kept in the --state file so
+// that on restart only the files that were added or modified while the command was not running
+// are uploaded.
+type Watch struct {
+	command
+	settle     time.Duration
+	after      string
+	moveTo     string
+	state      string
+	workers    uint
+	parts      uint
+	onConflict string
+	include    patterns
+	exclude    patterns
+	patterns   string
+}
+
+// watchEvent is a file system event for a new or modified file or, if the event queue
+// overflowed, a request to rescan the directory tree.
+type watchEvent struct {
+	Path   string
+	Rescan bool
+}
+
+// watchQueue is the set of files waiting to be uploaded, each with the time after which it is
+// due for upload, and the set of files being uploaded. Repeated events for a file postpone the
+// upload until the file has not been modified for the settle interval.
+type watchQueue struct {
+	sync.Mutex
+	dir       string
+	settle    time.Duration
+	selection *lib.Selection
+	ignore    map[string]bool
+	pending   map[string]time.Time
+	inflight  map[string]bool
+}
+
+// watchResult is the outcome of uploading a file.
+type watchResult struct {
+	rel     string
+	fileID  string
+	outcome string
+	entry   syncEntry
+	removed bool
+	err     error
+}
+
+// watchRetry is the interval after which a failed upload is retried.
+const watchRetry = 1 * time.Minute
+
+// watchSaveInterval is the minimum interval between writes of the state file.
+const watchSaveInterval = 5 * time.Second
+
+func (cmd *Watch) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
+	flagset.DurationVar(&cmd.settle, "settle", cmd.settle, "Interval for which a file must be unmodified before it is uploaded")
+	flagset.StringVar(&cmd.after, "after", cmd.after, "Action for an uploaded file (keep, delete or move)")
+	flagset.StringVar(&cmd.moveTo, "move-to", cmd.moveTo, "Directory to which to move uploaded files (--after move)")
+	flagset.StringVar(&cmd.state, "state", cmd.state, "File in which to keep the state of the uploaded files")
+	flagset.StringVar(&cmd.onConflict, "on-conflict", cmd.onConflict, "Action if the file exists in Box (fail, skip, version or rename)")
+	flagset.Var(&cmd.include, "include", "Glob pattern for paths to include (may be repeated)")
+	flagset.Var(&cmd.exclude, "exclude", "Glob pattern for paths to exclude (may be repeated)")
+	flagset.StringVar(&cmd.patterns, "patterns-from", cmd.patterns, "File with gitignore-like include/exclude patterns")
+	flagset.UintVar(&cmd.workers, "workers", cmd.workers, "Maximum number of files to upload concurrently")
+	flagset.UintVar(&cmd.parts, "part-workers", cmd.parts, "Maximum number of parts of a large file to upload concurrently")
+
+	return flagset
+}
+
+func (cmd Watch) Execute(flagset *flag.FlagSet, c credentials.ICredentials) error {
+	args := flagset.Args()
+	if len(args) < 1 {
+		return fmt.Errorf("missing local directory argument")
+	} else if len(args) < 2 {
+		return fmt.Errorf("missing Box folder argument")
+	}
+
+	if cmd.after != "keep" && cmd.after != "delete" && cmd.after != "move" {
+		return fmt.Errorf("invalid --after action '%v' (expected keep, delete or move)", cmd.after)
+	} else if cmd.after == "move" && cmd.moveTo == "" {
+		return fmt.Errorf("--after move requires a --move-to directory")
+	} else if !isConflictAction(cmd.onConflict) {
+		return fmt.Errorf("invalid --on-conflict action '%v' (expected %v)", cmd.onConflict, strings.Join(conflicts, ", "))
+	} else if cmd.settle <= 0 {
+		return fmt.Errorf("invalid --settle interval '%v'", cmd.settle)
+	} else if cmd.workers == 0 {
+		return fmt.Errorf("invalid --workers '%v'", cmd.workers)
+	}
+
+	dir, err := filepath.Abs(args[0])
+	if err != nil {
+		return err
+	} else if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		return err
+	} else if info, err := os.Stat(dir); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%v is not a directory", dir)
+	}
+
+	if cmd.after == "move" {
+		if cmd.moveTo, err = filepath.Abs(cmd.moveTo); err != nil {
+			return err
+		} else if cmd.moveTo == dir || strings.HasPrefix(cmd.moveTo, dir+string(filepath.Separator)) {
+			return fmt.Errorf("--move-to directory %v is in the watched directory", cmd.moveTo)
+		}
+	}
+
+	selection, err := selection(cmd.include, cmd.exclude, cmd.patterns)
+	if err != nil {
+		return err
+	}
+
+	credentials := c["box"].(box.Credentials)
+
+	b := box.NewBox()
+	if err := b.Authenticate(credentials); err != nil {
+		return err
+	}
+
+	r := resolver(&b)
+	defer save("watch", r)
+
+	if strings.HasPrefix(strings.TrimSpace(args[1]), "/") {
+		if _, err := r.MkdirAll(args[1]); err != nil {
+			return err
+		}
+	}
+
+	rootID, prefix, err := root(r, args[1])
+	if err != nil {
+		return err
+	}
+
+	state, err := loadSyncState(cmd.state, fmt.Sprintf("%v:%v", rootID, dir))
+	if err != nil {
+		return err
+	}
+
+	w, err := newWatcher("watch", dir)
+	if err != nil {
+		return err
+	}
+
+	defer w.close()
+
+	return cmd.run(b, r, w, dir, prefix, selection, state)
+}
+
+// run uploads the new and modified files until interrupted. The files in the directory tree are
+// checked against the state on startup (and if inotify events were lost), so that files added
+// or modified while not watching are uploaded.
+func (cmd Watch) run(b box.Box, r *box.Resolver, w *watcher, dir string, prefix string, selection *lib.Selection, state *syncState) error {
+	q := watchQueue{
+		dir:       dir,
+		settle:    cmd.settle,
+		selection: selection,
+		ignore:    map[string]bool{},
+		pending:   map[string]time.Time{},
+		inflight:  map[string]bool{},
+	}
+
+	for _, f := range []string{cmd.state, cmd.state + ".tmp"} {
+		if p, err := filepath.Abs(f); err == nil {
+			q.ignore[p] = true
+		}
+	}
+
+	counts := struct {
+		uploaded  int
+		unchanged int
+		failed    int
+	}{}
+
+	summary := func() string {
+		q.Lock()
+		defer q.Unlock()
+
+		return fmt.Sprintf("%v uploaded, %v unchanged, %v failed, %v pending, %v in progress",
+			counts.uploaded, counts.unchanged, counts.failed, len(q.pending), len(q.inflight))
+	}
+
+	unwatch := watch("watch", summary)
+	defer unwatch()
+
+	// ... queues the files that have changed since they were last uploaded
+	rescan := func() error {
+		locals, _, _, err := scanLocal("watch", dir, selection, false)
+		if err != nil {
+			return err
+		}
+
+		q.Lock()
+		defer q.Unlock()
+
+		for _, f := range locals {
+			last := state.get(f.Rel)
+			if !q.ignore[f.Path] && (last.Size != f.Size || !last.Modified.Equal(f.Modified)) {
+				if _, ok := q.pending[f.Rel]; !ok {
+					q.pending[f.Rel] = time.Now().Add(cmd.settle)
+				}
+			}
+		}
+
+		return nil
+	}
+
+	if err := rescan(); err != nil {
+		return err
+	}
+
+	infof("watch", "watching %v (%v %v to upload)", dir, len(q.pending), plural(len(q.pending), "file"))
+
+	// ... upload workers
+	uploads := make(chan string)
+	results := make(chan watchResult)
+
+	var wg sync.WaitGroup
+	for i := uint(0); i < cmd.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for rel := range uploads {
+				results <- cmd.upload(b, r, dir, prefix, rel, state)
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	saved := time.Now()
+	dirty := false
+	events := w.events
+
+	for !isInterrupted() {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				events = nil
+			} else if q.event(e, time.Now()) {
+				if err := rescan(); err != nil {
+					warnf("watch", "error rescanning %v (%v)", dir, err)
+				}
+			}
+
+		case err := <-w.errors:
+			close(uploads)
+			cmd.drain(results, state)
+			return err
+
+		case result := <-results:
+			q.Lock()
+			delete(q.inflight, result.rel)
+
+			switch {
+			case errors.Is(result.err, os.ErrNotExist):
+				infof("watch", "%v  deleted before upload", result.rel)
+
+			case result.err != nil:
+				warnf("watch", "%v  %v (retrying in %v)", result.rel, result.err, watchRetry)
+				counts.failed++
+				if _, ok := q.pending[result.rel]; !ok {
+					q.pending[result.rel] = time.Now().Add(watchRetry)
+				}
+
+			case result.outcome == "unchanged":
+				counts.unchanged++
+
+			default:
+				infof("watch", "%v  %v  %v", result.fileID, result.rel, result.outcome)
+				counts.uploaded++
+			}
+			q.Unlock()
+
+			if result.err == nil && result.removed {
+				state.remove(result.rel)
+				dirty = true
+			} else if result.err == nil {
+				state.set(result.rel, result.entry)
+				dirty = true
+			}
+
+		case <-ticker.C:
+			q.dispatch(time.Now(), uploads)
+
+			if dirty && time.Since(saved) > watchSaveInterval {
+				if err := state.save(); err != nil {
+					warnf("watch", "error saving state (%v)", err)
+				}
+
+				saved = time.Now()
+				dirty = false
+			}
+		}
+	}
+
+	infof("watch", "stopping after %v %v in progress", len(q.inflight), plural(len(q.inflight), "upload"))
+
+	close(uploads)
+	cmd.drain(results, state)
+
+	infof("watch", "%v", summary())
+
+	return nil
+}
+
+// event schedules the upload of a new or modified file, postponing the upload of a file that is
+// already pending until it has settled. Files that are not selected are ignored. Returns true if
+// the event is a request to rescan the directory tree.
+func (q *watchQueue) event(e watchEvent, now time.Time) bool {
+	if e.Rescan {
+		return true
+	} else if q.ignore[e.Path] {
+		return false
+	}
+
+	if rel, err := filepath.Rel(q.dir, e.Path); err == nil {
+		if rel = "/" + filepath.ToSlash(rel); q.selection.Match(rel, lib.File) {
+			q.Lock()
+			q.pending[rel] = now.Add(q.settle)
+			q.Unlock()
+		}
+	}
+
+	return false
+}
+
+// dispatch queues the files that have not been modified for the settle interval for upload.
+// A file that is still being modified (e.g. if the modify events were coalesced) is rescheduled
+// and a file that no longer exists is dropped.
+func (q *watchQueue) dispatch(now time.Time, uploads chan string) {
+	q.Lock()
+	defer q.Unlock()
+
+	for rel, due := range q.pending {
+		if q.inflight[rel] || now.Before(due) {
+			continue
+		}
+
+		if info, err := os.Stat(filepath.Join(q.dir, filepath.FromSlash(rel))); err != nil {
+			delete(q.pending, rel)
+			continue
+		} else if settled := info.ModTime().Add(q.settle); now.Before(settled) {
+			q.pending[rel] = settled
+			continue
+		}
+
+		select {
+		case uploads <- rel:
+			delete(q.pending, rel)
+			q.inflight[rel] = true
+
+		default:
+			return
+		}
+	}
+}
+
+// drain waits for the in-flight uploads to complete and saves the state.
+func (cmd Watch) drain(results chan watchResult, state *syncState) {
+	for result := range results {
+		if result.err != nil {
+			warnf("watch", "%v  %v", result.rel, result.err)
+		} else if result.removed {
+			state.remove(result.rel)
+		} else {
+			state.set(result.rel, result.entry)
+		}
+
+		if result.err == nil && result.outcome != "unchanged" {
+			infof("watch", "%v  %v  %v", result.fileID, result.rel, result.outcome)
+		}
+	}
+
+	if err := state.save(); err != nil {
+		warnf("watch", "error saving state (%v)", err)
+	}
+}
+
+// upload uploads a file to the corresponding Box folder (creating the folder if necessary)
+// unless it has the same SHA1 as when it was last uploaded. The uploaded file is then kept,
+// deleted or moved according to --after. A file that was modified while it was being uploaded is
+// kept so that it is uploaded again.
+func (cmd Watch) upload(b box.Box, r *box.Resolver, dir string, prefix string, rel string, state *syncState) watchResult {
+	result := watchResult{
+		rel: rel,
+	}
+
+	file := filepath.Join(dir, filepath.FromSlash(rel))
+	info, err := os.Stat(file)
+	if err != nil {
+		result.err = err
+		return result
+	} else if !info.Mode().IsRegular() {
+		result.err = fmt.Errorf("not a regular file")
+		return result
+	}
+
+	local := localFile{Path: file, Rel: rel, Size: info.Size(), Modified: info.ModTime()}
+	last := state.get(rel)
+
+	sha1, err := localSHA1(local, last)
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	result.entry = syncEntry{Size: local.Size, Modified: local.Modified, SHA1: sha1}
+
+	if last.SHA1 != "" && strings.EqualFold(last.SHA1, sha1) {
+		result.outcome = "unchanged"
+	} else {
+		folder, err := r.MkdirAll(prefix + path.Dir(rel))
+		if err != nil {
+			result.err = err
+			return result
+		}
+
+		uploader := UploadFile{
+			parts:      cmd.parts,
+			onConflict: cmd.onConflict,
+		}
+
+		if result.fileID, result.outcome, result.err = uploader.exec(b, file, folder.ID, func(int64) {}, nil); result.err != nil {
+			return result
+		}
+	}
+
+	if v, err := os.Stat(file); err != nil || v.Size() != local.Size || !v.ModTime().Equal(local.Modified) {
+		return result
+	}
+
+	switch cmd.after {
+	case "delete":
+		if err := os.Remove(file); err != nil {
+			warnf("watch", "%v  error deleting uploaded file (%v)", rel, err)
+		} else {
+			result.removed = true
+		}
+
+	case "move":
+		dest := filepath.Join(cmd.moveTo, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(dest), 0750); err != nil {
+			warnf("watch", "%v  error moving uploaded file (%v)", rel, err)
+		} else if err := os.Rename(file, dest); err != nil {
+			warnf("watch", "%v  error moving uploaded file (%v)", rel, err)
+		} else {
+			result.removed = true
+		}
+	}
+
+	return result
+}
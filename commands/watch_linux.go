@@ -0,0 +1,184 @@
+//go:build linux
+
+package commands
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO
+
+// watcher watches a local directory tree for new and modified files using inotify. New
+// subdirectories are watched as they are created and the files already in them are reported,
+// since they may have been written before the watch was added. An inotify queue overflow is
+// reported as a rescan event.
+type watcher struct {
+	tag     string
+	f       *os.File
+	watch   func(dir string) (int32, error)
+	watches map[int32]string
+	events  chan watchEvent
+	errors  chan error
+}
+
+// inotifyEvent is a decoded inotify event i.e. the watch descriptor, the event mask and the name
+// of the file or subdirectory in the watched directory.
+type inotifyEvent struct {
+	wd   int32
+	mask uint32
+	name string
+}
+
+func newWatcher(tag string, dir string) (*watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	// ... a non-blocking file is read through the runtime poller, so close unblocks the reader
+	w := watcher{
+		tag:     tag,
+		f:       os.NewFile(uintptr(fd), "inotify"),
+		watches: map[int32]string{},
+		events:  make(chan watchEvent, 1024),
+		errors:  make(chan error, 1),
+	}
+
+	w.watch = func(dir string) (int32, error) {
+		wd, err := syscall.InotifyAddWatch(fd, dir, inotifyMask|syscall.IN_ONLYDIR)
+		if err != nil {
+			return 0, os.NewSyscallError("inotify_add_watch", err)
+		}
+
+		return int32(wd), nil
+	}
+
+	if _, err := w.add(dir, false); err != nil {
+		w.f.Close()
+		return nil, err
+	}
+
+	raw := make(chan inotifyEvent, 64)
+
+	go w.read(raw)
+	go w.dispatch(raw)
+
+	return &w, nil
+}
+
+// close stops watching the directory tree.
+func (w *watcher) close() error {
+	return w.f.Close()
+}
+
+// add watches a directory and all its subdirectories, returning the files in the directories if
+// list is set.
+func (w *watcher) add(dir string, list bool) ([]string, error) {
+	files := []string{}
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil && p != dir && errors.Is(err, os.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			wd, err := w.watch(p)
+			if err != nil {
+				return err
+			}
+
+			w.watches[wd] = p
+		} else if list && d.Type().IsRegular() {
+			files = append(files, p)
+		}
+
+		return nil
+	})
+
+	return files, err
+}
+
+// read decodes the inotify events until the watcher is closed.
+func (w *watcher) read(raw chan<- inotifyEvent) {
+	defer close(raw)
+
+	buffer := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+
+	for {
+		N, err := w.f.Read(buffer)
+		if errors.Is(err, os.ErrClosed) {
+			return
+		} else if err != nil {
+			w.errors <- err
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= N; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+			name := buffer[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(event.Len)]
+			offset += syscall.SizeofInotifyEvent + int(event.Len)
+
+			for len(name) > 0 && name[len(name)-1] == 0 {
+				name = name[:len(name)-1]
+			}
+
+			raw <- inotifyEvent{wd: event.Wd, mask: event.Mask, name: string(name)}
+		}
+	}
+}
+
+// dispatch translates the inotify events into watch events until the event stream is closed.
+// A file that is created, modified or moved into a watched directory is reported as a watch
+// event, a new subdirectory is watched and the files already in it are reported, and a queue
+// overflow is reported as a rescan.
+func (w *watcher) dispatch(raw <-chan inotifyEvent) {
+	defer close(w.events)
+
+	for e := range raw {
+		w.event(e.wd, e.mask, e.name)
+	}
+}
+
+func (w *watcher) event(wd int32, mask uint32, name string) {
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		warnf(w.tag, "inotify event queue overflow - rescanning")
+		w.events <- watchEvent{Rescan: true}
+		return
+	}
+
+	if mask&syscall.IN_IGNORED != 0 {
+		delete(w.watches, wd)
+		return
+	}
+
+	dir, ok := w.watches[wd]
+	if !ok || name == "" {
+		return
+	}
+
+	p := filepath.Join(dir, name)
+
+	if mask&syscall.IN_ISDIR != 0 {
+		if mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+			files, err := w.add(p, true)
+			if err != nil {
+				warnf(w.tag, "error watching %v (%v)", p, err)
+			}
+
+			for _, f := range files {
+				w.events <- watchEvent{Path: f}
+			}
+		}
+
+		return
+	}
+
+	w.events <- watchEvent{Path: p}
+}
@@ -0,0 +1,118 @@
+//go:build linux
+
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"syscall"
+	"testing"
+)
+
+// stubWatcher returns a watcher for a directory tree that assigns watch descriptors without
+// inotify, so that the event dispatch can be tested without a live watcher.
+func stubWatcher(t *testing.T, dir string) *watcher {
+	next := int32(0)
+	w := watcher{
+		tag:     "watch",
+		watches: map[int32]string{},
+		events:  make(chan watchEvent, 64),
+		errors:  make(chan error, 1),
+	}
+
+	w.watch = func(dir string) (int32, error) {
+		next++
+		return next, nil
+	}
+
+	if _, err := w.add(dir, false); err != nil {
+		t.Fatalf("Error watching %v (%v)", dir, err)
+	}
+
+	return &w
+}
+
+func TestWatcherDispatch(t *testing.T) {
+	dir := t.TempDir()
+	mkdir := func(p string) {
+		if err := os.MkdirAll(filepath.Join(dir, p), 0750); err != nil {
+			t.Fatalf("Error creating %v (%v)", p, err)
+		}
+	}
+
+	write := func(p string) {
+		if err := os.WriteFile(filepath.Join(dir, p), []byte(p), 0666); err != nil {
+			t.Fatalf("Error writing %v (%v)", p, err)
+		}
+	}
+
+	mkdir("alpha")
+	w := stubWatcher(t, dir)
+
+	// ... a new subdirectory with files written before it was watched
+	mkdir("beta/gamma")
+	write("beta/b.pdf")
+	write("beta/gamma/c.pdf")
+
+	tests := []struct {
+		event    inotifyEvent
+		expected []watchEvent
+	}{
+		{inotifyEvent{1, syscall.IN_CREATE, "a.pdf"}, []watchEvent{{Path: filepath.Join(dir, "a.pdf")}}},
+		{inotifyEvent{1, syscall.IN_MODIFY, "a.pdf"}, []watchEvent{{Path: filepath.Join(dir, "a.pdf")}}},
+		{inotifyEvent{1, syscall.IN_CLOSE_WRITE, "a.pdf"}, []watchEvent{{Path: filepath.Join(dir, "a.pdf")}}},
+		{inotifyEvent{2, syscall.IN_MOVED_TO, "d.pdf"}, []watchEvent{{Path: filepath.Join(dir, "alpha", "d.pdf")}}},
+		{inotifyEvent{1, syscall.IN_CREATE | syscall.IN_ISDIR, "beta"}, []watchEvent{{Path: filepath.Join(dir, "beta", "b.pdf")}, {Path: filepath.Join(dir, "beta", "gamma", "c.pdf")}}},
+		{inotifyEvent{4, syscall.IN_CREATE, "e.pdf"}, []watchEvent{{Path: filepath.Join(dir, "beta", "gamma", "e.pdf")}}},
+		{inotifyEvent{1, syscall.IN_MODIFY | syscall.IN_ISDIR, "alpha"}, nil},
+		{inotifyEvent{1, syscall.IN_CREATE, ""}, nil},
+		{inotifyEvent{9, syscall.IN_CREATE, "f.pdf"}, nil},
+		{inotifyEvent{2, syscall.IN_IGNORED, ""}, nil},
+		{inotifyEvent{2, syscall.IN_CREATE, "g.pdf"}, nil},
+		{inotifyEvent{-1, syscall.IN_Q_OVERFLOW, ""}, []watchEvent{{Rescan: true}}},
+	}
+
+	raw := make(chan inotifyEvent)
+	go w.dispatch(raw)
+
+	for i, v := range tests {
+		raw <- v.event
+
+		// ... a marker event separates the watch events for each inotify event
+		marker := fmt.Sprintf("marker-%v", i)
+		raw <- inotifyEvent{1, syscall.IN_CREATE, marker}
+
+		events := []watchEvent{}
+		for e := range w.events {
+			if e.Path == filepath.Join(dir, marker) {
+				break
+			}
+
+			events = append(events, e)
+		}
+
+		sort.Slice(events, func(i, j int) bool { return events[i].Path < events[j].Path })
+
+		if len(events) != len(v.expected) || (len(events) > 0 && !reflect.DeepEqual(events, v.expected)) {
+			t.Errorf("Incorrect watch events for %+v\n   expected:%+v\n   got:     %+v", v.event, v.expected, events)
+		}
+	}
+
+	close(raw)
+	if _, ok := <-w.events; ok {
+		t.Errorf("Expected watch events to be closed with the inotify event stream")
+	}
+
+	expected := map[int32]string{
+		1: dir,
+		3: filepath.Join(dir, "beta"),
+		4: filepath.Join(dir, "beta", "gamma"),
+	}
+
+	if !reflect.DeepEqual(w.watches, expected) {
+		t.Errorf("Incorrect watched directories\n   expected:%v\n   got:     %v", expected, w.watches)
+	}
+}
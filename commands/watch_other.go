@@ -0,0 +1,22 @@
+//go:build !linux
+
+package commands
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// watcher is not implemented on platforms other than Linux.
+type watcher struct {
+	events chan watchEvent
+	errors chan error
+}
+
+func newWatcher(tag string, dir string) (*watcher, error) {
+	return nil, fmt.Errorf("watch is not supported on %v (requires Linux inotify)", runtime.GOOS)
+}
+
+func (w *watcher) close() error {
+	return nil
+}
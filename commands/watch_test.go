@@ -0,0 +1,148 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/twystd/unboxd/box/lib"
+)
+
+func TestWatchQueueEvent(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2023, time.June, 1, 12, 30, 0, 0, time.UTC)
+	settle := 5 * time.Second
+
+	q := watchQueue{
+		dir:       dir,
+		settle:    settle,
+		selection: lib.NewSelection(nil, []string{"*.tmp"}),
+		ignore:    map[string]bool{filepath.Join(dir, ".watch-state"): true},
+		pending:   map[string]time.Time{},
+		inflight:  map[string]bool{},
+	}
+
+	tests := []struct {
+		event  watchEvent
+		at     time.Duration
+		rescan bool
+	}{
+		{watchEvent{Path: filepath.Join(dir, "a.pdf")}, 0, false},
+		{watchEvent{Path: filepath.Join(dir, "alpha", "b.pdf")}, time.Second, false},
+		{watchEvent{Path: filepath.Join(dir, "a.pdf")}, 3 * time.Second, false},
+		{watchEvent{Path: filepath.Join(dir, "c.tmp")}, 3 * time.Second, false},
+		{watchEvent{Path: filepath.Join(dir, ".watch-state")}, 3 * time.Second, false},
+		{watchEvent{Rescan: true}, 4 * time.Second, true},
+	}
+
+	for _, v := range tests {
+		if rescan := q.event(v.event, now.Add(v.at)); rescan != v.rescan {
+			t.Errorf("Incorrect rescan for %+v - expected:%v, got:%v", v.event, v.rescan, rescan)
+		}
+	}
+
+	// ... repeated events postpone the upload
+	expected := map[string]time.Time{
+		"/a.pdf":       now.Add(3*time.Second + settle),
+		"/alpha/b.pdf": now.Add(time.Second + settle),
+	}
+
+	if !reflect.DeepEqual(q.pending, expected) {
+		t.Errorf("Incorrect pending uploads\n   expected:%v\n   got:     %v", expected, q.pending)
+	}
+}
+
+func TestWatchQueueDispatch(t *testing.T) {
+	dir := t.TempDir()
+	settle := 5 * time.Second
+	now := time.Now()
+
+	write := func(name string, modified time.Time) {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(name), 0666); err != nil {
+			t.Fatalf("Error writing %v (%v)", p, err)
+		} else if err := os.Chtimes(p, modified, modified); err != nil {
+			t.Fatalf("Error setting modification time for %v (%v)", p, err)
+		}
+	}
+
+	write("settled.pdf", now.Add(-time.Minute))
+	write("writing.pdf", now.Add(-time.Second))
+	write("uploading.pdf", now.Add(-time.Minute))
+	write("later.pdf", now.Add(-time.Minute))
+
+	q := watchQueue{
+		dir:    dir,
+		settle: settle,
+		pending: map[string]time.Time{
+			"/settled.pdf":   now.Add(-time.Second),
+			"/writing.pdf":   now.Add(-time.Second),
+			"/uploading.pdf": now.Add(-time.Second),
+			"/later.pdf":     now.Add(time.Second),
+			"/deleted.pdf":   now.Add(-time.Second),
+		},
+		inflight: map[string]bool{"/uploading.pdf": true},
+	}
+
+	uploads := make(chan string, 10)
+	q.dispatch(now, uploads)
+	close(uploads)
+
+	dispatched := []string{}
+	for rel := range uploads {
+		dispatched = append(dispatched, rel)
+	}
+
+	if expected := []string{"/settled.pdf"}; !reflect.DeepEqual(dispatched, expected) {
+		t.Errorf("Incorrect dispatched uploads - expected:%v, got:%v", expected, dispatched)
+	}
+
+	if !q.inflight["/settled.pdf"] {
+		t.Errorf("Dispatched upload not in progress")
+	}
+
+	pending := []string{}
+	for rel := range q.pending {
+		pending = append(pending, rel)
+	}
+
+	sort.Strings(pending)
+	if expected := []string{"/later.pdf", "/uploading.pdf", "/writing.pdf"}; !reflect.DeepEqual(pending, expected) {
+		t.Errorf("Incorrect pending uploads - expected:%v, got:%v", expected, pending)
+	}
+
+	// ... a file that is still being written is rescheduled for when it has settled
+	if info, err := os.Stat(filepath.Join(dir, "writing.pdf")); err != nil {
+		t.Fatalf("Error reading %v (%v)", "writing.pdf", err)
+	} else if due := q.pending["/writing.pdf"]; !due.Equal(info.ModTime().Add(settle)) {
+		t.Errorf("Incorrect rescheduled upload - expected:%v, got:%v", info.ModTime().Add(settle), due)
+	}
+}
+
+func TestWatchQueueDispatchBusy(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	q := watchQueue{dir: dir, settle: time.Second, pending: map[string]time.Time{}, inflight: map[string]bool{}}
+	for _, name := range []string{"a.pdf", "b.pdf", "c.pdf"} {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(name), 0666); err != nil {
+			t.Fatalf("Error writing %v (%v)", p, err)
+		} else if err := os.Chtimes(p, now.Add(-time.Minute), now.Add(-time.Minute)); err != nil {
+			t.Fatalf("Error setting modification time for %v (%v)", p, err)
+		}
+
+		q.pending["/"+name] = now.Add(-time.Second)
+	}
+
+	// ... uploads are only dispatched while there is an idle worker
+	uploads := make(chan string, 1)
+	q.dispatch(now, uploads)
+
+	if len(uploads) != 1 || len(q.inflight) != 1 || len(q.pending) != 2 {
+		t.Errorf("Incorrect dispatch with busy workers - expected:1 dispatched, 2 pending, got:%v dispatched, %v pending", len(q.inflight), len(q.pending))
+	}
+}
@@ -6,6 +6,10 @@ require (
 	github.com/cristalhq/jwt/v4 v4.0.2
 	github.com/google/uuid v1.3.0
 	github.com/youmark/pkcs8 v0.0.0-20201027041543-1326539a0a0a
+	go.etcd.io/bbolt v1.3.9
 )
 
-require golang.org/x/crypto v0.31.0 // indirect
+require (
+	golang.org/x/crypto v0.31.0 // indirect
+	golang.org/x/sys v0.28.0 // indirect
+)
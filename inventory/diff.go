@@ -0,0 +1,163 @@
+package inventory
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Snapshot is a set of files and folders to be compared, e.g. loaded from a TSV export, an
+// inventory or a checkpoint. Tags and SHA1 are false if the source did not include the tags
+// or the file SHA1 hashes, in which case tag and content changes are not reported.
+type Snapshot struct {
+	Items []Item
+	Tags  bool
+	SHA1  bool
+}
+
+// Change is a single difference between two snapshots. A file or folder may have more than one
+// change e.g. moved and renamed. From and To are the old and new values for the change (parent
+// folder, name, SHA1 or tags).
+type Change struct {
+	Change string   `json:"change"`
+	Type   ItemType `json:"type"`
+	ID     uint64   `json:"id"`
+	Path   string   `json:"path"`
+	From   string   `json:"from,omitempty"`
+	To     string   `json:"to,omitempty"`
+}
+
+const (
+	added    = "added"
+	removed  = "removed"
+	moved    = "moved"
+	renamed  = "renamed"
+	modified = "modified"
+	retagged = "tags"
+)
+
+// Snapshot returns all the items in the inventory.
+func (v *Inventory) Snapshot() (Snapshot, error) {
+	snapshot := Snapshot{
+		Items: []Item{},
+		Tags:  true,
+		SHA1:  true,
+	}
+
+	err := v.Walk("/", func(item Item) error {
+		snapshot.Items = append(snapshot.Items, item)
+		return nil
+	})
+
+	return snapshot, err
+}
+
+// Diff compares two snapshots, matching files and folders by type and ID rather than by path.
+// An item is reported as moved if its parent folder has changed - a folder is identified by ID
+// (if it is in the snapshot) so the contents of a moved or renamed folder are not themselves
+// reported as moved. The changes are sorted by path.
+func Diff(old, new Snapshot) []Change {
+	changes := []Change{}
+
+	before := index(old.Items)
+	after := index(new.Items)
+	oldParents := parents(old.Items)
+	newParents := parents(new.Items)
+
+	for k, p := range after {
+		q, ok := before[k]
+		if !ok {
+			changes = append(changes, Change{Change: added, Type: p.Type, ID: p.ID, Path: p.Path})
+			continue
+		}
+
+		if oldParents(q) != newParents(p) {
+			changes = append(changes, Change{Change: moved, Type: p.Type, ID: p.ID, Path: p.Path, From: path.Dir(q.Path), To: path.Dir(p.Path)})
+		}
+
+		if name(q) != name(p) {
+			changes = append(changes, Change{Change: renamed, Type: p.Type, ID: p.ID, Path: p.Path, From: name(q), To: name(p)})
+		}
+
+		if old.SHA1 && new.SHA1 && p.Type == FileItem && q.SHA1 != p.SHA1 {
+			changes = append(changes, Change{Change: modified, Type: p.Type, ID: p.ID, Path: p.Path, From: q.SHA1, To: p.SHA1})
+		}
+
+		if old.Tags && new.Tags && tags(q) != tags(p) {
+			changes = append(changes, Change{Change: retagged, Type: p.Type, ID: p.ID, Path: p.Path, From: tags(q), To: tags(p)})
+		}
+	}
+
+	for k, q := range before {
+		if _, ok := after[k]; !ok {
+			changes = append(changes, Change{Change: removed, Type: q.Type, ID: q.ID, Path: q.Path})
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		p, q := changes[i], changes[j]
+		if p.Path != q.Path {
+			return p.Path < q.Path
+		} else if p.Type != q.Type {
+			return p.Type > q.Type
+		}
+
+		return order(p.Change) < order(q.Change)
+	})
+
+	return changes
+}
+
+func index(items []Item) map[string]Item {
+	m := map[string]Item{}
+	for _, item := range items {
+		m[string(key(item.Type, item.ID))] = item
+	}
+
+	return m
+}
+
+// parents returns a function that identifies the parent folder of an item, by ID if the parent
+// folder is in the snapshot and by path otherwise.
+func parents(items []Item) func(Item) string {
+	folders := map[string]uint64{}
+	for _, item := range items {
+		if item.Type == FolderItem {
+			folders[item.Path] = item.ID
+		}
+	}
+
+	return func(item Item) string {
+		if id, ok := folders[path.Dir(item.Path)]; ok {
+			return fmt.Sprintf("%v", id)
+		}
+
+		return path.Dir(item.Path)
+	}
+}
+
+func name(item Item) string {
+	if item.Name != "" {
+		return item.Name
+	}
+
+	return path.Base(item.Path)
+}
+
+func tags(item Item) string {
+	list := append([]string{}, item.Tags...)
+	sort.Strings(list)
+
+	return strings.Join(list, "; ")
+}
+
+func order(change string) int {
+	for i, c := range []string{added, removed, moved, renamed, modified, retagged} {
+		if c == change {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,67 @@
+package inventory
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	old := Snapshot{
+		Items: []Item{
+			{Type: FolderItem, ID: 1, Path: "/alpha"},
+			{Type: FolderItem, ID: 2, Path: "/beta"},
+			{Type: FolderItem, ID: 11, Path: "/alpha/pending"},
+			{Type: FileItem, ID: 101, Path: "/alpha/notes.txt", SHA1: "a1"},
+			{Type: FileItem, ID: 111, Path: "/alpha/pending/report.pdf", SHA1: "b1", Tags: []string{"draft"}},
+			{Type: FileItem, ID: 201, Path: "/beta/photo.jpg", SHA1: "c1"},
+		},
+		Tags: true,
+		SHA1: true,
+	}
+
+	new := Snapshot{
+		Items: []Item{
+			{Type: FolderItem, ID: 1, Path: "/alpha"},
+			{Type: FolderItem, ID: 2, Path: "/beta"},
+			{Type: FolderItem, ID: 11, Path: "/beta/done"},
+			{Type: FileItem, ID: 111, Path: "/beta/done/report.pdf", SHA1: "b2", Tags: []string{"final"}},
+			{Type: FileItem, ID: 201, Path: "/beta/photo.jpg", SHA1: "c1"},
+			{Type: FileItem, ID: 202, Path: "/beta/photo-2.jpg", SHA1: "c2"},
+		},
+		Tags: true,
+		SHA1: true,
+	}
+
+	expected := []Change{
+		{Change: "removed", Type: FileItem, ID: 101, Path: "/alpha/notes.txt"},
+		{Change: "moved", Type: FolderItem, ID: 11, Path: "/beta/done", From: "/alpha", To: "/beta"},
+		{Change: "renamed", Type: FolderItem, ID: 11, Path: "/beta/done", From: "pending", To: "done"},
+		{Change: "modified", Type: FileItem, ID: 111, Path: "/beta/done/report.pdf", From: "b1", To: "b2"},
+		{Change: "tags", Type: FileItem, ID: 111, Path: "/beta/done/report.pdf", From: "draft", To: "final"},
+		{Change: "added", Type: FileItem, ID: 202, Path: "/beta/photo-2.jpg"},
+	}
+
+	if changes := Diff(old, new); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("incorrect diff\n   expected:%v\n   got:     %v", expected, changes)
+	}
+}
+
+func TestDiffWithoutTagsOrSHA1(t *testing.T) {
+	old := Snapshot{
+		Items: []Item{
+			{Type: FileItem, ID: 101, Path: "/alpha/notes.txt", SHA1: "a1", Tags: []string{"draft"}},
+		},
+		Tags: true,
+		SHA1: true,
+	}
+
+	new := Snapshot{
+		Items: []Item{
+			{Type: FileItem, ID: 101, Path: "/alpha/notes.txt"},
+		},
+	}
+
+	if changes := Diff(old, new); len(changes) != 0 {
+		t.Errorf("expected no changes, got:%v", changes)
+	}
+}
@@ -0,0 +1,462 @@
+package inventory
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Inventory is a local database of Box folders and files, populated by list-folders and
+// list-files so that the folder tree can be queried offline. The contents of a folder are
+// replaced each time the folder is listed, so a partial crawl incrementally refreshes the
+// part of the tree it visits.
+//
+// The database is a bbolt file with the buckets:
+//
+//	items     type:ID         JSON encoded item
+//	children  parent|type:ID  index of items by parent folder ID
+//	paths     path\0type:ID   index of items by path
+//	crawls    root            JSON encoded crawl
+//	meta      stream          JSON encoded events stream position
+type Inventory struct {
+	db *bolt.DB
+}
+
+type ItemType string
+
+const (
+	FileItem   ItemType = "file"
+	FolderItem ItemType = "folder"
+)
+
+type Item struct {
+	Type     ItemType  `json:"type"`
+	ID       uint64    `json:"id"`
+	Parent   uint64    `json:"parent"`
+	Name     string    `json:"name"`
+	Path     string    `json:"path"`
+	Size     uint64    `json:"size"`
+	SHA1     string    `json:"sha1,omitempty"`
+	Tags     []string  `json:"tags,omitempty"`
+	Created  time.Time `json:"created"`
+	Modified time.Time `json:"modified"`
+	Crawled  time.Time `json:"crawled"`
+}
+
+// Crawl records the most recent completed traversal from a root folder. The position is the Box
+// events stream position when the traversal started, from which the changes made during and after
+// the traversal can be replayed.
+type Crawl struct {
+	Root      uint64    `json:"root"`
+	Path      string    `json:"path"`
+	Files     bool      `json:"files,omitempty"`
+	Position  string    `json:"position,omitempty"`
+	Started   time.Time `json:"started"`
+	Completed time.Time `json:"completed"`
+}
+
+// Stream records the Box events stream position up to which the inventory has been refreshed.
+type Stream struct {
+	Position  string    `json:"position"`
+	Refreshed time.Time `json:"refreshed"`
+}
+
+var ErrNotFound = errors.New("not found")
+
+var buckets = struct {
+	items    []byte
+	children []byte
+	paths    []byte
+	crawls   []byte
+	meta     []byte
+}{
+	items:    []byte("items"),
+	children: []byte("children"),
+	paths:    []byte("paths"),
+	crawls:   []byte("crawls"),
+	meta:     []byte("meta"),
+}
+
+// Open opens (or creates) an inventory database. Returns an error if the database is in use
+// by another process.
+func Open(file string) (*Inventory, error) {
+	if err := os.MkdirAll(filepath.Dir(file), 0750); err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(file, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("inventory %v is in use", file)
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{buckets.items, buckets.children, buckets.paths, buckets.crawls, buckets.meta} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Inventory{db: db}, nil
+}
+
+// OpenReadOnly opens an existing inventory database for reading. The database may be opened
+// read-only by more than one process at a time, but not while it is open for writing.
+func OpenReadOnly(file string) (*Inventory, error) {
+	if _, err := os.Stat(file); err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(file, 0600, &bolt.Options{Timeout: 1 * time.Second, ReadOnly: true})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("inventory %v is in use", file)
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{buckets.items, buckets.children, buckets.paths, buckets.crawls, buckets.meta} {
+			if tx.Bucket(b) == nil {
+				return fmt.Errorf("%v is not an inventory database", file)
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Inventory{db: db}, nil
+}
+
+func (v *Inventory) Close() error {
+	return v.db.Close()
+}
+
+// Get returns the item with the type and ID. Returns an error wrapping ErrNotFound if the
+// item is not in the inventory.
+func (v *Inventory) Get(t ItemType, id uint64) (Item, error) {
+	item := Item{}
+
+	err := v.db.View(func(tx *bolt.Tx) error {
+		if p, ok, err := get(tx, key(t, id)); err != nil {
+			return err
+		} else if !ok {
+			return fmt.Errorf("%v %v: %w", t, id, ErrNotFound)
+		} else {
+			item = p
+			return nil
+		}
+	})
+
+	return item, err
+}
+
+// Put adds or updates an item. The paths of the items in a folder are updated if the folder
+// has been moved or renamed.
+func (v *Inventory) Put(item Item) error {
+	return v.db.Update(func(tx *bolt.Tx) error {
+		return put(tx, item)
+	})
+}
+
+// Delete removes an item and, for a folder, everything in it.
+func (v *Inventory) Delete(t ItemType, id uint64) error {
+	return v.db.Update(func(tx *bolt.Tx) error {
+		return remove(tx, key(t, id))
+	})
+}
+
+// Refresh replaces the items of the given type in a folder with the current folder contents,
+// removing items (and the contents of folders) that are no longer in the folder.
+func (v *Inventory) Refresh(parent uint64, t ItemType, items []Item, crawled time.Time) error {
+	return v.db.Update(func(tx *bolt.Tx) error {
+		current := map[string]bool{}
+		for _, item := range items {
+			item.Type = t
+			item.Parent = parent
+			item.Crawled = crawled
+
+			if err := put(tx, item); err != nil {
+				return err
+			}
+
+			current[string(key(t, item.ID))] = true
+		}
+
+		stale := [][]byte{}
+		prefix := parentKey(parent)
+		c := tx.Bucket(buckets.children).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			child := k[len(prefix):]
+			if strings.HasPrefix(string(child), string(t)+":") && !current[string(child)] {
+				stale = append(stale, append([]byte{}, child...))
+			}
+		}
+
+		for _, k := range stale {
+			if err := remove(tx, k); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Children returns the items in a folder, sorted by path.
+func (v *Inventory) Children(parent uint64) ([]Item, error) {
+	items := []Item{}
+
+	err := v.db.View(func(tx *bolt.Tx) error {
+		prefix := parentKey(parent)
+		c := tx.Bucket(buckets.children).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			if item, ok, err := get(tx, k[len(prefix):]); err != nil {
+				return err
+			} else if ok {
+				items = append(items, item)
+			}
+		}
+
+		return nil
+	})
+
+	sort.Slice(items, func(i, j int) bool { return items[i].Path < items[j].Path })
+
+	return items, err
+}
+
+// Walk invokes the function for every item at or below the path, in path order. A blank
+// path or "/" walks the entire inventory. Walk stops at the first error returned by the
+// function.
+func (v *Inventory) Walk(path string, f func(Item) error) error {
+	path = strings.TrimSuffix(path, "/")
+
+	return v.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(buckets.paths).Cursor()
+		for k, _ := c.Seek([]byte(path)); k != nil && bytes.HasPrefix(k, []byte(path)); k, _ = c.Next() {
+			p, itemKey, _ := bytes.Cut(k, []byte{0})
+			if path != "" && string(p) != path && !strings.HasPrefix(string(p), path+"/") {
+				continue
+			}
+
+			if item, ok, err := get(tx, itemKey); err != nil {
+				return err
+			} else if !ok {
+				continue
+			} else if err := f(item); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// SetCrawl records a completed crawl. A folders only crawl does not remove the files from the
+// inventory, so the files flag is retained from a previous crawl of the same root folder.
+func (v *Inventory) SetCrawl(crawl Crawl) error {
+	return v.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(buckets.crawls)
+
+		if data := bucket.Get(uint64Key(crawl.Root)); data != nil {
+			previous := Crawl{}
+			if err := json.Unmarshal(data, &previous); err != nil {
+				return err
+			}
+
+			crawl.Files = crawl.Files || previous.Files
+		}
+
+		if data, err := json.Marshal(crawl); err != nil {
+			return err
+		} else {
+			return bucket.Put(uint64Key(crawl.Root), data)
+		}
+	})
+}
+
+// Crawls returns the completed crawls, sorted by root folder path.
+func (v *Inventory) Crawls() ([]Crawl, error) {
+	crawls := []Crawl{}
+
+	err := v.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(buckets.crawls).ForEach(func(k, data []byte) error {
+			crawl := Crawl{}
+			if err := json.Unmarshal(data, &crawl); err != nil {
+				return err
+			}
+
+			crawls = append(crawls, crawl)
+
+			return nil
+		})
+	})
+
+	sort.Slice(crawls, func(i, j int) bool { return crawls[i].Path < crawls[j].Path })
+
+	return crawls, err
+}
+
+// SetStream records the events stream position.
+func (v *Inventory) SetStream(stream Stream) error {
+	return v.db.Update(func(tx *bolt.Tx) error {
+		if data, err := json.Marshal(stream); err != nil {
+			return err
+		} else {
+			return tx.Bucket(buckets.meta).Put([]byte("stream"), data)
+		}
+	})
+}
+
+// Stream returns the events stream position. The position is blank if it has not been set.
+func (v *Inventory) Stream() (Stream, error) {
+	stream := Stream{}
+
+	err := v.db.View(func(tx *bolt.Tx) error {
+		if data := tx.Bucket(buckets.meta).Get([]byte("stream")); data != nil {
+			return json.Unmarshal(data, &stream)
+		}
+
+		return nil
+	})
+
+	return stream, err
+}
+
+func get(tx *bolt.Tx, k []byte) (Item, bool, error) {
+	item := Item{}
+
+	if data := tx.Bucket(buckets.items).Get(k); data == nil {
+		return item, false, nil
+	} else if err := json.Unmarshal(data, &item); err != nil {
+		return item, false, fmt.Errorf("invalid inventory item %s (%v)", k, err)
+	} else {
+		return item, true, nil
+	}
+}
+
+func put(tx *bolt.Tx, item Item) error {
+	k := key(item.Type, item.ID)
+
+	previous, exists, err := get(tx, k)
+	if err != nil {
+		return err
+	} else if exists {
+		if err := unindex(tx, previous); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Bucket(buckets.items).Put(k, data); err != nil {
+		return err
+	} else if err := tx.Bucket(buckets.children).Put(append(parentKey(item.Parent), k...), []byte{}); err != nil {
+		return err
+	} else if err := tx.Bucket(buckets.paths).Put(pathKey(item.Path, k), []byte{}); err != nil {
+		return err
+	}
+
+	// ... moved or renamed folder?
+	if exists && item.Type == FolderItem && previous.Path != item.Path {
+		children := []Item{}
+		prefix := parentKey(item.ID)
+		c := tx.Bucket(buckets.children).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			if child, ok, err := get(tx, k[len(prefix):]); err != nil {
+				return err
+			} else if ok {
+				children = append(children, child)
+			}
+		}
+
+		for _, child := range children {
+			child.Path = item.Path + strings.TrimPrefix(child.Path, previous.Path)
+			if err := put(tx, child); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func remove(tx *bolt.Tx, k []byte) error {
+	item, ok, err := get(tx, k)
+	if err != nil || !ok {
+		return err
+	}
+
+	if item.Type == FolderItem {
+		children := [][]byte{}
+		prefix := parentKey(item.ID)
+		c := tx.Bucket(buckets.children).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			children = append(children, append([]byte{}, k[len(prefix):]...))
+		}
+
+		for _, child := range children {
+			if err := remove(tx, child); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := unindex(tx, item); err != nil {
+		return err
+	}
+
+	return tx.Bucket(buckets.items).Delete(k)
+}
+
+func unindex(tx *bolt.Tx, item Item) error {
+	k := key(item.Type, item.ID)
+
+	if err := tx.Bucket(buckets.children).Delete(append(parentKey(item.Parent), k...)); err != nil {
+		return err
+	}
+
+	return tx.Bucket(buckets.paths).Delete(pathKey(item.Path, k))
+}
+
+func key(t ItemType, ID uint64) []byte {
+	return []byte(fmt.Sprintf("%v:%v", t, ID))
+}
+
+func uint64Key(v uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, v)
+}
+
+func parentKey(parent uint64) []byte {
+	return append(uint64Key(parent), '|')
+}
+
+func pathKey(path string, k []byte) []byte {
+	return append(append([]byte(path), 0), k...)
+}
@@ -0,0 +1,303 @@
+package inventory
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func open(t *testing.T) *Inventory {
+	v, err := Open(filepath.Join(t.TempDir(), "inventory.db"))
+	if err != nil {
+		t.Fatalf("error opening inventory (%v)", err)
+	}
+
+	t.Cleanup(func() { v.Close() })
+
+	return v
+}
+
+func populate(t *testing.T, v *Inventory) {
+	crawled := time.Date(2023, time.June, 1, 12, 30, 0, 0, time.UTC)
+
+	folders := map[uint64][]Item{
+		0: {
+			{ID: 1, Name: "alpha", Path: "/alpha"},
+			{ID: 2, Name: "beta", Path: "/beta"},
+		},
+		1: {
+			{ID: 11, Name: "pending", Path: "/alpha/pending"},
+		},
+	}
+
+	files := map[uint64][]Item{
+		1: {
+			{ID: 101, Name: "notes.txt", Path: "/alpha/notes.txt", Size: 32},
+		},
+		11: {
+			{ID: 111, Name: "report.pdf", Path: "/alpha/pending/report.pdf", Size: 1024, Tags: []string{"draft"}},
+		},
+		2: {
+			{ID: 201, Name: "photo.jpg", Path: "/beta/photo.jpg", Size: 4096},
+		},
+	}
+
+	for parent, items := range folders {
+		if err := v.Refresh(parent, FolderItem, items, crawled); err != nil {
+			t.Fatalf("error refreshing folder %v (%v)", parent, err)
+		}
+	}
+
+	for parent, items := range files {
+		if err := v.Refresh(parent, FileItem, items, crawled); err != nil {
+			t.Fatalf("error refreshing folder %v (%v)", parent, err)
+		}
+	}
+}
+
+func paths(t *testing.T, v *Inventory, path string) []string {
+	list := []string{}
+	if err := v.Walk(path, func(item Item) error { list = append(list, item.Path); return nil }); err != nil {
+		t.Fatalf("error walking inventory (%v)", err)
+	}
+
+	return list
+}
+
+func TestInventoryGet(t *testing.T) {
+	v := open(t)
+	populate(t, v)
+
+	item, err := v.Get(FileItem, 111)
+	if err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+
+	if item.Parent != 11 || item.Path != "/alpha/pending/report.pdf" || item.Size != 1024 || !reflect.DeepEqual(item.Tags, []string{"draft"}) {
+		t.Errorf("incorrect item - expected:%v, got:%v", "report.pdf", item)
+	}
+
+	if item.Crawled.IsZero() {
+		t.Errorf("expected crawl time, got:%v", item.Crawled)
+	}
+
+	if _, err := v.Get(FolderItem, 111); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got:%v", err)
+	}
+}
+
+func TestInventoryWalk(t *testing.T) {
+	v := open(t)
+	populate(t, v)
+
+	tests := []struct {
+		path     string
+		expected []string
+	}{
+		{"/", []string{"/alpha", "/alpha/notes.txt", "/alpha/pending", "/alpha/pending/report.pdf", "/beta", "/beta/photo.jpg"}},
+		{"/alpha", []string{"/alpha", "/alpha/notes.txt", "/alpha/pending", "/alpha/pending/report.pdf"}},
+		{"/alpha/pending/", []string{"/alpha/pending", "/alpha/pending/report.pdf"}},
+		{"/alp", []string{}},
+		{"/gamma", []string{}},
+	}
+
+	for _, test := range tests {
+		if list := paths(t, v, test.path); !reflect.DeepEqual(list, test.expected) {
+			t.Errorf("incorrect walk of %v\n   expected:%v\n   got:     %v", test.path, test.expected, list)
+		}
+	}
+}
+
+func TestInventoryRefresh(t *testing.T) {
+	v := open(t)
+	populate(t, v)
+
+	// ... renamed /alpha/notes.txt and deleted /alpha/pending
+	if err := v.Refresh(1, FileItem, []Item{{ID: 101, Name: "NOTES.txt", Path: "/alpha/NOTES.txt"}}, time.Now()); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+
+	if err := v.Refresh(1, FolderItem, []Item{}, time.Now()); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+
+	expected := []string{"/alpha", "/alpha/NOTES.txt", "/beta", "/beta/photo.jpg"}
+	if list := paths(t, v, "/"); !reflect.DeepEqual(list, expected) {
+		t.Errorf("incorrect inventory after refresh\n   expected:%v\n   got:     %v", expected, list)
+	}
+
+	if _, err := v.Get(FileItem, 111); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected contents of deleted folder to be removed, got:%v", err)
+	}
+
+	if children, err := v.Children(1); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	} else if len(children) != 1 || children[0].ID != 101 {
+		t.Errorf("incorrect children - expected:%v, got:%v", "[101]", children)
+	}
+}
+
+func TestInventoryPutMovedFolder(t *testing.T) {
+	v := open(t)
+	populate(t, v)
+
+	if err := v.Put(Item{Type: FolderItem, ID: 11, Parent: 2, Name: "done", Path: "/beta/done"}); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+
+	expected := []string{"/alpha", "/alpha/notes.txt", "/beta", "/beta/done", "/beta/done/report.pdf", "/beta/photo.jpg"}
+	if list := paths(t, v, "/"); !reflect.DeepEqual(list, expected) {
+		t.Errorf("incorrect inventory after move\n   expected:%v\n   got:     %v", expected, list)
+	}
+
+	if children, err := v.Children(1); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	} else if len(children) != 1 || children[0].ID != 101 {
+		t.Errorf("incorrect children - expected:%v, got:%v", "[101]", children)
+	}
+}
+
+func TestInventoryDelete(t *testing.T) {
+	v := open(t)
+	populate(t, v)
+
+	if err := v.Delete(FolderItem, 1); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+
+	expected := []string{"/beta", "/beta/photo.jpg"}
+	if list := paths(t, v, ""); !reflect.DeepEqual(list, expected) {
+		t.Errorf("incorrect inventory after delete\n   expected:%v\n   got:     %v", expected, list)
+	}
+}
+
+func TestInventoryCrawls(t *testing.T) {
+	v := open(t)
+
+	started := time.Date(2023, time.June, 1, 12, 30, 0, 0, time.UTC)
+	completed := started.Add(90 * time.Minute)
+
+	if err := v.SetCrawl(Crawl{Root: 1, Path: "/alpha", Started: started, Completed: completed}); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+
+	if err := v.SetCrawl(Crawl{Root: 1, Path: "/alpha", Started: started, Completed: completed.Add(time.Hour)}); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+
+	crawls, err := v.Crawls()
+	if err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	} else if len(crawls) != 1 || !crawls[0].Completed.Equal(completed.Add(time.Hour)) {
+		t.Errorf("incorrect crawls - got:%v", crawls)
+	}
+}
+
+func TestInventoryCrawlsFiles(t *testing.T) {
+	v := open(t)
+
+	started := time.Date(2023, time.June, 1, 12, 30, 0, 0, time.UTC)
+
+	if err := v.SetCrawl(Crawl{Root: 1, Path: "/alpha", Files: true, Position: "1152922976252290886", Started: started}); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+
+	if err := v.SetCrawl(Crawl{Root: 1, Path: "/alpha", Files: false, Position: "1152922976252290999", Started: started.Add(time.Hour)}); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+
+	if err := v.SetCrawl(Crawl{Root: 2, Path: "/beta", Files: false, Started: started}); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+
+	crawls, err := v.Crawls()
+	if err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	} else if len(crawls) != 2 {
+		t.Fatalf("incorrect crawls - got:%v", crawls)
+	}
+
+	if !crawls[0].Files || crawls[0].Position != "1152922976252290999" {
+		t.Errorf("incorrect merged crawl - got:%+v", crawls[0])
+	}
+
+	if crawls[1].Files {
+		t.Errorf("incorrect folders only crawl - got:%+v", crawls[1])
+	}
+}
+
+func TestInventoryStream(t *testing.T) {
+	v := open(t)
+
+	if stream, err := v.Stream(); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	} else if stream.Position != "" {
+		t.Errorf("expected blank stream position, got:%v", stream.Position)
+	}
+
+	if err := v.SetStream(Stream{Position: "1152922976252290886", Refreshed: time.Now()}); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+
+	if stream, err := v.Stream(); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	} else if stream.Position != "1152922976252290886" {
+		t.Errorf("incorrect stream position - expected:%v, got:%v", "1152922976252290886", stream.Position)
+	}
+}
+
+func TestInventoryInUse(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "inventory.db")
+
+	v, err := Open(file)
+	if err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+
+	defer v.Close()
+
+	if _, err := Open(file); err == nil {
+		t.Errorf("expected 'in use' error opening locked inventory")
+	}
+}
+
+func TestInventoryOpenReadOnly(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "inventory.db")
+
+	if _, err := OpenReadOnly(file); err == nil {
+		t.Errorf("expected error opening missing inventory read-only")
+	}
+
+	if v, err := Open(file); err != nil {
+		t.Fatalf("error opening inventory (%v)", err)
+	} else {
+		populate(t, v)
+		v.Close()
+	}
+
+	v, err := OpenReadOnly(file)
+	if err != nil {
+		t.Fatalf("error opening inventory read-only (%v)", err)
+	}
+
+	defer v.Close()
+
+	// ... may be opened read-only by more than one reader
+	if u, err := OpenReadOnly(file); err != nil {
+		t.Errorf("error opening inventory read-only twice (%v)", err)
+	} else {
+		u.Close()
+	}
+
+	if snapshot, err := v.Snapshot(); err != nil {
+		t.Errorf("unexpected error (%v)", err)
+	} else if len(snapshot.Items) != 6 {
+		t.Errorf("incorrect read-only snapshot - expected:%v items, got:%v", 6, len(snapshot.Items))
+	}
+
+	if err := v.Put(Item{Type: FolderItem, ID: 3, Name: "gamma", Path: "/gamma"}); err == nil {
+		t.Errorf("expected error updating read-only inventory")
+	}
+}